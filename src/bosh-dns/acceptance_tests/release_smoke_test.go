@@ -221,17 +221,15 @@ var _ = Describe("Integration", func() {
 
 				runErrand("make-health-executable-job-unhealthy" + osSuffix)
 
-				Eventually(func() map[string]string {
+				Eventually(func() interface{} {
 					respData, err := secureGetRespBody(client, firstInstance.IP, 2345)
 					Expect(err).ToNot(HaveOccurred())
 
-					var respJson map[string]string
+					var respJson map[string]interface{}
 					err = json.Unmarshal(respData, &respJson)
 					Expect(err).ToNot(HaveOccurred())
-					return respJson
-				}, 31*time.Second).Should(Equal(map[string]string{
-					"state": "job-health-executable-fail",
-				}))
+					return respJson["state"]
+				}, 31*time.Second).Should(Equal("job-health-executable-fail"))
 
 				runErrand("make-health-executable-job-healthy" + osSuffix)
 
@@ -317,7 +315,7 @@ func setupSecureGet() *httpclient.HTTPClient {
 	Expect(err).NotTo(HaveOccurred())
 
 	logger := boshlog.NewAsyncWriterLogger(boshlog.LevelDebug, ioutil.Discard)
-	return healthclient.NewHealthClient([]byte(caCert), cert, logger)
+	return healthclient.NewHealthClient([]byte(caCert), cert, healthclient.DefaultConfig(), logger)
 }
 
 func secureGetRespBody(client *httpclient.HTTPClient, hostname string, port int) ([]byte, error) {
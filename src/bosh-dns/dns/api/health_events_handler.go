@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"bosh-dns/dns/server/healthiness"
+)
+
+//go:generate counterfeiter . HealthEventSubscriber
+
+type HealthEventSubscriber interface {
+	Subscribe() (<-chan healthiness.HealthEvent, func())
+}
+
+// HealthEventsHandler streams health changes as server-sent events until the
+// client disconnects. Changes before the request are not replayed.
+type HealthEventsHandler struct {
+	subscriber HealthEventSubscriber
+}
+
+func NewHealthEventsHandler(subscriber HealthEventSubscriber) HealthEventsHandler {
+	return HealthEventsHandler{subscriber: subscriber}
+}
+
+func (h HealthEventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := h.subscriber.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+
+			fmt.Fprintf(w, "event: health\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package api_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"bosh-dns/dns/api"
+	"bosh-dns/dns/api/apifakes"
+	"bosh-dns/dns/server/healthiness"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HealthEventsHandler", func() {
+	var (
+		fakeSubscriber *apifakes.FakeHealthEventSubscriber
+		events         chan healthiness.HealthEvent
+		unsubscribed   chan struct{}
+		server         *httptest.Server
+	)
+
+	BeforeEach(func() {
+		events = make(chan healthiness.HealthEvent)
+		unsubscribed = make(chan struct{})
+
+		fakeSubscriber = &apifakes.FakeHealthEventSubscriber{}
+		fakeSubscriber.SubscribeReturns(events, func() { close(unsubscribed) })
+
+		server = httptest.NewServer(api.NewHealthEventsHandler(fakeSubscriber))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("streams health events as server-sent events", func() {
+		response, err := http.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		defer response.Body.Close()
+
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+		Expect(response.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+
+		events <- healthiness.HealthEvent{
+			IP:             "10.0.0.1",
+			Domains:        []string{"app.bosh."},
+			Status:         healthiness.StatusUnhealthy,
+			PreviousStatus: healthiness.StatusHealthy,
+			Reason:         "connection refused",
+			Timestamp:      time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+		}
+
+		reader := bufio.NewReader(response.Body)
+
+		line, err := reader.ReadString('\n')
+		Expect(err).NotTo(HaveOccurred())
+		Expect(line).To(Equal("event: health\n"))
+
+		line, err = reader.ReadString('\n')
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strings.TrimPrefix(line, "data: ")).To(MatchJSON(`{
+			"ip": "10.0.0.1",
+			"domains": ["app.bosh."],
+			"status": "unhealthy",
+			"previous_status": "healthy",
+			"reason": "connection refused",
+			"timestamp": "2018-01-02T03:04:05Z"
+		}`))
+
+		line, err = reader.ReadString('\n')
+		Expect(err).NotTo(HaveOccurred())
+		Expect(line).To(Equal("\n"))
+	})
+
+	It("unsubscribes when the client disconnects", func() {
+		response, err := http.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		response.Body.Close()
+
+		Eventually(unsubscribed).Should(BeClosed())
+	})
+
+	It("rejects other methods", func() {
+		response, err := http.Post(server.URL, "text/plain", nil)
+		Expect(err).NotTo(HaveOccurred())
+		defer response.Body.Close()
+
+		Expect(response.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+		Expect(fakeSubscriber.SubscribeCallCount()).To(Equal(0))
+	})
+})
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"bosh-dns/dns/server/healthiness"
+)
+
+//go:generate counterfeiter . HealthStateReporter
+
+type HealthStateReporter interface {
+	HealthStates() map[string]healthiness.HealthState
+}
+
+type HealthHandler struct {
+	reporter HealthStateReporter
+}
+
+type ipHealthState struct {
+	IP string `json:"ip"`
+	healthiness.HealthState
+}
+
+func NewHealthHandler(reporter HealthStateReporter) HealthHandler {
+	return HealthHandler{reporter: reporter}
+}
+
+func (h HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	states := h.reporter.HealthStates()
+
+	ips := []string{}
+	for ip := range states {
+		ips = append(ips, ip)
+	}
+
+	sort.Strings(ips)
+
+	response := struct {
+		IPs []ipHealthState `json:"ips"`
+	}{IPs: []ipHealthState{}}
+
+	for _, ip := range ips {
+		response.IPs = append(response.IPs, ipHealthState{IP: ip, HealthState: states[ip]})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
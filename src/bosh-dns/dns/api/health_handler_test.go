@@ -0,0 +1,86 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"bosh-dns/dns/api"
+	"bosh-dns/dns/api/apifakes"
+	"bosh-dns/dns/server/healthiness"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HealthHandler", func() {
+	var (
+		fakeReporter *apifakes.FakeHealthStateReporter
+		handler      api.HealthHandler
+		recorder     *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		fakeReporter = &apifakes.FakeHealthStateReporter{}
+		handler = api.NewHealthHandler(fakeReporter)
+		recorder = httptest.NewRecorder()
+	})
+
+	It("reports the health state of every tracked ip sorted by ip", func() {
+		lastCheck := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+		fakeReporter.HealthStatesReturns(map[string]healthiness.HealthState{
+			"10.0.0.2": {
+				Status:              healthiness.StatusUnhealthy,
+				ConsecutiveFailures: 3,
+				LastCheck:           lastCheck,
+				NextCheck:           lastCheck.Add(40 * time.Second),
+			},
+			"10.0.0.1": {
+				Status:               healthiness.StatusHealthy,
+				ConsecutiveSuccesses: 5,
+				LastCheck:            lastCheck,
+				NextCheck:            lastCheck.Add(20 * time.Second),
+			},
+		})
+
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/health", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(recorder.Body.String()).To(MatchJSON(`{
+			"ips": [
+				{
+					"ip": "10.0.0.1",
+					"status": "healthy",
+					"consecutive_successes": 5,
+					"consecutive_failures": 0,
+					"last_check": "2018-01-02T03:04:05Z",
+					"next_check": "2018-01-02T03:04:25Z"
+				},
+				{
+					"ip": "10.0.0.2",
+					"status": "unhealthy",
+					"consecutive_successes": 0,
+					"consecutive_failures": 3,
+					"last_check": "2018-01-02T03:04:05Z",
+					"next_check": "2018-01-02T03:04:45Z"
+				}
+			]
+		}`))
+	})
+
+	It("reports an empty list when nothing is tracked", func() {
+		fakeReporter.HealthStatesReturns(map[string]healthiness.HealthState{})
+
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/health", nil))
+
+		Expect(recorder.Body.String()).To(MatchJSON(`{"ips": []}`))
+	})
+
+	It("rejects other methods", func() {
+		handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/health", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(fakeReporter.HealthStatesCallCount()).To(Equal(0))
+	})
+})
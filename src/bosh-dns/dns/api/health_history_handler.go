@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"bosh-dns/dns/server/healthiness"
+)
+
+//go:generate counterfeiter . HealthHistoryReporter
+
+type HealthHistoryReporter interface {
+	History() map[string][]healthiness.HealthCheckResult
+}
+
+// HealthHistoryHandler reports the last check results of every tracked ip,
+// or only of the ip given as the ip parameter.
+type HealthHistoryHandler struct {
+	reporter HealthHistoryReporter
+}
+
+type ipHealthHistory struct {
+	IP      string                `json:"ip"`
+	History []healthHistoryResult `json:"history"`
+}
+
+type healthHistoryResult struct {
+	Timestamp time.Time                `json:"timestamp"`
+	Status    healthiness.HealthStatus `json:"status"`
+	Latency   string                   `json:"latency"`
+	Error     string                   `json:"error,omitempty"`
+}
+
+func NewHealthHistoryHandler(reporter HealthHistoryReporter) HealthHistoryHandler {
+	return HealthHistoryHandler{reporter: reporter}
+}
+
+func (h HealthHistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	history := h.reporter.History()
+	selected := r.URL.Query().Get("ip")
+
+	ips := []string{}
+	for ip := range history {
+		if selected == "" || ip == selected {
+			ips = append(ips, ip)
+		}
+	}
+
+	sort.Strings(ips)
+
+	response := struct {
+		IPs []ipHealthHistory `json:"ips"`
+	}{IPs: []ipHealthHistory{}}
+
+	for _, ip := range ips {
+		results := []healthHistoryResult{}
+		for _, result := range history[ip] {
+			results = append(results, healthHistoryResult{
+				Timestamp: result.Timestamp,
+				Status:    result.Status,
+				Latency:   result.Latency.String(),
+				Error:     result.Error,
+			})
+		}
+
+		response.IPs = append(response.IPs, ipHealthHistory{IP: ip, History: results})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
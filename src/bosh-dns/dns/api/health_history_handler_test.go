@@ -0,0 +1,91 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"bosh-dns/dns/api"
+	"bosh-dns/dns/api/apifakes"
+	"bosh-dns/dns/server/healthiness"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HealthHistoryHandler", func() {
+	var (
+		fakeReporter *apifakes.FakeHealthHistoryReporter
+		handler      api.HealthHistoryHandler
+		recorder     *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		fakeReporter = &apifakes.FakeHealthHistoryReporter{}
+		handler = api.NewHealthHistoryHandler(fakeReporter)
+		recorder = httptest.NewRecorder()
+
+		checkedAt := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+		fakeReporter.HistoryReturns(map[string][]healthiness.HealthCheckResult{
+			"10.0.0.2": {
+				{Timestamp: checkedAt, Status: healthiness.StatusHealthy, Latency: 3 * time.Millisecond},
+				{Timestamp: checkedAt.Add(time.Second), Status: healthiness.StatusUnhealthy, Latency: 5 * time.Second, Error: "timed out"},
+			},
+			"10.0.0.1": {
+				{Timestamp: checkedAt, Status: healthiness.StatusDegraded, Latency: 2 * time.Millisecond},
+			},
+		})
+	})
+
+	It("reports the history of every tracked ip sorted by ip", func() {
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/health/history", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(recorder.Body.String()).To(MatchJSON(`{
+			"ips": [
+				{
+					"ip": "10.0.0.1",
+					"history": [
+						{"timestamp": "2018-01-02T03:04:05Z", "status": "degraded", "latency": "2ms"}
+					]
+				},
+				{
+					"ip": "10.0.0.2",
+					"history": [
+						{"timestamp": "2018-01-02T03:04:05Z", "status": "healthy", "latency": "3ms"},
+						{"timestamp": "2018-01-02T03:04:06Z", "status": "unhealthy", "latency": "5s", "error": "timed out"}
+					]
+				}
+			]
+		}`))
+	})
+
+	It("reports only the history of the selected ip", func() {
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/health/history?ip=10.0.0.1", nil))
+
+		Expect(recorder.Body.String()).To(MatchJSON(`{
+			"ips": [
+				{
+					"ip": "10.0.0.1",
+					"history": [
+						{"timestamp": "2018-01-02T03:04:05Z", "status": "degraded", "latency": "2ms"}
+					]
+				}
+			]
+		}`))
+	})
+
+	It("reports an empty list for unknown ips", func() {
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/health/history?ip=10.0.0.3", nil))
+
+		Expect(recorder.Body.String()).To(MatchJSON(`{"ips": []}`))
+	})
+
+	It("rejects other methods", func() {
+		handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/health/history", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(fakeReporter.HistoryCallCount()).To(Equal(0))
+	})
+})
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"bosh-dns/dns/server/handlers"
+)
+
+//go:generate counterfeiter . HedgingReporter
+
+type HedgingReporter interface {
+	Report() map[string]handlers.HedgingReport
+}
+
+// RecursorHedgingHandler reports per recursor pool how often queries to the
+// recursors were hedged, and how often the hedged query answered first.
+type RecursorHedgingHandler struct {
+	reporter HedgingReporter
+}
+
+func NewRecursorHedgingHandler(reporter HedgingReporter) RecursorHedgingHandler {
+	return RecursorHedgingHandler{reporter: reporter}
+}
+
+func (h RecursorHedgingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.reporter.Report())
+}
@@ -0,0 +1,67 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"bosh-dns/dns/api"
+	"bosh-dns/dns/api/apifakes"
+	"bosh-dns/dns/server/handlers"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RecursorHedgingHandler", func() {
+	var (
+		fakeReporter *apifakes.FakeHedgingReporter
+		handler      api.RecursorHedgingHandler
+		recorder     *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		fakeReporter = &apifakes.FakeHedgingReporter{}
+		handler = api.NewRecursorHedgingHandler(fakeReporter)
+		recorder = httptest.NewRecorder()
+
+		fakeReporter.ReportReturns(map[string]handlers.HedgingReport{
+			"default": {
+				Queries:       100,
+				Hedged:        12,
+				HedgeWins:     7,
+				HedgesSkipped: 2,
+			},
+			"corp.example.com.": {
+				Queries: 3,
+				Hedged:  1,
+			},
+		})
+	})
+
+	It("reports the hedging metrics of each recursor pool", func() {
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/recursors/hedging", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(recorder.Body.String()).To(MatchJSON(`{
+			"default": {
+				"queries": 100,
+				"hedged": 12,
+				"hedge_wins": 7,
+				"hedges_skipped": 2
+			},
+			"corp.example.com.": {
+				"queries": 3,
+				"hedged": 1,
+				"hedge_wins": 0,
+				"hedges_skipped": 0
+			}
+		}`))
+	})
+
+	It("rejects other methods", func() {
+		handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/recursors/hedging", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net"
+	"net/http"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+const logTag = "api"
+
+// Server serves read-only introspection endpoints. It is meant to be bound to
+// the loopback interface only and therefore does not authenticate requests.
+type Server struct {
+	address string
+	mux     *http.ServeMux
+	logger  boshlog.Logger
+}
+
+func NewServer(address string, logger boshlog.Logger) *Server {
+	return &Server{
+		address: address,
+		mux:     http.NewServeMux(),
+		logger:  logger,
+	}
+}
+
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+func (s *Server) Run(shutdown chan struct{}) error {
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: s.mux}
+
+	go func() {
+		<-shutdown
+		server.Close()
+	}()
+
+	s.logger.Info(logTag, "serving introspection api on %s", listener.Addr().String())
+
+	err = server.Serve(listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+
+	return err
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -16,13 +17,59 @@ type Config struct {
 	Timeout           DurationJSON
 	RecursorTimeout   DurationJSON `json:"recursor_timeout"`
 	Recursors         []string
-	RecordsFile       string   `json:"records_file"`
-	AliasFilesGlob    string   `json:"alias_files_glob"`
-	HandlersFilesGlob string   `json:"handlers_files_glob"`
-	UpcheckDomains    []string `json:"upcheck_domains"`
+	RecursorTLS       RecursorTLSConfig `json:"recursor_tls"`
+	RecordsFile       string            `json:"records_file"`
+	AliasFilesGlob    string            `json:"alias_files_glob"`
+	HandlersFilesGlob string            `json:"handlers_files_glob"`
+	UpcheckDomains    []string          `json:"upcheck_domains"`
+
+	// RecursorSelection is the strategy to choose the recursor that is
+	// queried first. With RecursorSelectionLatency, another recursor is
+	// probed every RecursorProbeInterval. With RecursorSelectionHedged, the
+	// next recursor is also queried after RecursorHedgeDelay, with at most
+	// RecursorMaxHedges of these queries in flight; 0 disables hedging.
+	RecursorSelection     string       `json:"recursor_selection"`
+	RecursorProbeInterval DurationJSON `json:"recursor_probe_interval"`
+	RecursorHedgeDelay    DurationJSON `json:"recursor_hedge_delay"`
+	RecursorMaxHedges     int          `json:"recursor_max_hedges"`
 
 	Health HealthConfig `json:"health"`
 	Cache  Cache        `json:"cache"`
+	API    APIConfig    `json:"api"`
+}
+
+const (
+	// RecursorSelectionFailover keeps querying the same recursor until too
+	// many of its recent queries failed.
+	RecursorSelectionFailover = "failover"
+
+	// RecursorSelectionLatency queries the recursor with the lowest moving
+	// average of its latency and error rate.
+	RecursorSelectionLatency = "latency"
+
+	// RecursorSelectionHedged also queries the next recursor when the
+	// preferred one does not answer quickly, and takes the first answer.
+	RecursorSelectionHedged = "hedged"
+)
+
+// TLSRecursorScheme prefixes the addresses of DNS-over-TLS recursors, e.g.
+// tls://1.1.1.1:853. The name verified against the certificate of the
+// recursor may follow the address, e.g. tls://1.1.1.1:853#cloudflare-dns.com.
+const TLSRecursorScheme = "tls://"
+
+// HTTPSRecursorScheme prefixes the URLs of DNS-over-HTTPS recursors, e.g.
+// https://dns.google/dns-query. Queries are POSTed to the URL, unless it
+// ends in the {?dns} template of RFC 8484, in which case they are sent as
+// GET requests.
+const HTTPSRecursorScheme = "https://"
+
+// RecursorTLSConfig verifies the certificates of DNS-over-TLS recursors
+// against the CAs in CAFile, or the system CAs when it is not set. Recursors
+// without a name in their address are verified against ServerName, or their
+// host when it is not set either.
+type RecursorTLSConfig struct {
+	CAFile     string `json:"ca_file"`
+	ServerName string `json:"server_name"`
 }
 
 type HealthConfig struct {
@@ -31,8 +78,109 @@ type HealthConfig struct {
 	CertificateFile   string       `json:"certificate_file"`
 	PrivateKeyFile    string       `json:"private_key_file"`
 	CAFile            string       `json:"ca_file"`
+	ServerIdentity    string       `json:"server_identity"`
 	CheckInterval     DurationJSON `json:"check_interval"`
 	MaxTrackedQueries int          `json:"max_tracked_queries"`
+
+	// Checks due on a tick are spread across CheckInterval, each delayed by
+	// up to CheckJitter, and run by at most Workers at a time. Checks of the
+	// bosh-dns health server are attempted CheckAttempts times.
+	Workers         int          `json:"workers"`
+	CheckJitter     DurationJSON `json:"check_jitter"`
+	CheckTimeout    DurationJSON `json:"check_timeout"`
+	CheckAttempts   int          `json:"check_attempts"`
+	CheckRetryDelay DurationJSON `json:"check_retry_delay"`
+
+	MaxCheckInterval   DurationJSON `json:"max_check_interval"`
+	HealthyThreshold   int          `json:"healthy_threshold"`
+	UnhealthyThreshold int          `json:"unhealthy_threshold"`
+
+	// DefaultHealth decides how IPs which have not been checked yet are
+	// answered: as healthy, after waiting up to DefaultHealthWait for their
+	// first check, or ranked below IPs known to be healthy.
+	DefaultHealth     string       `json:"default_health"`
+	DefaultHealthWait DurationJSON `json:"default_health_wait"`
+
+	// The last HistorySize check results of every IP are kept. IPs changing
+	// their state more than FlappingThreshold times within FlappingWindow are
+	// flapping, and are held unhealthy until they are stable again when
+	// HoldFlapping is set. A FlappingThreshold of 0 disables the detection.
+	HistorySize       int          `json:"history_size"`
+	FlappingThreshold int          `json:"flapping_threshold"`
+	FlappingWindow    DurationJSON `json:"flapping_window"`
+	HoldFlapping      bool         `json:"hold_flapping"`
+
+	Checks []HealthCheckConfig `json:"checks"`
+
+	TrackAll TrackAllConfig `json:"track_all"`
+
+	SharedObservations SharedObservationsConfig `json:"shared_observations"`
+
+	// StateFile persists the health of tracked domains across restarts
+	// when set. Snapshots older than StateMaxAge are not restored.
+	StateFile             string       `json:"state_file"`
+	StateSnapshotInterval DurationJSON `json:"state_snapshot_interval"`
+	StateMaxAge           DurationJSON `json:"state_max_age"`
+}
+
+// TrackAllConfig checks the health of every record, or only of the selected
+// deployments and instance groups, before they are queried. At most Rate new
+// IPs per second are added to the checks.
+type TrackAllConfig struct {
+	Enabled   bool                  `json:"enabled"`
+	Selectors []TrackSelectorConfig `json:"selectors"`
+	Rate      int                   `json:"rate"`
+}
+
+// SharedObservationsConfig shares checks of the bosh-dns health server with
+// peers. When serving, the results of local checks are written to File for
+// the health server to serve. When consuming, the observations served by the
+// instances of Peers replace local checks once Quorum of them agree, but every
+// IP is still checked locally each LocalCheckInterval. Observations older
+// than MaxAge are ignored.
+type SharedObservationsConfig struct {
+	Serve              bool         `json:"serve"`
+	File               string       `json:"file"`
+	Consume            bool         `json:"consume"`
+	Peers              []string     `json:"peers"`
+	Quorum             int          `json:"quorum"`
+	MaxAge             DurationJSON `json:"max_age"`
+	LocalCheckInterval DurationJSON `json:"local_check_interval"`
+}
+
+// Modes deciding the health of IPs which have not been checked yet.
+const (
+	DefaultHealthHealthy     = "healthy"
+	DefaultHealthWait        = "wait"
+	DefaultHealthLowPriority = "low-priority"
+)
+
+type TrackSelectorConfig struct {
+	Deployment    string `json:"deployment"`
+	InstanceGroup string `json:"instance_group"`
+}
+
+const (
+	HealthCheckTypeTCP  = "tcp"
+	HealthCheckTypeHTTP = "http"
+	HealthCheckTypeGRPC = "grpc"
+)
+
+// HealthCheckConfig overrides the bosh-dns health server check for the IPs
+// resolved for a domain or alias. Wildcard domains (`*.example.com`) apply to
+// all of their subdomains.
+type HealthCheckConfig struct {
+	Domain         string       `json:"domain"`
+	Type           string       `json:"type"`
+	Port           int          `json:"port"`
+	Path           string       `json:"path"`
+	ExpectedStatus int          `json:"expected_status"`
+	Service        string       `json:"service"`
+	Timeout        DurationJSON `json:"timeout"`
+}
+
+type APIConfig struct {
+	Port int `json:"port"`
 }
 
 type Cache struct {
@@ -70,10 +218,36 @@ func LoadFromFile(configFilePath string) (Config, error) {
 	}
 
 	c := Config{
-		Timeout:         DurationJSON(5 * time.Second),
-		RecursorTimeout: DurationJSON(2 * time.Second),
+		Timeout:               DurationJSON(5 * time.Second),
+		RecursorTimeout:       DurationJSON(2 * time.Second),
+		RecursorSelection:     RecursorSelectionFailover,
+		RecursorProbeInterval: DurationJSON(10 * time.Second),
+		RecursorHedgeDelay:    DurationJSON(100 * time.Millisecond),
+		RecursorMaxHedges:     100,
 		Health: HealthConfig{
-			MaxTrackedQueries: 2000,
+			MaxTrackedQueries:  2000,
+			Workers:            1000,
+			ServerIdentity:     "health.bosh-dns",
+			CheckTimeout:       DurationJSON(5 * time.Second),
+			CheckAttempts:      4,
+			CheckRetryDelay:    DurationJSON(500 * time.Millisecond),
+			HealthyThreshold:   1,
+			UnhealthyThreshold: 1,
+			DefaultHealth:      DefaultHealthHealthy,
+			DefaultHealthWait:  DurationJSON(200 * time.Millisecond),
+			HistorySize:        20,
+			FlappingThreshold:  4,
+			FlappingWindow:     DurationJSON(5 * time.Minute),
+			TrackAll: TrackAllConfig{
+				Rate: 100,
+			},
+			SharedObservations: SharedObservationsConfig{
+				Quorum:             2,
+				MaxAge:             DurationJSON(time.Minute),
+				LocalCheckInterval: DurationJSON(time.Minute),
+			},
+			StateSnapshotInterval: DurationJSON(time.Minute),
+			StateMaxAge:           DurationJSON(5 * time.Minute),
 		},
 	}
 
@@ -85,6 +259,62 @@ func LoadFromFile(configFilePath string) (Config, error) {
 		return Config{}, errors.New("port is required")
 	}
 
+	if c.Health.HealthyThreshold < 1 || c.Health.UnhealthyThreshold < 1 {
+		return Config{}, errors.New("health thresholds must be at least 1")
+	}
+
+	if c.Health.Workers < 1 || c.Health.CheckAttempts < 1 {
+		return Config{}, errors.New("health workers and check attempts must be at least 1")
+	}
+
+	if c.Health.HistorySize < 0 || c.Health.FlappingThreshold < 0 {
+		return Config{}, errors.New("health history_size and flapping_threshold must not be negative")
+	}
+
+	if c.Health.TrackAll.Rate < 1 {
+		return Config{}, errors.New("health track_all rate must be at least 1")
+	}
+
+	if c.Health.SharedObservations.Quorum < 1 {
+		return Config{}, errors.New("health shared_observations quorum must be at least 1")
+	}
+
+	sharing := c.Health.SharedObservations.Serve || c.Health.SharedObservations.Consume
+	if sharing && c.Health.SharedObservations.MaxAge < c.Health.SharedObservations.LocalCheckInterval {
+		return Config{}, errors.New("health shared_observations max_age must be at least local_check_interval")
+	}
+
+	switch c.RecursorSelection {
+	case RecursorSelectionFailover, RecursorSelectionLatency, RecursorSelectionHedged:
+	default:
+		return Config{}, fmt.Errorf("recursor_selection has unknown strategy '%s'", c.RecursorSelection)
+	}
+
+	if c.RecursorProbeInterval <= 0 {
+		return Config{}, errors.New("recursor_probe_interval must be positive")
+	}
+
+	if c.RecursorHedgeDelay <= 0 {
+		return Config{}, errors.New("recursor_hedge_delay must be positive")
+	}
+
+	if c.RecursorMaxHedges < 0 {
+		return Config{}, errors.New("recursor_max_hedges must not be negative")
+	}
+
+	switch c.Health.DefaultHealth {
+	case DefaultHealthHealthy, DefaultHealthWait, DefaultHealthLowPriority:
+	default:
+		return Config{}, fmt.Errorf("health default_health has unknown mode '%s'", c.Health.DefaultHealth)
+	}
+
+	for i := range c.Health.Checks {
+		err = c.Health.Checks[i].applyDefaults()
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
 	c.Recursors, err = AppendDefaultDNSPortIfMissing(c.Recursors)
 	if err != nil {
 		return Config{}, err
@@ -93,19 +323,83 @@ func LoadFromFile(configFilePath string) (Config, error) {
 	return c, nil
 }
 
+func (c *HealthCheckConfig) applyDefaults() error {
+	if c.Domain == "" {
+		return errors.New("health check domain is required")
+	}
+
+	switch c.Type {
+	case HealthCheckTypeTCP, HealthCheckTypeGRPC:
+	case HealthCheckTypeHTTP:
+		if c.Path == "" {
+			c.Path = "/"
+		}
+
+		if c.ExpectedStatus == 0 {
+			c.ExpectedStatus = 200
+		}
+	default:
+		return fmt.Errorf("health check for %s has unknown type '%s'", c.Domain, c.Type)
+	}
+
+	if c.Port <= 0 {
+		return fmt.Errorf("health check for %s requires a port", c.Domain)
+	}
+
+	if c.Timeout == 0 {
+		c.Timeout = DurationJSON(5 * time.Second)
+	}
+
+	return nil
+}
+
 func AppendDefaultDNSPortIfMissing(recursors []string) ([]string, error) {
 	recursorsWithPort := []string{}
 	for i := range recursors {
-		_, _, err := net.SplitHostPort(recursors[i])
+		recursor, err := appendDefaultDNSPortIfMissing(recursors[i])
 		if err != nil {
-			if strings.Contains(err.Error(), "missing port in address") {
-				recursorsWithPort = append(recursorsWithPort, net.JoinHostPort(recursors[i], "53"))
-			} else {
-				return []string{}, err
-			}
-		} else {
-			recursorsWithPort = append(recursorsWithPort, recursors[i])
+			return []string{}, err
 		}
+
+		recursorsWithPort = append(recursorsWithPort, recursor)
 	}
 	return recursorsWithPort, nil
 }
+
+func appendDefaultDNSPortIfMissing(recursor string) (string, error) {
+	if strings.HasPrefix(recursor, HTTPSRecursorScheme) {
+		recursorURL, err := url.Parse(strings.Replace(recursor, "{?dns}", "", 1))
+		if err != nil {
+			return "", err
+		}
+
+		if recursorURL.Host == "" {
+			return "", fmt.Errorf("recursor '%s' has no host", recursor)
+		}
+
+		return recursor, nil
+	}
+
+	scheme, defaultPort := "", "53"
+	if strings.HasPrefix(recursor, TLSRecursorScheme) {
+		scheme, defaultPort = TLSRecursorScheme, "853"
+	} else if strings.Contains(recursor, "://") {
+		return "", fmt.Errorf("recursor '%s' has an unsupported scheme", recursor)
+	}
+
+	address, serverName := strings.TrimPrefix(recursor, scheme), ""
+	if index := strings.Index(address, "#"); scheme != "" && index >= 0 {
+		address, serverName = address[:index], address[index:]
+	}
+
+	_, _, err := net.SplitHostPort(address)
+	if err != nil {
+		if !strings.Contains(err.Error(), "missing port in address") {
+			return "", err
+		}
+
+		address = net.JoinHostPort(strings.TrimSuffix(strings.TrimPrefix(address, "["), "]"), defaultPort)
+	}
+
+	return scheme + address + serverName, nil
+}
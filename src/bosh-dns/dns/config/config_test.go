@@ -12,6 +12,7 @@ import (
 	"bosh-dns/dns/config"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 )
 
@@ -53,21 +54,62 @@ var _ = Describe("Config", func() {
 
 	It("returns config from a config file", func() {
 		configContents, err := json.Marshal(map[string]interface{}{
-			"address":             listenAddress,
-			"port":                listenPort,
-			"timeout":             timeout,
-			"recursor_timeout":    recursorTimeout,
-			"upcheck_domains":     upcheckDomains,
-			"alias_files_glob":    aliasesFileGlob,
-			"handlers_files_glob": handlersFileGlob,
+			"address":                 listenAddress,
+			"port":                    listenPort,
+			"timeout":                 timeout,
+			"recursor_timeout":        recursorTimeout,
+			"upcheck_domains":         upcheckDomains,
+			"alias_files_glob":        aliasesFileGlob,
+			"handlers_files_glob":     handlersFileGlob,
+			"recursor_selection":      "latency",
+			"recursor_probe_interval": "30s",
+			"recursor_hedge_delay":    "50ms",
+			"recursor_max_hedges":     20,
 			"health": map[string]interface{}{
-				"enabled":             true,
-				"port":                healthPort,
-				"certificate_file":    healthCertificateFile,
-				"private_key_file":    healthPrivateKeyFile,
-				"ca_file":             healthCAFile,
-				"check_interval":      upcheckInterval,
-				"max_tracked_queries": healthMaxTrackedQueries,
+				"enabled":                 true,
+				"port":                    healthPort,
+				"certificate_file":        healthCertificateFile,
+				"private_key_file":        healthPrivateKeyFile,
+				"ca_file":                 healthCAFile,
+				"server_identity":         "health.dns.internal",
+				"check_interval":          upcheckInterval,
+				"max_tracked_queries":     healthMaxTrackedQueries,
+				"max_check_interval":      "5m",
+				"workers":                 50,
+				"check_jitter":            "2s",
+				"check_timeout":           "1s",
+				"check_attempts":          2,
+				"check_retry_delay":       "100ms",
+				"healthy_threshold":       2,
+				"unhealthy_threshold":     3,
+				"default_health":          "wait",
+				"default_health_wait":     "100ms",
+				"history_size":            10,
+				"flapping_threshold":      3,
+				"flapping_window":         "2m",
+				"hold_flapping":           true,
+				"state_file":              "/var/vcap/data/bosh-dns/health-state.json",
+				"state_snapshot_interval": "30s",
+				"state_max_age":           "10m",
+				"track_all": map[string]interface{}{
+					"enabled": true,
+					"selectors": []map[string]interface{}{
+						{"deployment": "cf", "instance_group": "router"},
+					},
+					"rate": 20,
+				},
+				"shared_observations": map[string]interface{}{
+					"serve":                true,
+					"file":                 "/var/vcap/data/bosh-dns/health-observations.json",
+					"consume":              true,
+					"peers":                []string{"q-s0.bosh-dns.default.dep.bosh."},
+					"quorum":               3,
+					"max_age":              "2m",
+					"local_check_interval": "30s",
+				},
+			},
+			"api": map[string]interface{}{
+				"port": 53080,
 			},
 			"cache": map[string]interface{}{
 				"enabled": true,
@@ -97,26 +139,65 @@ var _ = Describe("Config", func() {
 		dnsConfig, err := config.LoadFromFile(configFilePath)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(dnsConfig).To(Equal(config.Config{
-			Address:           listenAddress,
-			Port:              listenPort,
-			Timeout:           config.DurationJSON(timeoutDuration),
-			RecursorTimeout:   config.DurationJSON(recursorTimeoutDuration),
-			Recursors:         []string{},
-			UpcheckDomains:    []string{"upcheck.domain.", "health2.bosh."},
-			AliasFilesGlob:    aliasesFileGlob,
-			HandlersFilesGlob: handlersFileGlob,
+			Address:               listenAddress,
+			Port:                  listenPort,
+			Timeout:               config.DurationJSON(timeoutDuration),
+			RecursorTimeout:       config.DurationJSON(recursorTimeoutDuration),
+			Recursors:             []string{},
+			UpcheckDomains:        []string{"upcheck.domain.", "health2.bosh."},
+			AliasFilesGlob:        aliasesFileGlob,
+			HandlersFilesGlob:     handlersFileGlob,
+			RecursorSelection:     "latency",
+			RecursorProbeInterval: config.DurationJSON(30 * time.Second),
+			RecursorHedgeDelay:    config.DurationJSON(50 * time.Millisecond),
+			RecursorMaxHedges:     20,
 			Health: config.HealthConfig{
-				Enabled:           true,
-				Port:              healthPort,
-				CertificateFile:   healthCertificateFile,
-				PrivateKeyFile:    healthPrivateKeyFile,
-				CAFile:            healthCAFile,
-				CheckInterval:     config.DurationJSON(upcheckIntervalDuration),
-				MaxTrackedQueries: healthMaxTrackedQueries,
+				Enabled:               true,
+				Port:                  healthPort,
+				CertificateFile:       healthCertificateFile,
+				PrivateKeyFile:        healthPrivateKeyFile,
+				CAFile:                healthCAFile,
+				ServerIdentity:        "health.dns.internal",
+				CheckInterval:         config.DurationJSON(upcheckIntervalDuration),
+				MaxTrackedQueries:     healthMaxTrackedQueries,
+				MaxCheckInterval:      config.DurationJSON(5 * time.Minute),
+				Workers:               50,
+				CheckJitter:           config.DurationJSON(2 * time.Second),
+				CheckTimeout:          config.DurationJSON(time.Second),
+				CheckAttempts:         2,
+				CheckRetryDelay:       config.DurationJSON(100 * time.Millisecond),
+				HealthyThreshold:      2,
+				UnhealthyThreshold:    3,
+				DefaultHealth:         "wait",
+				DefaultHealthWait:     config.DurationJSON(100 * time.Millisecond),
+				HistorySize:           10,
+				FlappingThreshold:     3,
+				FlappingWindow:        config.DurationJSON(2 * time.Minute),
+				HoldFlapping:          true,
+				StateFile:             "/var/vcap/data/bosh-dns/health-state.json",
+				StateSnapshotInterval: config.DurationJSON(30 * time.Second),
+				StateMaxAge:           config.DurationJSON(10 * time.Minute),
+				TrackAll: config.TrackAllConfig{
+					Enabled:   true,
+					Selectors: []config.TrackSelectorConfig{{Deployment: "cf", InstanceGroup: "router"}},
+					Rate:      20,
+				},
+				SharedObservations: config.SharedObservationsConfig{
+					Serve:              true,
+					File:               "/var/vcap/data/bosh-dns/health-observations.json",
+					Consume:            true,
+					Peers:              []string{"q-s0.bosh-dns.default.dep.bosh."},
+					Quorum:             3,
+					MaxAge:             config.DurationJSON(2 * time.Minute),
+					LocalCheckInterval: config.DurationJSON(30 * time.Second),
+				},
 			},
 			Cache: config.Cache{
 				Enabled: true,
 			},
+			API: config.APIConfig{
+				Port: 53080,
+			},
 		}))
 	})
 
@@ -151,6 +232,57 @@ var _ = Describe("Config", func() {
 		})
 	})
 
+	Context("recursor_selection", func() {
+		It("defaults to failing over with a probe interval of 10s", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53}`)
+
+			dnsConfig, err := config.LoadFromFile(configFilePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(dnsConfig.RecursorSelection).To(Equal(config.RecursorSelectionFailover))
+			Expect(dnsConfig.RecursorProbeInterval).To(Equal(config.DurationJSON(10 * time.Second)))
+		})
+
+		It("defaults to hedging after 100ms with at most 100 hedged queries", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "recursor_selection": "hedged"}`)
+
+			dnsConfig, err := config.LoadFromFile(configFilePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(dnsConfig.RecursorSelection).To(Equal(config.RecursorSelectionHedged))
+			Expect(dnsConfig.RecursorHedgeDelay).To(Equal(config.DurationJSON(100 * time.Millisecond)))
+			Expect(dnsConfig.RecursorMaxHedges).To(Equal(100))
+		})
+
+		It("returns an error if the hedge delay is not positive", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "recursor_hedge_delay": "0s"}`)
+
+			_, err := config.LoadFromFile(configFilePath)
+			Expect(err).To(MatchError("recursor_hedge_delay must be positive"))
+		})
+
+		It("returns an error if the max hedges is negative", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "recursor_max_hedges": -1}`)
+
+			_, err := config.LoadFromFile(configFilePath)
+			Expect(err).To(MatchError("recursor_max_hedges must not be negative"))
+		})
+
+		It("returns an error for an unknown strategy", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "recursor_selection": "random"}`)
+
+			_, err := config.LoadFromFile(configFilePath)
+			Expect(err).To(MatchError("recursor_selection has unknown strategy 'random'"))
+		})
+
+		It("returns an error if the probe interval is not positive", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "recursor_probe_interval": "0s"}`)
+
+			_, err := config.LoadFromFile(configFilePath)
+			Expect(err).To(MatchError("recursor_probe_interval must be positive"))
+		})
+	})
+
 	Context("records_file", func() {
 		It("allows configuring the path", func() {
 			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "records_file": "/some/path"}`)
@@ -161,6 +293,17 @@ var _ = Describe("Config", func() {
 		})
 	})
 
+	Context("health.server_identity", func() {
+		It("defaults to health.bosh-dns", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53}`)
+
+			dnsConfig, err := config.LoadFromFile(configFilePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(dnsConfig.Health.ServerIdentity).To(Equal("health.bosh-dns"))
+		})
+	})
+
 	Context("health.max_tracked_queries", func() {
 		It("defaults to 2000", func() {
 			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53}`)
@@ -172,6 +315,189 @@ var _ = Describe("Config", func() {
 		})
 	})
 
+	Context("health check scheduling", func() {
+		It("defaults the workers and the retry policy", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53}`)
+
+			dnsConfig, err := config.LoadFromFile(configFilePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(dnsConfig.Health.Workers).To(Equal(1000))
+			Expect(dnsConfig.Health.CheckJitter).To(Equal(config.DurationJSON(0)))
+			Expect(dnsConfig.Health.CheckTimeout).To(Equal(config.DurationJSON(5 * time.Second)))
+			Expect(dnsConfig.Health.CheckAttempts).To(Equal(4))
+			Expect(dnsConfig.Health.CheckRetryDelay).To(Equal(config.DurationJSON(500 * time.Millisecond)))
+		})
+
+		It("returns error if there are no workers or attempts", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "health": {"check_attempts": 0}}`)
+
+			_, err := config.LoadFromFile(configFilePath)
+			Expect(err).To(MatchError("health workers and check attempts must be at least 1"))
+		})
+	})
+
+	Context("health thresholds", func() {
+		It("defaults to flipping state after a single result", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53}`)
+
+			dnsConfig, err := config.LoadFromFile(configFilePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(dnsConfig.Health.HealthyThreshold).To(Equal(1))
+			Expect(dnsConfig.Health.UnhealthyThreshold).To(Equal(1))
+		})
+
+		It("returns error if a threshold is less than 1", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "health": {"unhealthy_threshold": 0}}`)
+
+			_, err := config.LoadFromFile(configFilePath)
+			Expect(err).To(MatchError("health thresholds must be at least 1"))
+		})
+	})
+
+	Context("default health", func() {
+		It("defaults to treating unchecked ips as healthy", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53}`)
+
+			dnsConfig, err := config.LoadFromFile(configFilePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(dnsConfig.Health.DefaultHealth).To(Equal("healthy"))
+			Expect(dnsConfig.Health.DefaultHealthWait).To(Equal(config.DurationJSON(200 * time.Millisecond)))
+		})
+
+		It("returns error if the mode is unknown", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "health": {"default_health": "maybe"}}`)
+
+			_, err := config.LoadFromFile(configFilePath)
+			Expect(err).To(MatchError("health default_health has unknown mode 'maybe'"))
+		})
+	})
+
+	Context("tracking all records", func() {
+		It("is disabled by default", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53}`)
+
+			dnsConfig, err := config.LoadFromFile(configFilePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(dnsConfig.Health.TrackAll).To(Equal(config.TrackAllConfig{Rate: 100}))
+		})
+
+		It("returns error if the rate is less than 1", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "health": {"track_all": {"enabled": true, "rate": 0}}}`)
+
+			_, err := config.LoadFromFile(configFilePath)
+			Expect(err).To(MatchError("health track_all rate must be at least 1"))
+		})
+	})
+
+	Context("shared health observations", func() {
+		It("is disabled by default", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53}`)
+
+			dnsConfig, err := config.LoadFromFile(configFilePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(dnsConfig.Health.SharedObservations).To(Equal(config.SharedObservationsConfig{
+				Quorum:             2,
+				MaxAge:             config.DurationJSON(time.Minute),
+				LocalCheckInterval: config.DurationJSON(time.Minute),
+			}))
+		})
+
+		It("returns error if the quorum is less than 1", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "health": {"shared_observations": {"quorum": 0}}}`)
+
+			_, err := config.LoadFromFile(configFilePath)
+			Expect(err).To(MatchError("health shared_observations quorum must be at least 1"))
+		})
+
+		It("returns error if the maximum age is shorter than the local check interval", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "health": {"shared_observations": {"consume": true, "max_age": "30s", "local_check_interval": "1m"}}}`)
+
+			_, err := config.LoadFromFile(configFilePath)
+			Expect(err).To(MatchError("health shared_observations max_age must be at least local_check_interval"))
+
+			configFilePath = writeConfigFile(`{"address": "127.0.0.1", "port": 53, "health": {"shared_observations": {"serve": true, "max_age": "30s", "local_check_interval": "1m"}}}`)
+
+			_, err = config.LoadFromFile(configFilePath)
+			Expect(err).To(MatchError("health shared_observations max_age must be at least local_check_interval"))
+		})
+
+		It("does not compare the maximum age with the local check interval when disabled", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "health": {"shared_observations": {"max_age": "30s", "local_check_interval": "1m"}}}`)
+
+			_, err := config.LoadFromFile(configFilePath)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("health history", func() {
+		It("keeps history and detects flapping by default", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53}`)
+
+			dnsConfig, err := config.LoadFromFile(configFilePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(dnsConfig.Health.HistorySize).To(Equal(20))
+			Expect(dnsConfig.Health.FlappingThreshold).To(Equal(4))
+			Expect(dnsConfig.Health.FlappingWindow).To(Equal(config.DurationJSON(5 * time.Minute)))
+			Expect(dnsConfig.Health.HoldFlapping).To(BeFalse())
+		})
+
+		It("returns error if the history size is negative", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "health": {"history_size": -1}}`)
+
+			_, err := config.LoadFromFile(configFilePath)
+			Expect(err).To(MatchError("health history_size and flapping_threshold must not be negative"))
+		})
+	})
+
+	Context("health state persistence", func() {
+		It("defaults the snapshot interval and maximum age", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53}`)
+
+			dnsConfig, err := config.LoadFromFile(configFilePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(dnsConfig.Health.StateFile).To(Equal(""))
+			Expect(dnsConfig.Health.StateSnapshotInterval).To(Equal(config.DurationJSON(time.Minute)))
+			Expect(dnsConfig.Health.StateMaxAge).To(Equal(config.DurationJSON(5 * time.Minute)))
+		})
+	})
+
+	Context("health checks", func() {
+		It("applies defaults per check type", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "health": {"checks": [
+				{"domain": "db.internal", "type": "tcp", "port": 5432},
+				{"domain": "*.apps.internal", "type": "http", "port": 8080},
+				{"domain": "api.internal", "type": "grpc", "port": 9090, "service": "api", "timeout": "1s"}
+			]}}`)
+
+			dnsConfig, err := config.LoadFromFile(configFilePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(dnsConfig.Health.Checks).To(Equal([]config.HealthCheckConfig{
+				{Domain: "db.internal", Type: "tcp", Port: 5432, Timeout: config.DurationJSON(5 * time.Second)},
+				{Domain: "*.apps.internal", Type: "http", Port: 8080, Path: "/", ExpectedStatus: 200, Timeout: config.DurationJSON(5 * time.Second)},
+				{Domain: "api.internal", Type: "grpc", Port: 9090, Service: "api", Timeout: config.DurationJSON(time.Second)},
+			}))
+		})
+
+		DescribeTable("rejects invalid checks", func(check, expectedErr string) {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "health": {"checks": [` + check + `]}}`)
+
+			_, err := config.LoadFromFile(configFilePath)
+			Expect(err).To(MatchError(expectedErr))
+		},
+			Entry("missing domain", `{"type": "tcp", "port": 1}`, "health check domain is required"),
+			Entry("unknown type", `{"domain": "a", "type": "udp", "port": 1}`, "health check for a has unknown type 'udp'"),
+			Entry("missing port", `{"domain": "a", "type": "tcp"}`, "health check for a requires a port"),
+		)
+	})
+
 	Context("timeout", func() {
 		It("defaults timeout when not specified", func() {
 			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53}`)
@@ -227,6 +553,58 @@ var _ = Describe("Config", func() {
 			Expect(err.Error()).To(ContainSubstring("too many colons in address"))
 			Expect(err.Error()).To(ContainSubstring("::::::::::::"))
 		})
+
+		It("allows DNS-over-TLS recursors with a default port of 853", func() {
+			configFilePath := writeConfigFile(`{
+				"address": "127.0.0.1",
+				"port": 53,
+				"recursors": ["tls://1.1.1.1", "tls://9.9.9.9:8853", "tls://[2606:4700::1111]#cloudflare-dns.com"],
+				"recursor_tls": {"ca_file": "/var/vcap/jobs/bosh-dns/config/recursor_ca.crt", "server_name": "dns.example.com"}
+			}`)
+
+			dnsConfig, err := config.LoadFromFile(configFilePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(dnsConfig.Recursors).To(Equal([]string{
+				"tls://1.1.1.1:853",
+				"tls://9.9.9.9:8853",
+				"tls://[2606:4700::1111]:853#cloudflare-dns.com",
+			}))
+			Expect(dnsConfig.RecursorTLS).To(Equal(config.RecursorTLSConfig{
+				CAFile:     "/var/vcap/jobs/bosh-dns/config/recursor_ca.crt",
+				ServerName: "dns.example.com",
+			}))
+		})
+
+		It("allows DNS-over-HTTPS recursors", func() {
+			configFilePath := writeConfigFile(`{
+				"address": "127.0.0.1",
+				"port": 53,
+				"recursors": ["https://dns.google/dns-query", "https://1.1.1.1:8443/dns-query{?dns}"]
+			}`)
+
+			dnsConfig, err := config.LoadFromFile(configFilePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(dnsConfig.Recursors).To(Equal([]string{
+				"https://dns.google/dns-query",
+				"https://1.1.1.1:8443/dns-query{?dns}",
+			}))
+		})
+
+		It("returns an error if a DNS-over-HTTPS recursor has no host", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "recursors": ["https:///dns-query"]}`)
+
+			_, err := config.LoadFromFile(configFilePath)
+			Expect(err).To(MatchError("recursor 'https:///dns-query' has no host"))
+		})
+
+		It("returns an error if the recursor has an unsupported scheme", func() {
+			configFilePath := writeConfigFile(`{"address": "127.0.0.1", "port": 53, "recursors": ["quic://1.1.1.1"]}`)
+
+			_, err := config.LoadFromFile(configFilePath)
+			Expect(err).To(MatchError("recursor 'quic://1.1.1.1' has an unsupported scheme"))
+		})
 	})
 })
 
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"bosh-dns/dns/server/aliases"
+	"bosh-dns/dns/server/records"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const lintAliasesCommand = "lint-aliases"
+
+type staticRecordsReader struct {
+	contents []byte
+}
+
+func (r staticRecordsReader) Get() ([]byte, error) {
+	return r.contents, nil
+}
+
+func (r staticRecordsReader) Subscribe() <-chan bool {
+	c := make(chan bool)
+	close(c)
+	return c
+}
+
+func lintAliasesExitCode(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet(lintAliasesCommand, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var recordsPath string
+	flags.StringVar(&recordsPath, "records", "", "optional path to a records.json used to check that targets resolve to instances")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s %s [--records records.json] aliases.json...\n", os.Args[0], lintAliasesCommand)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	logger := boshlog.NewWriterLogger(boshlog.LevelError, stderr)
+	fs := boshsys.NewOsFileSystem(logger)
+
+	var recordSet aliases.RecordSet
+	if recordsPath != "" {
+		contents, err := fs.ReadFile(recordsPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "reading records file: %s\n", err.Error())
+			return 1
+		}
+
+		recordSet, err = records.NewRecordSet(staticRecordsReader{contents: contents}, logger)
+		if err != nil {
+			fmt.Fprintf(stderr, "loading records file: %s\n", err.Error())
+			return 1
+		}
+
+		if len(recordSet.Domains()) == 0 {
+			fmt.Fprintf(stderr, "loading records file: no records found in %s\n", recordsPath)
+			return 1
+		}
+	}
+
+	problems := aliases.NewLinter(fs).Lint(flags.Args(), recordSet)
+	for _, problem := range problems {
+		fmt.Fprintln(stdout, problem.String())
+	}
+
+	if len(problems) > 0 {
+		fmt.Fprintf(stdout, "%d problem(s) found\n", len(problems))
+		return 1
+	}
+
+	fmt.Fprintln(stdout, "no problems found")
+
+	return 0
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -11,6 +15,7 @@ import (
 
 	"code.cloudfoundry.org/clock"
 
+	"bosh-dns/dns/api"
 	dnsconfig "bosh-dns/dns/config"
 	"bosh-dns/dns/server"
 	"bosh-dns/dns/server/aliases"
@@ -49,6 +54,10 @@ func main() {
 }
 
 func mainExitCode() int {
+	if len(os.Args) > 1 && os.Args[1] == lintAliasesCommand {
+		return lintAliasesExitCode(os.Args[2:], os.Stdout, os.Stderr)
+	}
+
 	logger := boshlog.NewAsyncWriterLogger(boshlog.LevelDebug, os.Stdout)
 	logTag := "main"
 	defer logger.FlushTimeout(5 * time.Second)
@@ -101,33 +110,136 @@ func mainExitCode() int {
 		return 1
 	}
 
+	shutdown := make(chan struct{})
+
+	fileReader := records.NewFileReader(config.RecordsFile, system.NewOsFileSystem(logger), clock, logger, repoUpdate)
+	recordSet, err := records.NewRecordSet(fileReader, logger)
+	aliasedRecordSet := aliases.NewAliasedRecordSet(recordSet, aliasConfiguration)
+
 	var healthWatcher healthiness.HealthWatcher = healthiness.NewNopHealthWatcher()
+	var checkAssigner healthiness.HealthCheckAssigner = healthiness.NewDomainHealthChecker(nil, nil, nil)
 	if config.Health.Enabled {
-		httpClient, err := healthclient.NewHealthClientFromFiles(config.Health.CAFile, config.Health.CertificateFile, config.Health.PrivateKeyFile, logger)
+		httpClient, err := healthclient.NewHealthClientFromFiles(
+			config.Health.CAFile,
+			config.Health.CertificateFile,
+			config.Health.PrivateKeyFile,
+			healthclient.Config{
+				Timeout:        time.Duration(config.Health.CheckTimeout),
+				MaxAttempts:    uint(config.Health.CheckAttempts),
+				RetryDelay:     time.Duration(config.Health.CheckRetryDelay),
+				ServerIdentity: config.Health.ServerIdentity,
+			},
+			logger,
+		)
 		if err != nil {
 			logger.Error(logTag, fmt.Sprintf("Unable to configure health checker %s", err.Error()))
 			return 1
 		}
-		healthChecker := healthiness.NewHealthChecker(httpClient, config.Health.Port)
-		checkInterval := time.Duration(config.Health.CheckInterval)
-		healthWatcher = healthiness.NewHealthWatcher(healthChecker, clock, checkInterval)
+		boshHealthChecker := healthiness.NewHealthChecker(httpClient, config.Health.Port)
+
+		shared := config.Health.SharedObservations
+		if shared.Serve || shared.Consume {
+			peers := []string{}
+			if shared.Consume {
+				peers = shared.Peers
+			}
+
+			ownIPs, err := localIPs()
+			if err != nil {
+				logger.Error(logTag, fmt.Sprintf("Unable to list local addresses %s", err.Error()))
+				return 1
+			}
+
+			peerHealthChecker := healthiness.NewPeerHealthChecker(boshHealthChecker, httpClient, aliasedRecordSet, config.Health.Port, clock, healthiness.PeerHealthCheckerConfig{
+				Peers:              peers,
+				LocalIPs:           ownIPs,
+				Quorum:             shared.Quorum,
+				MaxAge:             time.Duration(shared.MaxAge),
+				LocalCheckInterval: time.Duration(shared.LocalCheckInterval),
+				FetchInterval:      time.Duration(config.Health.CheckInterval),
+			}, logger)
+			boshHealthChecker = peerHealthChecker
+
+			if shared.Consume {
+				go peerHealthChecker.Run(shutdown)
+			}
+
+			if shared.Serve && shared.File != "" {
+				observationsWriter := healthiness.NewHealthSnapshotter(
+					peerHealthChecker,
+					fs,
+					shared.File,
+					clock,
+					time.Duration(config.Health.CheckInterval),
+					time.Duration(shared.MaxAge),
+					logger,
+				)
+				go observationsWriter.Run(shutdown)
+			}
+		}
+
+		domainHealthChecker := healthiness.NewDomainHealthChecker(
+			boshHealthChecker,
+			newDomainHealthCheckers(config.Health.Checks),
+			newStaticHealthCheckers(aliasConfiguration.HealthChecks()),
+		)
+		checkAssigner = domainHealthChecker
+		healthWatcher = healthiness.NewHealthWatcher(domainHealthChecker, clock, healthiness.HealthWatcherConfig{
+			CheckInterval:      time.Duration(config.Health.CheckInterval),
+			Workers:            config.Health.Workers,
+			Jitter:             time.Duration(config.Health.CheckJitter),
+			MaxCheckInterval:   time.Duration(config.Health.MaxCheckInterval),
+			HealthyThreshold:   config.Health.HealthyThreshold,
+			UnhealthyThreshold: config.Health.UnhealthyThreshold,
+			DefaultHealth:      config.Health.DefaultHealth,
+			DefaultHealthWait:  time.Duration(config.Health.DefaultHealthWait),
+			HistorySize:        config.Health.HistorySize,
+			FlappingThreshold:  config.Health.FlappingThreshold,
+			FlappingWindow:     time.Duration(config.Health.FlappingWindow),
+			HoldFlapping:       config.Health.HoldFlapping,
+		})
 	}
 
-	shutdown := make(chan struct{})
+	healthEvents := healthiness.NewHealthEventBus(100)
+	healthyRecordSet := healthiness.NewHealthyRecordSet(aliasedRecordSet, healthWatcher, checkAssigner, healthEvents, uint(config.Health.MaxTrackedQueries), shutdown)
+
+	snapshotSaved := make(chan struct{})
+	if config.Health.Enabled && config.Health.StateFile != "" {
+		snapshotter := healthiness.NewHealthSnapshotter(
+			healthyRecordSet,
+			fs,
+			config.Health.StateFile,
+			clock,
+			time.Duration(config.Health.StateSnapshotInterval),
+			time.Duration(config.Health.StateMaxAge),
+			logger,
+		)
+
+		err = snapshotter.Restore()
+		if err != nil {
+			logger.Error(logTag, fmt.Sprintf("restoring health state: %s", err.Error()))
+		}
 
-	fileReader := records.NewFileReader(config.RecordsFile, system.NewOsFileSystem(logger), clock, logger, repoUpdate)
-	recordSet, err := records.NewRecordSet(fileReader, logger)
-	aliasedRecordSet := aliases.NewAliasedRecordSet(recordSet, aliasConfiguration)
-	healthyRecordSet := healthiness.NewHealthyRecordSet(aliasedRecordSet, healthWatcher, uint(config.Health.MaxTrackedQueries), shutdown)
+		go func() {
+			snapshotter.Run(shutdown)
+			close(snapshotSaved)
+		}()
+	} else {
+		close(snapshotSaved)
+	}
 
 	localDomain := dnsresolver.NewLocalDomain(logger, healthyRecordSet, shuffle.New())
-	discoveryHandler := handlers.NewDiscoveryHandler(logger, localDomain)
-
-	handlerRegistrar := handlers.NewHandlerRegistrar(logger, clock, aliasedRecordSet, mux, discoveryHandler)
 
 	handlers.AddHandler(mux, clock, "arpa.", handlers.NewArpaHandler(logger), logger)
 
-	exchangerFactory := handlers.NewExchangerFactory(time.Duration(config.RecursorTimeout))
+	recursorTLSConfig, err := newRecursorTLSConfig(fs, config.RecursorTLS)
+	if err != nil {
+		logger.Error(logTag, fmt.Sprintf("Unable to configure DNS-over-TLS recursors: %s", err.Error()))
+		return 1
+	}
+
+	hedgingRegistry := handlers.NewHedgingRegistry()
+	exchangerFactory := handlers.NewExchangerFactory(time.Duration(config.RecursorTimeout), recursorTLSConfig)
 
 	for _, handlerConfig := range handlersConfiguration.Handlers {
 		var handler dns.Handler
@@ -140,7 +252,10 @@ func mainExitCode() int {
 				return 1
 			}
 
-			recursorPool := handlers.NewFailoverRecursorPool(stringShuffler.Shuffle(handlerConfig.Source.Recursors), logger)
+			recursorPool := newRecursorPool(config, handlerConfig.Domain, stringShuffler.Shuffle(handlerConfig.Source.Recursors), hedgingRegistry, clock, logger)
+			handler = handlers.NewForwardHandler(recursorPool, exchangerFactory, clock, logger)
+		} else if handlerConfig.Source.Type == "https" {
+			recursorPool := newRecursorPool(config, handlerConfig.Domain, []string{handlerConfig.Source.URL}, hedgingRegistry, clock, logger)
 			handler = handlers.NewForwardHandler(recursorPool, exchangerFactory, clock, logger)
 		} else {
 			logger.Error(logTag, fmt.Sprintf(`Configuring handler for "%s": Unexpected handler source type: %s`, handlerConfig.Domain, handlerConfig.Source.Type))
@@ -161,13 +276,15 @@ func mainExitCode() int {
 		upchecks = append(upchecks, server.NewDNSAnswerValidatingUpcheck(fmt.Sprintf("%s:%d", config.Address, config.Port), upcheckDomain, "tcp"))
 	}
 
-	recursorPool := handlers.NewFailoverRecursorPool(config.Recursors, logger)
-	forwardHandler := handlers.NewForwardHandler(recursorPool, exchangerFactory, clock, logger)
+	recursorPool := newRecursorPool(config, handlers.DefaultRecursorPool, config.Recursors, hedgingRegistry, clock, logger)
+	var forwardHandler dns.Handler = handlers.NewForwardHandler(recursorPool, exchangerFactory, clock, logger)
 	if config.Cache.Enabled {
-		mux.Handle(".", handlers.NewCachingDNSHandler(forwardHandler))
-	} else {
-		mux.Handle(".", forwardHandler)
+		forwardHandler = handlers.NewCachingDNSHandler(forwardHandler)
 	}
+	mux.Handle(".", forwardHandler)
+
+	discoveryHandler := handlers.NewDiscoveryHandler(logger, localDomain, aliasedRecordSet, forwardHandler)
+	handlerRegistrar := handlers.NewHandlerRegistrar(logger, clock, aliasedRecordSet, mux, discoveryHandler)
 
 	bindAddress := fmt.Sprintf("%s:%d", config.Address, config.Port)
 	dnsServer := server.New(
@@ -191,6 +308,34 @@ func mainExitCode() int {
 
 	go healthWatcher.Run(shutdown)
 
+	if config.Health.Enabled && config.Health.TrackAll.Enabled {
+		selectors := []healthiness.TrackSelector{}
+		for _, selector := range config.Health.TrackAll.Selectors {
+			selectors = append(selectors, healthiness.TrackSelector{
+				Deployment: selector.Deployment,
+				Group:      selector.InstanceGroup,
+			})
+		}
+
+		tracker := healthiness.NewAllRecordsTracker(recordSet, healthyRecordSet, selectors, config.Health.TrackAll.Rate, clock)
+		go tracker.Run(shutdown)
+	}
+
+	if config.API.Port != 0 {
+		apiServer := api.NewServer(fmt.Sprintf("127.0.0.1:%d", config.API.Port), logger)
+		apiServer.Handle("/health", api.NewHealthHandler(healthWatcher))
+		apiServer.Handle("/health/events", api.NewHealthEventsHandler(healthEvents))
+		apiServer.Handle("/health/history", api.NewHealthHistoryHandler(healthWatcher))
+		apiServer.Handle("/recursors/hedging", api.NewRecursorHedgingHandler(hedgingRegistry))
+
+		go func() {
+			err := apiServer.Run(shutdown)
+			if err != nil {
+				logger.Error(logTag, fmt.Sprintf("could not start api server: %s", err.Error()))
+			}
+		}()
+	}
+
 	sigterm := make(chan os.Signal, 1)
 	signal.Notify(sigterm, syscall.SIGTERM)
 
@@ -205,5 +350,107 @@ func mainExitCode() int {
 		return 1
 	}
 
+	<-snapshotSaved
+
 	return 0
 }
+
+// newRecursorPool creates the pool of recursors selected by the config. The
+// hedging metrics of hedged pools are reported under the given name, unless
+// hedging is disabled with a recursor_max_hedges of 0.
+func newRecursorPool(config dnsconfig.Config, name string, recursors []string, hedgingRegistry *handlers.HedgingRegistry, clock clock.Clock, logger boshlog.Logger) handlers.RecursorPool {
+	switch config.RecursorSelection {
+	case dnsconfig.RecursorSelectionLatency:
+		// a failed query costs about as much as a query that timed out
+		return handlers.NewLatencyRecursorPool(recursors, time.Duration(config.RecursorTimeout), time.Duration(config.RecursorProbeInterval), clock, logger)
+	case dnsconfig.RecursorSelectionHedged:
+		var metrics *handlers.HedgingMetrics
+		if config.RecursorMaxHedges > 0 {
+			metrics = hedgingRegistry.Metrics(name)
+		}
+
+		return handlers.NewHedgedRecursorPool(recursors, time.Duration(config.RecursorHedgeDelay), config.RecursorMaxHedges, metrics, clock, logger)
+	}
+
+	return handlers.NewFailoverRecursorPool(recursors, logger)
+}
+
+// newRecursorTLSConfig verifies DNS-over-TLS recursors against the system
+// roots, unless a CA file is configured.
+func newRecursorTLSConfig(fs boshsys.FileSystem, recursorTLS dnsconfig.RecursorTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: recursorTLS.ServerName,
+	}
+
+	if recursorTLS.CAFile == "" {
+		return tlsConfig, nil
+	}
+
+	caCert, err := fs.ReadFile(recursorTLS.CAFile)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading recursor CA file")
+	}
+
+	tlsConfig.RootCAs = x509.NewCertPool()
+	if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+		return nil, bosherr.Errorf("No CA certificates found in '%s'", recursorTLS.CAFile)
+	}
+
+	return tlsConfig, nil
+}
+
+// localIPs lists the addresses of this instance, which must not take part
+// in sharing health observations as its own peer.
+func localIPs() ([]string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	ips := []string{}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			ips = append(ips, ipNet.IP.String())
+		}
+	}
+
+	return ips, nil
+}
+
+func newDomainHealthCheckers(checks []dnsconfig.HealthCheckConfig) map[string]healthiness.HealthChecker {
+	checkers := map[string]healthiness.HealthChecker{}
+
+	for _, check := range checks {
+		timeout := time.Duration(check.Timeout)
+
+		switch check.Type {
+		case dnsconfig.HealthCheckTypeTCP:
+			checkers[check.Domain] = healthiness.NewTCPHealthChecker(check.Port, timeout)
+		case dnsconfig.HealthCheckTypeHTTP:
+			checkers[check.Domain] = healthiness.NewHTTPHealthChecker(&http.Client{Timeout: timeout}, check.Port, check.Path, check.ExpectedStatus)
+		case dnsconfig.HealthCheckTypeGRPC:
+			checkers[check.Domain] = healthiness.NewGRPCHealthChecker(check.Port, check.Service, timeout)
+		}
+	}
+
+	return checkers
+}
+
+func newStaticHealthCheckers(checks map[string]aliases.TargetHealthCheck) map[string]healthiness.HealthChecker {
+	checkers := map[string]healthiness.HealthChecker{}
+
+	for ip, check := range checks {
+		switch check.Type {
+		case aliases.TargetHealthCheckNone:
+			checkers[ip] = healthiness.NewUncheckedHealthChecker()
+		case aliases.TargetHealthCheckTCP:
+			checkers[ip] = healthiness.NewTCPHealthChecker(check.Port, check.Timeout)
+		case aliases.TargetHealthCheckHTTP:
+			checkers[ip] = healthiness.NewHTTPHealthChecker(&http.Client{Timeout: check.Timeout}, check.Port, check.Path, check.ExpectedStatus)
+		}
+	}
+
+	return checkers
+}
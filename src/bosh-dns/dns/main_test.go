@@ -89,6 +89,78 @@ var _ = Describe("main", func() {
 		})
 	})
 
+	Describe("lint-aliases", func() {
+		var aliasesFile *os.File
+
+		BeforeEach(func() {
+			var err error
+			aliasesFile, err = ioutil.TempFile("", "aliases")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.Remove(aliasesFile.Name())
+		})
+
+		It("exits 0 when the alias files are valid", func() {
+			_, err := aliasesFile.Write([]byte(`{"alias1": ["q-s0.group.network.deployment.bosh"]}`))
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := exec.Command(pathToServer, "lint-aliases", aliasesFile.Name())
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say("no problems found"))
+		})
+
+		It("exits 1 and reports the problems found", func() {
+			_, err := aliasesFile.Write([]byte(`{"alias1": ["alias2"], "alias2": ["alias1"]}`))
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := exec.Command(pathToServer, "lint-aliases", aliasesFile.Name())
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Out).To(gbytes.Say("%s: alias1: recursion detected", aliasesFile.Name()))
+			Expect(session.Out).To(gbytes.Say("%s: alias2: recursion detected", aliasesFile.Name()))
+			Expect(session.Out).To(gbytes.Say("2 problem\\(s\\) found"))
+		})
+
+		It("reports targets which resolve to zero instances in the records file", func() {
+			_, err := aliasesFile.Write([]byte(`{"alias1": ["q-s0.my-group.my-network.my-deployment.bosh"], "alias2": ["q-s0.other-group.my-network.my-deployment.bosh"]}`))
+			Expect(err).NotTo(HaveOccurred())
+
+			recordsFile, err := ioutil.TempFile("", "recordsjson")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.Remove(recordsFile.Name())
+
+			_, err = recordsFile.Write([]byte(`{
+				"record_keys": ["id", "instance_group", "network", "deployment", "ip", "domain"],
+				"record_infos": [["my-instance", "my-group", "my-network", "my-deployment", "127.0.0.1", "bosh"]]
+			}`))
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := exec.Command(pathToServer, "lint-aliases", "--records", recordsFile.Name(), aliasesFile.Name())
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(1))
+			Expect(session.Out).To(gbytes.Say("alias2: target q-s0.other-group.my-network.my-deployment.bosh. resolves to zero instances"))
+			Expect(session.Out).NotTo(gbytes.Say("alias1"))
+		})
+
+		It("exits 2 when no alias files are given", func() {
+			cmd := exec.Command(pathToServer, "lint-aliases")
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session).Should(gexec.Exit(2))
+			Expect(session.Err).To(gbytes.Say("Usage: .* lint-aliases"))
+		})
+	})
+
 	Context("when the server starts successfully", func() {
 		var (
 			cmd                   *exec.Cmd
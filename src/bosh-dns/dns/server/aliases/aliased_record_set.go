@@ -1,9 +1,16 @@
 package aliases
 
+import (
+	"bosh-dns/dns/server/records"
+
+	"github.com/miekg/dns"
+)
+
 //go:generate counterfeiter . RecordSet
 
 type RecordSet interface {
 	Resolve(string) ([]string, error)
+	HealthStrategy(string) string
 	Domains() []string
 	Subscribe() <-chan bool
 }
@@ -41,6 +48,24 @@ func (a *AliasedRecordSet) Resolve(domain string) ([]string, error) {
 	return a.recordSet.Resolve(domain)
 }
 
+// HealthStrategy of an alias is the strategy shared by all of its targets.
+// Targets disagreeing with each other fall back to the default strategy.
+func (a *AliasedRecordSet) HealthStrategy(domain string) string {
+	resolutions := a.config.Resolutions(domain)
+	if len(resolutions) == 0 {
+		return a.recordSet.HealthStrategy(domain)
+	}
+
+	strategy := a.recordSet.HealthStrategy(resolutions[0])
+	for _, resolution := range resolutions[1:] {
+		if a.recordSet.HealthStrategy(resolution) != strategy {
+			return records.HealthStrategySmart
+		}
+	}
+
+	return strategy
+}
+
 func (a *AliasedRecordSet) Subscribe() <-chan bool {
 	return a.recordSet.Subscribe()
 }
@@ -48,3 +73,19 @@ func (a *AliasedRecordSet) Subscribe() <-chan bool {
 func (a *AliasedRecordSet) Domains() []string {
 	return append(a.recordSet.Domains(), a.config.AliasHosts()...)
 }
+
+// IsLocal tells whether domain is served by bosh-dns itself rather than by
+// the recursors.
+func (a *AliasedRecordSet) IsLocal(domain string) bool {
+	if a.config.ServesLocally(domain) {
+		return true
+	}
+
+	for _, recordDomain := range a.recordSet.Domains() {
+		if dns.IsSubDomain(dns.Fqdn(recordDomain), domain) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -22,9 +22,10 @@ var _ = Describe("AliasedRecordSet", func() {
 		fakeRecordSet = &aliasesfakes.FakeRecordSet{}
 
 		config := aliases.MustNewConfigFromMap(map[string][]string{
-			"alias1":   {"a1_domain1", "a1_domain2"},
-			"alias2":   {"a2_domain1"},
-			"_.alias2": {"_.a2_domain1", "_.b2_domain1"},
+			"alias1":               {"a1_domain1", "a1_domain2"},
+			"alias2":               {"a2_domain1"},
+			"_.alias2":             {"_.a2_domain1", "_.b2_domain1"},
+			"<svc>.<space>.alias3": {"q-s0.<svc>.<space>.bosh"},
 		})
 
 		var err error
@@ -47,7 +48,62 @@ var _ = Describe("AliasedRecordSet", func() {
 	Describe("Domains", func() {
 		It("returns the aliases and underlying record sets domains", func() {
 			fakeRecordSet.DomainsReturns([]string{"a", "b"})
-			Expect(aliasSet.Domains()).To(ConsistOf("a", "b", "alias1.", "alias2."))
+			Expect(aliasSet.Domains()).To(ConsistOf("a", "b", "alias1.", "alias2.", "alias3."))
+		})
+	})
+
+	Describe("IsLocal", func() {
+		BeforeEach(func() {
+			fakeRecordSet.DomainsReturns([]string{"bosh."})
+		})
+
+		It("serves aliases and names below aliases which are not patterns", func() {
+			Expect(aliasSet.IsLocal("alias1.")).To(BeTrue())
+			Expect(aliasSet.IsLocal("sub.alias1.")).To(BeTrue())
+			Expect(aliasSet.IsLocal("anything.alias2.")).To(BeTrue())
+		})
+
+		It("serves names matching a pattern alias", func() {
+			Expect(aliasSet.IsLocal("web.prod.alias3.")).To(BeTrue())
+		})
+
+		It("serves names below the domains of the record set", func() {
+			Expect(aliasSet.IsLocal("q-s0.web.prod.bosh.")).To(BeTrue())
+		})
+
+		It("leaves sibling names below the suffix of a pattern alias to the recursors", func() {
+			Expect(aliasSet.IsLocal("alias3.")).To(BeFalse())
+			Expect(aliasSet.IsLocal("www.alias3.")).To(BeFalse())
+			Expect(aliasSet.IsLocal("a.b.c.alias3.")).To(BeFalse())
+		})
+	})
+
+	Describe("HealthStrategy", func() {
+		BeforeEach(func() {
+			fakeRecordSet.HealthStrategyStub = func(domain string) string {
+				switch domain {
+				case "a1_domain1.", "a2_domain1.":
+					return "1"
+				case "a1_domain2.":
+					return "2"
+				default:
+					return "3"
+				}
+			}
+		})
+
+		It("delegates non-aliased names to the underlying record set", func() {
+			Expect(aliasSet.HealthStrategy("q-s3.anything.")).To(Equal("3"))
+			Expect(fakeRecordSet.HealthStrategyArgsForCall(0)).To(Equal("q-s3.anything."))
+		})
+
+		It("uses the strategy of the alias targets", func() {
+			Expect(aliasSet.HealthStrategy("alias2.")).To(Equal("1"))
+			Expect(fakeRecordSet.HealthStrategyArgsForCall(0)).To(Equal("a2_domain1."))
+		})
+
+		It("uses the default strategy when the alias targets disagree", func() {
+			Expect(aliasSet.HealthStrategy("alias1.")).To(Equal("0"))
 		})
 	})
 
@@ -105,6 +161,18 @@ var _ = Describe("AliasedRecordSet", func() {
 			})
 		})
 
+		Context("when the message matches a pattern alias", func() {
+			It("translates the question substituting the captures", func() {
+				fakeRecordSet.ResolveReturns([]string{"1.1.1.1"}, nil)
+				resolutions, err := aliasSet.Resolve("web.prod.alias3.")
+
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resolutions).To(Equal([]string{"1.1.1.1"}))
+				Expect(fakeRecordSet.ResolveCallCount()).To(Equal(1))
+				Expect(fakeRecordSet.ResolveArgsForCall(0)).To(Equal("q-s0.web.prod.bosh."))
+			})
+		})
+
 		Context("when resolving an aliased host", func() {
 			It("resolves the alias", func() {
 				fakeRecordSet.ResolveReturns([]string{"1.1.1.1"}, nil)
@@ -7,7 +7,7 @@ import (
 )
 
 type FakeRecordSet struct {
-	ResolveStub        func(string) ([]string, error)
+	ResolveStub        func(arg1 string) ([]string, error)
 	resolveMutex       sync.RWMutex
 	resolveArgsForCall []struct {
 		arg1 string
@@ -20,6 +20,17 @@ type FakeRecordSet struct {
 		result1 []string
 		result2 error
 	}
+	HealthStrategyStub        func(arg1 string) string
+	healthStrategyMutex       sync.RWMutex
+	healthStrategyArgsForCall []struct {
+		arg1 string
+	}
+	healthStrategyReturns struct {
+		result1 string
+	}
+	healthStrategyReturnsOnCall map[int]struct {
+		result1 string
+	}
 	DomainsStub        func() []string
 	domainsMutex       sync.RWMutex
 	domainsArgsForCall []struct{}
@@ -93,6 +104,54 @@ func (fake *FakeRecordSet) ResolveReturnsOnCall(i int, result1 []string, result2
 	}{result1, result2}
 }
 
+func (fake *FakeRecordSet) HealthStrategy(arg1 string) string {
+	fake.healthStrategyMutex.Lock()
+	ret, specificReturn := fake.healthStrategyReturnsOnCall[len(fake.healthStrategyArgsForCall)]
+	fake.healthStrategyArgsForCall = append(fake.healthStrategyArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("HealthStrategy", []interface{}{arg1})
+	fake.healthStrategyMutex.Unlock()
+	if fake.HealthStrategyStub != nil {
+		return fake.HealthStrategyStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.healthStrategyReturns.result1
+}
+
+func (fake *FakeRecordSet) HealthStrategyCallCount() int {
+	fake.healthStrategyMutex.RLock()
+	defer fake.healthStrategyMutex.RUnlock()
+	return len(fake.healthStrategyArgsForCall)
+}
+
+func (fake *FakeRecordSet) HealthStrategyArgsForCall(i int) string {
+	fake.healthStrategyMutex.RLock()
+	defer fake.healthStrategyMutex.RUnlock()
+	return fake.healthStrategyArgsForCall[i].arg1
+}
+
+func (fake *FakeRecordSet) HealthStrategyReturns(result1 string) {
+	fake.HealthStrategyStub = nil
+	fake.healthStrategyReturns = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakeRecordSet) HealthStrategyReturnsOnCall(i int, result1 string) {
+	fake.HealthStrategyStub = nil
+	if fake.healthStrategyReturnsOnCall == nil {
+		fake.healthStrategyReturnsOnCall = make(map[int]struct {
+			result1 string
+		})
+	}
+	fake.healthStrategyReturnsOnCall[i] = struct {
+		result1 string
+	}{result1}
+}
+
 func (fake *FakeRecordSet) Domains() []string {
 	fake.domainsMutex.Lock()
 	ret, specificReturn := fake.domainsReturnsOnCall[len(fake.domainsArgsForCall)]
@@ -178,6 +237,8 @@ func (fake *FakeRecordSet) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.resolveMutex.RLock()
 	defer fake.resolveMutex.RUnlock()
+	fake.healthStrategyMutex.RLock()
+	defer fake.healthStrategyMutex.RUnlock()
 	fake.domainsMutex.RLock()
 	defer fake.domainsMutex.RUnlock()
 	fake.subscribeMutex.RLock()
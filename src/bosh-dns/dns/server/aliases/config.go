@@ -7,6 +7,7 @@ import (
 	"net"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/miekg/dns"
 )
@@ -14,13 +15,82 @@ import (
 type Config struct {
 	aliases           map[string][]string
 	underscoreAliases map[string][]string
+	patternAliases    []patternAlias
 	aliasHosts        []string
+	localHosts        map[string]bool
+	patternHosts      map[string]bool
+	healthChecks      map[string]TargetHealthCheck
+}
+
+const (
+	TargetHealthCheckNone = "none"
+	TargetHealthCheckTCP  = "tcp"
+	TargetHealthCheckHTTP = "http"
+)
+
+// TargetHealthCheck replaces the bosh-dns health server check of a literal IP
+// target, which usually does not run one.
+type TargetHealthCheck struct {
+	Type           string        `json:"type"`
+	Port           int           `json:"port"`
+	Path           string        `json:"path"`
+	ExpectedStatus int           `json:"expected_status"`
+	Timeout        time.Duration `json:"timeout"`
+}
+
+func (c *TargetHealthCheck) UnmarshalJSON(j []byte) error {
+	type plainCheck TargetHealthCheck
+	check := struct {
+		*plainCheck
+		Timeout string `json:"timeout"`
+	}{plainCheck: (*plainCheck)(c)}
+
+	err := json.Unmarshal(j, &check)
+	if err != nil {
+		return err
+	}
+
+	if check.Timeout == "" {
+		return nil
+	}
+
+	c.Timeout, err = time.ParseDuration(check.Timeout)
+
+	return err
+}
+
+// aliasTarget is a target in an alias file: either a plain domain or IP, or
+// an object giving a literal IP its own health check.
+type aliasTarget struct {
+	Target      string             `json:"target"`
+	HealthCheck *TargetHealthCheck `json:"health_check"`
+}
+
+func (t *aliasTarget) UnmarshalJSON(j []byte) error {
+	var target string
+	if err := json.Unmarshal(j, &target); err == nil {
+		*t = aliasTarget{Target: target}
+		return nil
+	}
+
+	type plainTarget aliasTarget
+	return json.Unmarshal(j, (*plainTarget)(t))
+}
+
+func targetNames(targets []aliasTarget) []string {
+	names := []string{}
+	for _, target := range targets {
+		names = append(names, target.Target)
+	}
+
+	return names
 }
 
 func NewConfig() Config {
 	return Config{
 		aliases:           map[string][]string{},
 		underscoreAliases: map[string][]string{},
+		healthChecks:      map[string]TargetHealthCheck{},
 	}
 }
 
@@ -42,20 +112,48 @@ func NewConfigFromMap(load map[string][]string) (Config, error) {
 		}
 	}
 
-	config.aliasHosts = config.getAliasHosts()
+	sortPatternAliases(config.patternAliases)
+	config.indexHosts()
+
+	return config, nil
+}
+
+func newConfigFromTargets(load map[string][]aliasTarget) (Config, error) {
+	config := NewConfig()
+
+	for alias, targets := range load {
+		err := config.setAlias(alias, targetNames(targets))
+		if err != nil {
+			return config, err
+		}
+
+		for _, target := range targets {
+			if target.HealthCheck == nil {
+				continue
+			}
+
+			err = config.setHealthCheck(alias, target.Target, *target.HealthCheck)
+			if err != nil {
+				return config, err
+			}
+		}
+	}
+
+	sortPatternAliases(config.patternAliases)
+	config.indexHosts()
 
 	return config, nil
 }
 
 func (c *Config) UnmarshalJSON(j []byte) error {
-	primitive := map[string][]string{}
+	primitive := map[string][]aliasTarget{}
 
 	err := json.Unmarshal(j, &primitive)
 	if err != nil {
 		return err
 	}
 
-	config, err := NewConfigFromMap(primitive)
+	config, err := newConfigFromTargets(primitive)
 	if err != nil {
 		return err
 	}
@@ -64,6 +162,49 @@ func (c *Config) UnmarshalJSON(j []byte) error {
 	return nil
 }
 
+func (c *Config) setHealthCheck(alias, ip string, check TargetHealthCheck) error {
+	if net.ParseIP(ip) == nil {
+		return fmt.Errorf("bad alias format: %s has a health check for %s which is not an IP", alias, ip)
+	}
+
+	switch check.Type {
+	case TargetHealthCheckNone:
+	case TargetHealthCheckTCP, TargetHealthCheckHTTP:
+		if check.Port <= 0 {
+			return fmt.Errorf("bad alias format: %s has a %s health check for %s without a port", alias, check.Type, ip)
+		}
+	default:
+		return fmt.Errorf("bad alias format: %s has a health check for %s with unknown type '%s'", alias, ip, check.Type)
+	}
+
+	if check.Type == TargetHealthCheckHTTP {
+		if check.Path == "" {
+			check.Path = "/"
+		}
+
+		if check.ExpectedStatus == 0 {
+			check.ExpectedStatus = 200
+		}
+	}
+
+	if check.Type != TargetHealthCheckNone && check.Timeout == 0 {
+		check.Timeout = 5 * time.Second
+	}
+
+	if existing, found := c.healthChecks[ip]; found && existing != check {
+		return fmt.Errorf("bad alias format: %s has a health check for %s conflicting with another check of the same IP", alias, ip)
+	}
+
+	c.healthChecks[ip] = check
+
+	return nil
+}
+
+// HealthChecks returns the health checks of literal IP targets by IP.
+func (c Config) HealthChecks() map[string]TargetHealthCheck {
+	return c.healthChecks
+}
+
 func (c *Config) setAlias(alias string, domains []string) error {
 	if alias == "" {
 		return errors.New("bad alias format: empty alias qn")
@@ -80,6 +221,26 @@ func (c *Config) setAlias(alias string, domains []string) error {
 		}
 	}
 
+	if isPatternAlias(alias) {
+		if strings.HasPrefix(alias, "_.") {
+			return fmt.Errorf("bad alias format: %s mixes underscore and capture labels", alias)
+		}
+
+		pattern, err := newPatternAlias(dns.Fqdn(alias), qualifedDomains)
+		if err != nil {
+			return err
+		}
+
+		c.patternAliases = append(c.patternAliases, pattern)
+
+		return nil
+	}
+
+	err := validateTargets(alias, qualifedDomains, nil)
+	if err != nil {
+		return err
+	}
+
 	if strings.HasPrefix(alias, "_.") {
 		splitAlias := strings.SplitN(alias, ".", 2)
 		c.underscoreAliases[dns.Fqdn(splitAlias[1])] = qualifedDomains
@@ -92,11 +253,17 @@ func (c *Config) setAlias(alias string, domains []string) error {
 
 func (c Config) IsReduced() bool {
 	for _, domains := range c.aliases {
-		for alias, _ := range c.aliases {
-			for _, domain := range domains {
-				if alias == domain {
-					return false
-				}
+		for _, domain := range domains {
+			if c.isAliased(domain) {
+				return false
+			}
+		}
+	}
+
+	for _, pattern := range c.patternAliases {
+		for _, domain := range pattern.targets {
+			if c.isAliased(domain) {
+				return false
 			}
 		}
 	}
@@ -104,6 +271,30 @@ func (c Config) IsReduced() bool {
 	return true
 }
 
+func (c Config) isAliased(domain string) bool {
+	if _, found := c.aliases[domain]; found {
+		return true
+	}
+
+	_, _, found := c.matchPattern(domain)
+
+	return found
+}
+
+func (c Config) matchPattern(domain string) (patternAlias, map[string]string, bool) {
+	if net.ParseIP(domain) != nil {
+		return patternAlias{}, nil, false
+	}
+
+	for _, pattern := range c.patternAliases {
+		if captures, found := pattern.match(domain); found {
+			return pattern, captures, true
+		}
+	}
+
+	return patternAlias{}, nil, false
+}
+
 func (c Config) Resolutions(maybeAlias string) []string {
 	for alias, domains := range c.aliases {
 		if alias == maybeAlias {
@@ -133,6 +324,21 @@ func (c Config) Resolutions(maybeAlias string) []string {
 		}
 	}
 
+	if pattern, captures, found := c.matchPattern(maybeAlias); found {
+		resolutions := []string{}
+
+		for _, domain := range pattern.expand(captures) {
+			resolved, err := c.reduce2(domain, 0)
+			if err != nil {
+				return nil
+			}
+
+			resolutions = append(resolutions, resolved...)
+		}
+
+		return resolutions
+	}
+
 	return nil
 }
 
@@ -153,7 +359,38 @@ func (c Config) Merge(other Config) Config {
 		c.underscoreAliases[alias] = targets
 	}
 
-	c.aliasHosts = c.getAliasHosts()
+	healthChecks := map[string]TargetHealthCheck{}
+	for ip, check := range other.healthChecks {
+		healthChecks[ip] = check
+	}
+
+	for ip, check := range c.healthChecks {
+		healthChecks[ip] = check
+	}
+
+	c.healthChecks = healthChecks
+
+	patterns := append([]patternAlias{}, c.patternAliases...)
+	for _, otherPattern := range other.patternAliases {
+		found := false
+		for _, pattern := range c.patternAliases {
+			if pattern.pattern == otherPattern.pattern {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			patterns = append(patterns, otherPattern)
+		}
+	}
+
+	if len(patterns) > 0 {
+		sortPatternAliases(patterns)
+		c.patternAliases = patterns
+	}
+
+	c.indexHosts()
 
 	return c
 }
@@ -175,17 +412,48 @@ func (c Config) ReducedForm() (Config, error) {
 		c.aliases[alias] = resolvedAlias
 	}
 
+	if len(c.patternAliases) == 0 {
+		return c, nil
+	}
+
+	patterns := []patternAlias{}
+	for _, pattern := range c.patternAliases {
+		resolvedTargets := []string{}
+
+		for _, target := range pattern.targets {
+			resolvedTarget, err := c.reduce2(target, 1)
+			if err != nil {
+				return Config{}, fmt.Errorf("failed to resolve %s: %s", pattern.pattern, err)
+			}
+
+			resolvedTargets = append(resolvedTargets, resolvedTarget...)
+		}
+
+		pattern.targets = resolvedTargets
+		patterns = append(patterns, pattern)
+	}
+
+	c.patternAliases = patterns
+
 	return c, nil
 }
 
+// reduce2 follows alias targets until they no longer refer to another alias.
+// Targets of pattern aliases still holding capture references are matched
+// symbolically: a reference can only satisfy a capture label, never a literal.
 func (c Config) reduce2(alias string, depth int) ([]string, error) {
-	if depth > len(c.aliases)+1 {
+	if depth > len(c.aliases)+len(c.patternAliases)+1 {
 		return nil, errors.New("recursion detected")
 	}
 
 	targets, found := c.aliases[alias]
 	if !found {
-		return []string{alias}, nil
+		pattern, captures, matched := c.matchPattern(alias)
+		if !matched {
+			return []string{alias}, nil
+		}
+
+		targets = pattern.expand(captures)
 	}
 
 	resolved := []string{}
@@ -206,6 +474,53 @@ func (c Config) AliasHosts() []string {
 	return c.aliasHosts
 }
 
+// ServesLocally tells whether domain is an alias, or a name below an alias
+// which is not a pattern. Pattern aliases claim the zone of their literal
+// suffix, but the names in it which do not match a pattern are left to the
+// recursors.
+func (c Config) ServesLocally(domain string) bool {
+	name := strings.ToLower(dns.Fqdn(domain))
+	inPatternZone := false
+
+	for offset, end := 0, false; !end; offset, end = dns.NextLabel(name, offset) {
+		if c.localHosts[name[offset:]] {
+			return true
+		}
+
+		if c.patternHosts[name[offset:]] {
+			inPatternZone = true
+		}
+	}
+
+	if !inPatternZone {
+		return false
+	}
+
+	_, _, found := c.matchPattern(domain)
+
+	return found
+}
+
+// indexHosts precomputes the hosts looked up for every query, so that
+// ServesLocally does not have to scan the aliases.
+func (c *Config) indexHosts() {
+	c.aliasHosts = c.getAliasHosts()
+	c.localHosts = map[string]bool{}
+	c.patternHosts = map[string]bool{}
+
+	for host := range c.aliases {
+		c.localHosts[strings.ToLower(dns.Fqdn(host))] = true
+	}
+
+	for host := range c.underscoreAliases {
+		c.localHosts[strings.ToLower(host)] = true
+	}
+
+	for _, pattern := range c.patternAliases {
+		c.patternHosts[strings.ToLower(pattern.host())] = true
+	}
+}
+
 func (c Config) getAliasHosts() []string {
 	aliasHosts := []string{}
 	allHosts := c.allAliasHosts()
@@ -241,5 +556,9 @@ func (c Config) allAliasHosts() map[string]bool {
 		allHosts[host] = true
 	}
 
+	for _, pattern := range c.patternAliases {
+		allHosts[pattern.host()] = true
+	}
+
 	return allHosts
 }
@@ -1,9 +1,13 @@
 package aliases_test
 
 import (
+	"encoding/json"
+	"time"
+
 	. "bosh-dns/dns/server/aliases"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 )
 
@@ -134,6 +138,116 @@ var _ = Describe("Config", func() {
 		})
 	})
 
+	Describe("pattern alias", func() {
+		Context("single label captures", func() {
+			It("substitutes every captured label into the targets", func() {
+				c := MustNewConfigFromMap(map[string][]string{
+					"<svc>.<space>.apps.internal": {"q-s0.<svc>.<space>.bosh"},
+				})
+
+				Expect(c.Resolutions("web.prod.apps.internal.")).To(Equal([]string{"q-s0.web.prod.bosh."}))
+			})
+
+			It("substitutes captures within a label", func() {
+				c := MustNewConfigFromMap(map[string][]string{
+					"<svc>.<space>.apps.internal": {"q-s0.<svc>-<space>.network.deployment.bosh", "*.<svc>.bosh"},
+				})
+
+				Expect(c.Resolutions("web.prod.apps.internal.")).To(Equal([]string{
+					"q-s0.web-prod.network.deployment.bosh.",
+					"q-s0.web.bosh.",
+				}))
+			})
+
+			It("does not match names with a different number of labels", func() {
+				c := MustNewConfigFromMap(map[string][]string{
+					"<svc>.<space>.apps.internal": {"<svc>.<space>.bosh"},
+				})
+
+				Expect(c.Resolutions("prod.apps.internal.")).To(BeNil())
+				Expect(c.Resolutions("a.web.prod.apps.internal.")).To(BeNil())
+				Expect(c.Resolutions("web.prod.apps.other.")).To(BeNil())
+			})
+
+			It("matches captures surrounded by literal labels", func() {
+				c := MustNewConfigFromMap(map[string][]string{
+					"web.<space>.apps.internal": {"web.<space>.bosh"},
+				})
+
+				Expect(c.Resolutions("web.prod.apps.internal.")).To(Equal([]string{"web.prod.bosh."}))
+				Expect(c.Resolutions("api.prod.apps.internal.")).To(BeNil())
+			})
+		})
+
+		Context("multi-label captures", func() {
+			It("matches any number of leading labels as a suffix pattern", func() {
+				c := MustNewConfigFromMap(map[string][]string{
+					"<host*>.apps.internal": {"<host>.bosh"},
+				})
+
+				Expect(c.Resolutions("web.apps.internal.")).To(Equal([]string{"web.bosh."}))
+				Expect(c.Resolutions("a.b.c.apps.internal.")).To(Equal([]string{"a.b.c.bosh."}))
+				Expect(c.Resolutions("apps.internal.")).To(BeNil())
+			})
+		})
+
+		Context("when several patterns match", func() {
+			It("prefers the most specific pattern", func() {
+				c := MustNewConfigFromMap(map[string][]string{
+					"<host*>.apps.internal":       {"any.bosh"},
+					"<svc>.<space>.apps.internal": {"two-labels.bosh"},
+					"<svc>.prod.apps.internal":    {"prod.bosh"},
+				})
+
+				Expect(c.Resolutions("web.prod.apps.internal.")).To(Equal([]string{"prod.bosh."}))
+				Expect(c.Resolutions("web.dev.apps.internal.")).To(Equal([]string{"two-labels.bosh."}))
+				Expect(c.Resolutions("web.apps.internal.")).To(Equal([]string{"any.bosh."}))
+			})
+		})
+
+		Context("when a static alias would also match", func() {
+			It("resolves with the static alias", func() {
+				c := MustNewConfigFromMap(map[string][]string{
+					"web.prod.apps.internal":      {"static.bosh"},
+					"<svc>.<space>.apps.internal": {"<svc>.bosh"},
+				})
+
+				Expect(c.Resolutions("web.prod.apps.internal.")).To(Equal([]string{"static.bosh."}))
+				Expect(c.Resolutions("api.prod.apps.internal.")).To(Equal([]string{"api.bosh."}))
+			})
+		})
+
+		Context("when a substituted target is itself an alias", func() {
+			It("resolves the target alias", func() {
+				c := MustNewConfigFromMap(map[string][]string{
+					"<svc>.apps.internal": {"<svc>.internal"},
+					"web.internal":        {"q-s0.web.bosh"},
+				})
+
+				Expect(c.Resolutions("web.apps.internal.")).To(Equal([]string{"q-s0.web.bosh."}))
+				Expect(c.Resolutions("api.apps.internal.")).To(Equal([]string{"api.internal."}))
+			})
+		})
+
+		Context("invalid patterns", func() {
+			DescribeTable("returns an error", func(alias string, targets []string, message string) {
+				_, err := NewConfigFromMap(map[string][]string{alias: targets})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(message))
+			},
+				Entry("partial capture label", "web-<svc>.apps.internal", []string{"domain"}, "invalid capture label 'web-<svc>'"),
+				Entry("unterminated capture label", "<svc.apps.internal", []string{"domain"}, "invalid capture label '<svc'"),
+				Entry("duplicate capture", "<svc>.<svc>.apps.internal", []string{"domain"}, "duplicate capture 'svc'"),
+				Entry("multi-label capture not first", "web.<rest*>.internal", []string{"domain"}, "multi-label capture 'rest' must be the first label"),
+				Entry("no literal suffix", "<svc>.<space>", []string{"domain"}, "must end with a literal label"),
+				Entry("underscore mixed with captures", "_.<svc>.internal", []string{"domain"}, "mixes underscore and capture labels"),
+				Entry("unknown capture reference", "<svc>.apps.internal", []string{"<space>.bosh"}, "references unknown capture 'space'"),
+				Entry("malformed capture reference", "<svc>.apps.internal", []string{"<svc.bosh"}, "has an invalid capture reference"),
+				Entry("capture reference in a static alias", "web.apps.internal", []string{"<svc>.bosh"}, "references unknown capture 'svc'"),
+			)
+		})
+	})
+
 	Describe("IP aliases", func() {
 		It("resolves and does not add a trailing dot", func() {
 			c := MustNewConfigFromMap(map[string][]string{
@@ -308,6 +422,71 @@ var _ = Describe("Config", func() {
 		})
 	})
 
+	Describe("ReducedForm with pattern aliases", func() {
+		It("reduces pattern targets that refer to static aliases", func() {
+			reduced, err := MustNewConfigFromMap(map[string][]string{
+				"<svc>.apps.internal": {"shared.internal", "<svc>.bosh"},
+				"shared.internal":     {"q-s0.shared.bosh"},
+			}).ReducedForm()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reduced.IsReduced()).To(BeTrue())
+			Expect(reduced.Resolutions("web.apps.internal.")).To(Equal([]string{"q-s0.shared.bosh.", "web.bosh."}))
+		})
+
+		It("reduces pattern targets that refer to other patterns", func() {
+			reduced, err := MustNewConfigFromMap(map[string][]string{
+				"<svc>.<space>.apps.internal": {"<svc>.<space>.internal"},
+				"<name>.<ns>.internal":        {"q-s0.<name>.<ns>.bosh"},
+			}).ReducedForm()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reduced.IsReduced()).To(BeTrue())
+			Expect(reduced.Resolutions("web.prod.apps.internal.")).To(Equal([]string{"q-s0.web.prod.bosh."}))
+		})
+
+		It("reduces static aliases that refer to patterns", func() {
+			reduced, err := MustNewConfigFromMap(map[string][]string{
+				"api.internal":        {"api.apps.internal"},
+				"<svc>.apps.internal": {"q-s0.<svc>.bosh"},
+			}).ReducedForm()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reduced.IsReduced()).To(BeTrue())
+			Expect(reduced.Resolutions("api.internal.")).To(Equal([]string{"q-s0.api.bosh."}))
+		})
+
+		It("errors on patterns referring to themselves", func() {
+			_, err := MustNewConfigFromMap(map[string][]string{
+				"<host*>.internal": {"<host>.apps.internal"},
+			}).ReducedForm()
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("failed to resolve <host*>.internal.: recursion detected"))
+		})
+
+		It("errors on cycles between static and pattern aliases", func() {
+			_, err := MustNewConfigFromMap(map[string][]string{
+				"web.internal":        {"web.apps.internal"},
+				"<svc>.apps.internal": {"<svc>.internal"},
+			}).ReducedForm()
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("recursion detected"))
+		})
+	})
+
+	Describe("IsReduced with pattern aliases", func() {
+		It("reports false when a static target matches a pattern", func() {
+			c := MustNewConfigFromMap(map[string][]string{
+				"alias1":              {"web.apps.internal"},
+				"<svc>.apps.internal": {"<svc>.bosh"},
+			})
+
+			Expect(c.IsReduced()).To(BeFalse())
+		})
+	})
+
 	Describe("AliasHosts", func() {
 		It("returns the set of hosts used by aliases", func() {
 			c := MustNewConfigFromMap(map[string][]string{
@@ -317,9 +496,106 @@ var _ = Describe("Config", func() {
 				"something.alias1": {"1.1.1.4"},
 				"a.b.c.":           {"1.1.1.5"},
 				"_.alias3":         {"1.1.1.6"},
+				"<a>.<b>.alias4":   {"1.1.1.7"},
+				"<a>.sub.alias5":   {"1.1.1.8"},
+				"<a*>.alias1":      {"1.1.1.9"},
 			})
 
-			Expect(c.AliasHosts()).To(ConsistOf("alias1.", "alias2.", "a.b.c.", "alias3."))
+			Expect(c.AliasHosts()).To(ConsistOf("alias1.", "alias2.", "a.b.c.", "alias3.", "alias4.", "sub.alias5."))
+		})
+	})
+
+	Describe("ServesLocally", func() {
+		var c Config
+
+		BeforeEach(func() {
+			c = MustNewConfigFromMap(map[string][]string{
+				"alias1":         {"1.1.1.1"},
+				"_.alias2":       {"1.1.1.2"},
+				"<a>.<b>.alias3": {"1.1.1.3"},
+			})
+		})
+
+		It("serves aliases and names below them", func() {
+			Expect(c.ServesLocally("alias1.")).To(BeTrue())
+			Expect(c.ServesLocally("sub.alias1.")).To(BeTrue())
+			Expect(c.ServesLocally("anything.alias2.")).To(BeTrue())
+			Expect(c.ServesLocally("ALIAS1.")).To(BeTrue())
 		})
+
+		It("serves names matching a pattern alias", func() {
+			Expect(c.ServesLocally("web.prod.alias3.")).To(BeTrue())
+		})
+
+		It("does not serve other names", func() {
+			Expect(c.ServesLocally("alias3.")).To(BeFalse())
+			Expect(c.ServesLocally("www.alias3.")).To(BeFalse())
+			Expect(c.ServesLocally("example.com.")).To(BeFalse())
+			Expect(c.ServesLocally("alias1.example.com.")).To(BeFalse())
+		})
+
+		It("serves the aliases of merged configs", func() {
+			c = c.Merge(MustNewConfigFromMap(map[string][]string{
+				"alias4":     {"1.1.1.4"},
+				"<a>.alias5": {"1.1.1.5"},
+			}))
+
+			Expect(c.ServesLocally("sub.alias4.")).To(BeTrue())
+			Expect(c.ServesLocally("web.alias5.")).To(BeTrue())
+			Expect(c.ServesLocally("sub.alias1.")).To(BeTrue())
+		})
+	})
+
+	Describe("HealthChecks", func() {
+		It("keeps the health checks of literal IP targets", func() {
+			var c Config
+			err := json.Unmarshal([]byte(`{
+				"db.internal": [
+					"q-s0.db.default.dep.bosh",
+					{"target": "10.0.0.1", "health_check": {"type": "tcp", "port": 5432}},
+					{"target": "10.0.0.2", "health_check": {"type": "http", "port": 8080, "timeout": "1s"}},
+					{"target": "10.0.0.3", "health_check": {"type": "none"}},
+					{"target": "10.0.0.4"}
+				]
+			}`), &c)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(c.Resolutions("db.internal.")).To(Equal([]string{"q-s0.db.default.dep.bosh.", "10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}))
+			Expect(c.HealthChecks()).To(Equal(map[string]TargetHealthCheck{
+				"10.0.0.1": {Type: "tcp", Port: 5432, Timeout: 5 * time.Second},
+				"10.0.0.2": {Type: "http", Port: 8080, Path: "/", ExpectedStatus: 200, Timeout: time.Second},
+				"10.0.0.3": {Type: "none"},
+			}))
+		})
+
+		It("prefers the health checks of the first config when merging", func() {
+			var first, second Config
+			Expect(json.Unmarshal([]byte(`{"a": [{"target": "10.0.0.1", "health_check": {"type": "none"}}]}`), &first)).To(Succeed())
+			Expect(json.Unmarshal([]byte(`{"b": [
+				{"target": "10.0.0.1", "health_check": {"type": "tcp", "port": 80}},
+				{"target": "10.0.0.2", "health_check": {"type": "tcp", "port": 80}}
+			]}`), &second)).To(Succeed())
+
+			Expect(first.Merge(second).HealthChecks()).To(Equal(map[string]TargetHealthCheck{
+				"10.0.0.1": {Type: "none"},
+				"10.0.0.2": {Type: "tcp", Port: 80, Timeout: 5 * time.Second},
+			}))
+		})
+
+		DescribeTable("returns an error", func(contents string, message string) {
+			var c Config
+			Expect(json.Unmarshal([]byte(contents), &c)).To(MatchError(message))
+		},
+			Entry("for domain targets", `{"a": [{"target": "db.internal", "health_check": {"type": "none"}}]}`,
+				"bad alias format: a has a health check for db.internal which is not an IP"),
+			Entry("for unknown types", `{"a": [{"target": "10.0.0.1", "health_check": {"type": "grpc", "port": 80}}]}`,
+				"bad alias format: a has a health check for 10.0.0.1 with unknown type 'grpc'"),
+			Entry("without a port", `{"a": [{"target": "10.0.0.1", "health_check": {"type": "tcp"}}]}`,
+				"bad alias format: a has a tcp health check for 10.0.0.1 without a port"),
+			Entry("for conflicting checks", `{"a": [{"target": "10.0.0.1", "health_check": {"type": "none"}}, {"target": "10.0.0.1", "health_check": {"type": "tcp", "port": 80}}]}`,
+				"bad alias format: a has a health check for 10.0.0.1 conflicting with another check of the same IP"),
+			Entry("for invalid timeouts", `{"a": [{"target": "10.0.0.1", "health_check": {"type": "tcp", "port": 80, "timeout": "soon"}}]}`,
+				`time: invalid duration "soon"`),
+		)
 	})
 })
@@ -0,0 +1,255 @@
+package aliases
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"github.com/miekg/dns"
+)
+
+var lintLabelRegexp = regexp.MustCompile(`^[a-zA-Z0-9_]([a-zA-Z0-9_-]*[a-zA-Z0-9_])?$`)
+
+type LintProblem struct {
+	File    string
+	Alias   string
+	Message string
+}
+
+func (p LintProblem) String() string {
+	if p.Alias == "" {
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", p.File, p.Alias, p.Message)
+}
+
+type Linter struct {
+	fs boshsys.FileSystem
+}
+
+type lintDefinition struct {
+	file    string
+	alias   string
+	targets []string
+}
+
+func NewLinter(fs boshsys.FileSystem) Linter {
+	return Linter{fs: fs}
+}
+
+// Lint loads the given alias files in order, the same way the server merges
+// them, and reports every problem found instead of stopping at the first one.
+// When recordSet is not nil, static targets below its domains are also
+// resolved against it; IPs and names outside them are external and skipped.
+func (l Linter) Lint(files []string, recordSet RecordSet) []LintProblem {
+	problems := []LintProblem{}
+	definitions := map[string]lintDefinition{}
+	merged := NewConfig()
+
+	for _, file := range files {
+		fileContents, err := l.fs.ReadFile(file)
+		if err != nil {
+			problems = append(problems, LintProblem{File: file, Message: fmt.Sprintf("cannot read alias file: %s", err)})
+			continue
+		}
+
+		primitive := map[string][]aliasTarget{}
+		err = json.Unmarshal(fileContents, &primitive)
+		if err != nil {
+			problems = append(problems, LintProblem{File: file, Message: fmt.Sprintf("alias file malformed: %s", err)})
+			continue
+		}
+
+		for _, alias := range sortedKeys(primitive) {
+			entry, err := newConfigFromTargets(map[string][]aliasTarget{alias: primitive[alias]})
+			if err != nil {
+				problems = append(problems, LintProblem{File: file, Alias: alias, Message: err.Error()})
+				continue
+			}
+
+			nameProblems := lintNames(alias, targetNames(primitive[alias]))
+			for _, message := range nameProblems {
+				problems = append(problems, LintProblem{File: file, Alias: alias, Message: message})
+			}
+
+			if len(nameProblems) > 0 {
+				continue
+			}
+
+			key := dns.Fqdn(alias)
+			targets := entry.targetsOf(key)
+
+			if previous, found := definitions[key]; found {
+				if !reflect.DeepEqual(previous.targets, targets) {
+					problems = append(problems, LintProblem{
+						File:    file,
+						Alias:   alias,
+						Message: fmt.Sprintf("conflicts with the definition in %s which takes precedence", previous.file),
+					})
+				}
+
+				continue
+			}
+
+			definitions[key] = lintDefinition{file: file, alias: alias, targets: targets}
+			merged = merged.Merge(entry)
+		}
+	}
+
+	cycles := false
+	for _, key := range sortedDefinitions(definitions) {
+		err := merged.lintRecursion(key)
+		if err != nil {
+			cycles = true
+			problems = append(problems, LintProblem{File: definitions[key].file, Alias: definitions[key].alias, Message: err.Error()})
+		}
+	}
+
+	if recordSet == nil || cycles {
+		return problems
+	}
+
+	reduced, err := merged.ReducedForm()
+	if err != nil {
+		return append(problems, LintProblem{Message: err.Error()})
+	}
+
+	domains := recordSet.Domains()
+
+	for _, key := range sortedDefinitions(definitions) {
+		for _, target := range reduced.targetsOf(key) {
+			if strings.HasPrefix(target, "_.") || captureReferenceRegexp.MatchString(target) || !isBelowDomains(target, domains) {
+				continue
+			}
+
+			ips, err := recordSet.Resolve(target)
+			if err != nil {
+				problems = append(problems, LintProblem{File: definitions[key].file, Alias: definitions[key].alias, Message: fmt.Sprintf("target %s failed to resolve: %s", target, err)})
+			} else if len(ips) == 0 {
+				problems = append(problems, LintProblem{File: definitions[key].file, Alias: definitions[key].alias, Message: fmt.Sprintf("target %s resolves to zero instances", target)})
+			}
+		}
+	}
+
+	return problems
+}
+
+func (c Config) targetsOf(alias string) []string {
+	if targets, found := c.aliases[alias]; found {
+		return targets
+	}
+
+	if strings.HasPrefix(alias, "_.") {
+		return c.underscoreAliases[strings.TrimPrefix(alias, "_.")]
+	}
+
+	for _, pattern := range c.patternAliases {
+		if pattern.pattern == alias {
+			return pattern.targets
+		}
+	}
+
+	return nil
+}
+
+func (c Config) lintRecursion(alias string) error {
+	if _, found := c.aliases[alias]; found {
+		_, err := c.reduce2(alias, 0)
+		return err
+	}
+
+	for _, target := range c.targetsOf(alias) {
+		_, err := c.reduce2(target, 1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func lintNames(alias string, targets []string) []string {
+	messages := []string{}
+
+	labels := dns.SplitDomainName(strings.TrimPrefix(alias, "_."))
+	for i, label := range labels {
+		if captureLabelRegexp.MatchString(label) {
+			labels[i] = "capture"
+		}
+	}
+
+	if !isValidLintName(strings.Join(labels, ".")) {
+		messages = append(messages, fmt.Sprintf("invalid alias name %s", alias))
+	}
+
+	for _, target := range targets {
+		if net.ParseIP(target) != nil {
+			continue
+		}
+
+		name := strings.TrimPrefix(strings.TrimPrefix(target, "_."), "*.")
+		name = captureReferenceRegexp.ReplaceAllString(name, "capture")
+		if !isValidLintName(name) {
+			messages = append(messages, fmt.Sprintf("invalid target name %s", target))
+		}
+	}
+
+	return messages
+}
+
+func isValidLintName(name string) bool {
+	name = dns.Fqdn(name)
+	if len(name) > 254 {
+		return false
+	}
+
+	for _, label := range dns.SplitDomainName(name) {
+		if len(label) > 63 || !lintLabelRegexp.MatchString(label) {
+			return false
+		}
+	}
+
+	return name != "."
+}
+
+func isBelowDomains(target string, domains []string) bool {
+	if net.ParseIP(target) != nil {
+		return false
+	}
+
+	for _, domain := range domains {
+		if dns.IsSubDomain(dns.Fqdn(domain), target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortedKeys(m map[string][]aliasTarget) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func sortedDefinitions(m map[string]lintDefinition) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
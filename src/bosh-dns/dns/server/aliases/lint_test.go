@@ -0,0 +1,156 @@
+package aliases_test
+
+import (
+	"errors"
+
+	. "bosh-dns/dns/server/aliases"
+	"bosh-dns/dns/server/aliases/aliasesfakes"
+
+	boshsysfakes "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Linter", func() {
+	var (
+		fs            *boshsysfakes.FakeFileSystem
+		fakeRecordSet *aliasesfakes.FakeRecordSet
+		linter        Linter
+	)
+
+	BeforeEach(func() {
+		fs = boshsysfakes.NewFakeFileSystem()
+		fakeRecordSet = &aliasesfakes.FakeRecordSet{}
+		fakeRecordSet.DomainsReturns([]string{"bosh."})
+		linter = NewLinter(fs)
+	})
+
+	Context("when the alias files are valid", func() {
+		BeforeEach(func() {
+			fs.WriteFileString("/a/aliases.json", `{
+				"alias1": ["alias2"],
+				"_.alias3": ["_.domain3"],
+				"<svc>.apps.internal": ["q-s0.<svc>.bosh"]
+			}`)
+			fs.WriteFileString("/b/aliases.json", `{
+				"alias2": ["q-s0.group.network.deployment.bosh", "1.1.1.1"]
+			}`)
+		})
+
+		It("reports no problems", func() {
+			Expect(linter.Lint([]string{"/a/aliases.json", "/b/aliases.json"}, nil)).To(BeEmpty())
+		})
+
+		It("resolves reduced static targets against the record set", func() {
+			fakeRecordSet.ResolveReturns([]string{"10.0.0.1"}, nil)
+
+			Expect(linter.Lint([]string{"/a/aliases.json", "/b/aliases.json"}, fakeRecordSet)).To(BeEmpty())
+
+			resolved := []string{}
+			for i := 0; i < fakeRecordSet.ResolveCallCount(); i++ {
+				resolved = append(resolved, fakeRecordSet.ResolveArgsForCall(i))
+			}
+
+			Expect(resolved).To(Equal([]string{
+				"q-s0.group.network.deployment.bosh.",
+				"q-s0.group.network.deployment.bosh.",
+			}))
+		})
+	})
+
+	It("reports files that cannot be read or parsed", func() {
+		fs.WriteFileString("/a/aliases.json", `{"alias1": "not-a-list"}`)
+
+		problems := linter.Lint([]string{"/a/aliases.json", "/missing/aliases.json"}, nil)
+		Expect(problems).To(HaveLen(2))
+		Expect(problems[0].File).To(Equal("/a/aliases.json"))
+		Expect(problems[0].Message).To(ContainSubstring("alias file malformed"))
+		Expect(problems[1].File).To(Equal("/missing/aliases.json"))
+		Expect(problems[1].Message).To(ContainSubstring("cannot read alias file"))
+	})
+
+	It("reports invalid names", func() {
+		fs.WriteFileString("/a/aliases.json", `{
+			"bad alias": ["domain"],
+			"alias1": ["-bad-target.bosh"],
+			"<svc*>.apps": ["<svc>.bosh"],
+			"web-<svc>.apps": ["domain"]
+		}`)
+
+		problems := linter.Lint([]string{"/a/aliases.json"}, nil)
+		Expect(problems).To(ConsistOf(
+			LintProblem{File: "/a/aliases.json", Alias: "alias1", Message: "invalid target name -bad-target.bosh"},
+			LintProblem{File: "/a/aliases.json", Alias: "bad alias", Message: "invalid alias name bad alias"},
+			LintProblem{File: "/a/aliases.json", Alias: "web-<svc>.apps", Message: "bad alias format: invalid capture label 'web-<svc>' in web-<svc>.apps."},
+		))
+	})
+
+	It("reports conflicting definitions between files", func() {
+		fs.WriteFileString("/a/aliases.json", `{"alias1": ["domain1"], "alias2": ["domain2"]}`)
+		fs.WriteFileString("/b/aliases.json", `{"alias1.": ["domain1"], "alias2": ["other"]}`)
+
+		problems := linter.Lint([]string{"/a/aliases.json", "/b/aliases.json"}, nil)
+		Expect(problems).To(Equal([]LintProblem{
+			{File: "/b/aliases.json", Alias: "alias2", Message: "conflicts with the definition in /a/aliases.json which takes precedence"},
+		}))
+	})
+
+	It("reports every alias taking part in a cycle", func() {
+		fs.WriteFileString("/a/aliases.json", `{"alias1": ["alias2"], "alias3": ["domain"]}`)
+		fs.WriteFileString("/b/aliases.json", `{"alias2": ["alias1"], "<svc>.internal": ["<svc>.internal"]}`)
+
+		problems := linter.Lint([]string{"/a/aliases.json", "/b/aliases.json"}, fakeRecordSet)
+		Expect(problems).To(Equal([]LintProblem{
+			{File: "/b/aliases.json", Alias: "<svc>.internal", Message: "recursion detected"},
+			{File: "/a/aliases.json", Alias: "alias1", Message: "recursion detected"},
+			{File: "/b/aliases.json", Alias: "alias2", Message: "recursion detected"},
+		}))
+		Expect(fakeRecordSet.ResolveCallCount()).To(Equal(0))
+	})
+
+	It("reports targets resolving to zero instances", func() {
+		fs.WriteFileString("/a/aliases.json", `{
+			"alias1": ["q-s0.empty.bosh", "q-s0.full.bosh"],
+			"alias2": ["q-s0.broken.bosh"]
+		}`)
+
+		fakeRecordSet.ResolveStub = func(domain string) ([]string, error) {
+			switch domain {
+			case "q-s0.full.bosh.":
+				return []string{"10.0.0.1"}, nil
+			case "q-s0.broken.bosh.":
+				return nil, errors.New("domain is malformed")
+			default:
+				return []string{}, nil
+			}
+		}
+
+		problems := linter.Lint([]string{"/a/aliases.json"}, fakeRecordSet)
+		Expect(problems).To(Equal([]LintProblem{
+			{File: "/a/aliases.json", Alias: "alias1", Message: "target q-s0.empty.bosh. resolves to zero instances"},
+			{File: "/a/aliases.json", Alias: "alias2", Message: "target q-s0.broken.bosh. failed to resolve: domain is malformed"},
+		}))
+	})
+
+	It("does not resolve external targets against the record set", func() {
+		fs.WriteFileString("/a/aliases.json", `{
+			"alias1": ["www.example.com", "1.1.1.1", "q-s0.empty.bosh"]
+		}`)
+
+		fakeRecordSet.ResolveReturns([]string{}, nil)
+
+		problems := linter.Lint([]string{"/a/aliases.json"}, fakeRecordSet)
+		Expect(problems).To(Equal([]LintProblem{
+			{File: "/a/aliases.json", Alias: "alias1", Message: "target q-s0.empty.bosh. resolves to zero instances"},
+		}))
+		Expect(fakeRecordSet.ResolveCallCount()).To(Equal(1))
+	})
+
+	Describe("LintProblem", func() {
+		It("formats the file, alias and message", func() {
+			Expect(LintProblem{File: "f", Alias: "a", Message: "m"}.String()).To(Equal("f: a: m"))
+			Expect(LintProblem{File: "f", Message: "m"}.String()).To(Equal("f: m"))
+		})
+	})
+})
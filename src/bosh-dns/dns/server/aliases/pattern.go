@@ -0,0 +1,171 @@
+package aliases
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	captureLabelRegexp     = regexp.MustCompile(`^<([a-zA-Z0-9_-]+)(\*)?>$`)
+	captureReferenceRegexp = regexp.MustCompile(`<([a-zA-Z0-9_-]+)>`)
+)
+
+// patternLabel is a single label of a pattern alias. It is either a literal
+// label which must match exactly, or a named capture. A multi-label capture
+// (`<name*>`) swallows one or more leading labels of the queried name.
+type patternLabel struct {
+	literal string
+	capture string
+	multi   bool
+}
+
+type patternAlias struct {
+	pattern string
+	labels  []patternLabel
+	targets []string
+}
+
+func isPatternAlias(alias string) bool {
+	return strings.ContainsAny(alias, "<>")
+}
+
+func newPatternAlias(pattern string, targets []string) (patternAlias, error) {
+	p := patternAlias{pattern: pattern, targets: targets}
+	captures := map[string]bool{}
+
+	splitPattern := dns.SplitDomainName(pattern)
+	for i, label := range splitPattern {
+		if !strings.ContainsAny(label, "<>") {
+			p.labels = append(p.labels, patternLabel{literal: label})
+			continue
+		}
+
+		match := captureLabelRegexp.FindStringSubmatch(label)
+		if match == nil {
+			return patternAlias{}, fmt.Errorf("bad alias format: invalid capture label '%s' in %s", label, pattern)
+		}
+
+		if captures[match[1]] {
+			return patternAlias{}, fmt.Errorf("bad alias format: duplicate capture '%s' in %s", match[1], pattern)
+		}
+
+		multi := match[2] != ""
+		if multi && i != 0 {
+			return patternAlias{}, fmt.Errorf("bad alias format: multi-label capture '%s' must be the first label in %s", match[1], pattern)
+		}
+
+		captures[match[1]] = true
+		p.labels = append(p.labels, patternLabel{capture: match[1], multi: multi})
+	}
+
+	if len(p.labels) == 0 || p.labels[len(p.labels)-1].capture != "" {
+		return patternAlias{}, fmt.Errorf("bad alias format: pattern %s must end with a literal label", pattern)
+	}
+
+	err := validateTargets(pattern, targets, captures)
+	if err != nil {
+		return patternAlias{}, err
+	}
+
+	return p, nil
+}
+
+func validateTargets(alias string, targets []string, captures map[string]bool) error {
+	for _, target := range targets {
+		for _, match := range captureReferenceRegexp.FindAllStringSubmatch(target, -1) {
+			if !captures[match[1]] {
+				return fmt.Errorf("bad alias format: target %s of %s references unknown capture '%s'", target, alias, match[1])
+			}
+		}
+
+		if strings.ContainsAny(captureReferenceRegexp.ReplaceAllString(target, ""), "<>") {
+			return fmt.Errorf("bad alias format: target %s of %s has an invalid capture reference", target, alias)
+		}
+	}
+
+	return nil
+}
+
+func (p patternAlias) match(domain string) (map[string]string, bool) {
+	splitDomain := dns.SplitDomainName(domain)
+
+	offset := len(splitDomain) - len(p.labels)
+	if offset < 0 || (offset > 0 && !p.labels[0].multi) {
+		return nil, false
+	}
+
+	captures := map[string]string{}
+
+	for i, label := range p.labels {
+		if label.multi {
+			captures[label.capture] = strings.Join(splitDomain[:offset+1], ".")
+			continue
+		}
+
+		domainLabel := splitDomain[i+offset]
+
+		if label.capture != "" {
+			captures[label.capture] = domainLabel
+		} else if label.literal != domainLabel {
+			return nil, false
+		}
+	}
+
+	return captures, true
+}
+
+func (p patternAlias) expand(captures map[string]string) []string {
+	expanded := []string{}
+
+	for _, target := range p.targets {
+		expanded = append(expanded, captureReferenceRegexp.ReplaceAllStringFunc(target, func(reference string) string {
+			return captures[reference[1:len(reference)-1]]
+		}))
+	}
+
+	return expanded
+}
+
+// host is the literal suffix following the last capture of the pattern.
+func (p patternAlias) host() string {
+	suffix := []string{}
+
+	for i := len(p.labels) - 1; i >= 0 && p.labels[i].capture == ""; i-- {
+		suffix = append([]string{p.labels[i].literal}, suffix...)
+	}
+
+	return dns.Fqdn(strings.Join(suffix, "."))
+}
+
+func (p patternAlias) literalCount() int {
+	count := 0
+
+	for _, label := range p.labels {
+		if label.capture == "" {
+			count++
+		}
+	}
+
+	return count
+}
+
+// sortPatternAliases orders patterns so that the most specific one is matched
+// first: more literal labels win, then single-label captures over multi-label
+// ones, and finally the pattern text itself to keep the order deterministic.
+func sortPatternAliases(patterns []patternAlias) {
+	sort.SliceStable(patterns, func(i, j int) bool {
+		if patterns[i].literalCount() != patterns[j].literalCount() {
+			return patterns[i].literalCount() > patterns[j].literalCount()
+		}
+
+		if patterns[i].labels[0].multi != patterns[j].labels[0].multi {
+			return !patterns[i].labels[0].multi
+		}
+
+		return patterns[i].pattern < patterns[j].pattern
+	})
+}
@@ -7,21 +7,39 @@ import (
 	"github.com/miekg/dns"
 )
 
+//go:generate counterfeiter . LocalNameChecker
+
+type LocalNameChecker interface {
+	IsLocal(string) bool
+}
+
+// DiscoveryHandler is registered for the zones served locally. Pattern
+// aliases register the zone of their literal suffix, in which the names that
+// do not match the pattern are still passed to the forward handler.
 type DiscoveryHandler struct {
 	logger      logger.Logger
 	logTag      string
 	localDomain dnsresolver.LocalDomain
+	localNames  LocalNameChecker
+	forward     dns.Handler
 }
 
-func NewDiscoveryHandler(logger logger.Logger, localDomain dnsresolver.LocalDomain) DiscoveryHandler {
+func NewDiscoveryHandler(logger logger.Logger, localDomain dnsresolver.LocalDomain, localNames LocalNameChecker, forward dns.Handler) DiscoveryHandler {
 	return DiscoveryHandler{
 		logger:      logger,
 		logTag:      "DiscoveryHandler",
 		localDomain: localDomain,
+		localNames:  localNames,
+		forward:     forward,
 	}
 }
 
 func (d DiscoveryHandler) ServeDNS(responseWriter dns.ResponseWriter, requestMsg *dns.Msg) {
+	if len(requestMsg.Question) > 0 && !d.localNames.IsLocal(requestMsg.Question[0].Name) {
+		d.forward.ServeDNS(responseWriter, requestMsg)
+		return
+	}
+
 	responseMsg := &dns.Msg{}
 
 	if len(requestMsg.Question) > 0 {
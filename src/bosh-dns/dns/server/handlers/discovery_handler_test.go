@@ -4,7 +4,10 @@ import (
 	"errors"
 	"net"
 
+	"bosh-dns/dns/server/aliases"
+	"bosh-dns/dns/server/aliases/aliasesfakes"
 	"bosh-dns/dns/server/handlers"
+	"bosh-dns/dns/server/handlers/handlersfakes"
 	"bosh-dns/dns/server/internal/internalfakes"
 	"bosh-dns/dns/server/records/dnsresolver"
 	"bosh-dns/dns/server/records/dnsresolver/dnsresolverfakes"
@@ -24,6 +27,8 @@ var _ = Describe("DiscoveryHandler", func() {
 			fakeLogger       *loggerfakes.FakeLogger
 			fakeRecordSet    *dnsresolverfakes.FakeRecordSet
 			fakeShuffler     *dnsresolverfakes.FakeAnswerShuffler
+			fakeLocalNames   *handlersfakes.FakeLocalNameChecker
+			forwarded        []string
 		)
 
 		BeforeEach(func() {
@@ -36,7 +41,49 @@ var _ = Describe("DiscoveryHandler", func() {
 			}
 
 			fakeWriter.RemoteAddrReturns(&net.UDPAddr{})
-			discoveryHandler = handlers.NewDiscoveryHandler(fakeLogger, dnsresolver.NewLocalDomain(fakeLogger, fakeRecordSet, fakeShuffler))
+			fakeLocalNames = &handlersfakes.FakeLocalNameChecker{}
+			fakeLocalNames.IsLocalReturns(true)
+			forwarded = []string{}
+			forward := dns.HandlerFunc(func(_ dns.ResponseWriter, req *dns.Msg) {
+				forwarded = append(forwarded, req.Question[0].Name)
+			})
+
+			discoveryHandler = handlers.NewDiscoveryHandler(fakeLogger, dnsresolver.NewLocalDomain(fakeLogger, fakeRecordSet, fakeShuffler), fakeLocalNames, forward)
+		})
+
+		Context("when the name is not served locally", func() {
+			BeforeEach(func() {
+				boshRecordSet := &aliasesfakes.FakeRecordSet{}
+				boshRecordSet.DomainsReturns([]string{"bosh."})
+
+				aliasedRecordSet := aliases.NewAliasedRecordSet(boshRecordSet, aliases.MustNewConfigFromMap(map[string][]string{
+					"<svc>.example.com": {"q-s0.<svc>.default.bosh"},
+				}))
+
+				discoveryHandler = handlers.NewDiscoveryHandler(fakeLogger, dnsresolver.NewLocalDomain(fakeLogger, fakeRecordSet, fakeShuffler), aliasedRecordSet, dns.HandlerFunc(func(_ dns.ResponseWriter, req *dns.Msg) {
+					forwarded = append(forwarded, req.Question[0].Name)
+				}))
+			})
+
+			It("recurses for sibling names below the suffix of a pattern alias", func() {
+				for _, name := range []string{"example.com.", "a.b.example.com."} {
+					m := &dns.Msg{}
+					m.SetQuestion(name, dns.TypeA)
+					discoveryHandler.ServeDNS(fakeWriter, m)
+				}
+
+				Expect(forwarded).To(Equal([]string{"example.com.", "a.b.example.com."}))
+				Expect(fakeWriter.WriteMsgCallCount()).To(Equal(0))
+			})
+
+			It("serves names matching the pattern alias", func() {
+				m := &dns.Msg{}
+				m.SetQuestion("web.example.com.", dns.TypeMX)
+				discoveryHandler.ServeDNS(fakeWriter, m)
+
+				Expect(forwarded).To(BeEmpty())
+				Expect(fakeWriter.WriteMsgCallCount()).To(Equal(1))
+			})
 		})
 
 		Context("when there are no questions", func() {
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"crypto/tls"
 	"time"
 
 	"github.com/miekg/dns"
@@ -8,8 +9,22 @@ import (
 
 type ExchangerFactory func(string) Exchanger
 
-func NewExchangerFactory(timeout time.Duration) ExchangerFactory {
+// NewExchangerFactory returns plain DNS clients for the udp and tcp
+// networks. For the tcp-tls and https networks it always returns the same
+// exchanger, which keeps connections to DNS-over-TLS and DNS-over-HTTPS
+// recursors open.
+func NewExchangerFactory(timeout time.Duration, tlsConfig *tls.Config) ExchangerFactory {
+	tlsExchanger := newTLSExchanger(tlsConfig, timeout)
+	httpsExchanger := newHTTPSExchanger(tlsConfig, timeout)
+
 	return func(net string) Exchanger {
+		switch net {
+		case "tcp-tls":
+			return tlsExchanger
+		case "https":
+			return httpsExchanger
+		}
+
 		return &dns.Client{Net: net, Timeout: timeout, UDPSize: 65535}
 	}
 }
@@ -18,7 +18,7 @@ var _ = Describe("ExchangerFactory", func() {
 		net := fmt.Sprintf("net-%d", rand.Int())
 		timeout := time.Duration(rand.Int())
 
-		exchangerFactory := handlers.NewExchangerFactory(timeout)
+		exchangerFactory := handlers.NewExchangerFactory(timeout, nil)
 		exchanger := exchangerFactory(net)
 
 		Expect(exchanger).To(BeAssignableToTypeOf(&dns.Client{}))
@@ -27,4 +27,21 @@ var _ = Describe("ExchangerFactory", func() {
 		Expect(client.Net).To(Equal(net))
 		Expect(client.Timeout).To(Equal(timeout))
 	})
+
+	It("returns the same exchanger for DNS-over-TLS recursors", func() {
+		exchangerFactory := handlers.NewExchangerFactory(time.Second, nil)
+
+		exchanger := exchangerFactory("tcp-tls")
+		Expect(exchanger).NotTo(BeAssignableToTypeOf(&dns.Client{}))
+		Expect(exchangerFactory("tcp-tls")).To(BeIdenticalTo(exchanger))
+	})
+
+	It("returns the same exchanger for DNS-over-HTTPS recursors", func() {
+		exchangerFactory := handlers.NewExchangerFactory(time.Second, nil)
+
+		exchanger := exchangerFactory("https")
+		Expect(exchanger).NotTo(BeAssignableToTypeOf(&dns.Client{}))
+		Expect(exchangerFactory("https")).To(BeIdenticalTo(exchanger))
+		Expect(exchangerFactory("tcp-tls")).NotTo(BeIdenticalTo(exchanger))
+	})
 })
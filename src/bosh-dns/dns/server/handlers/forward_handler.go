@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
+	"bosh-dns/dns/config"
+
 	"code.cloudfoundry.org/clock"
 
 	"github.com/cloudfoundry/bosh-utils/logger"
@@ -54,27 +57,54 @@ func (r ForwardHandler) ServeDNS(responseWriter dns.ResponseWriter, request *dns
 
 	client := r.exchangerFactory(network)
 
+	// the recursor pool may query several recursors at once, only the first
+	// answer is written
+	var (
+		answer     *dns.Msg
+		answeredBy string
+		answerLock sync.Mutex
+	)
+
 	err := r.recursors.PerformStrategically(func(recursor string) error {
-		exchangeAnswer, _, err := client.Exchange(request, recursor)
-		if err == nil || err == dns.ErrTruncated {
-			response := r.compressIfNeeded(responseWriter, request, exchangeAnswer)
+		exchanger, address := client, recursor
+		switch {
+		case strings.HasPrefix(recursor, config.TLSRecursorScheme):
+			exchanger, address = r.exchangerFactory("tcp-tls"), strings.TrimPrefix(recursor, config.TLSRecursorScheme)
+		case strings.HasPrefix(recursor, config.HTTPSRecursorScheme):
+			exchanger = r.exchangerFactory("https")
+		}
+
+		exchangeAnswer, _, err := exchanger.Exchange(request, address)
+		if err != nil && err != dns.ErrTruncated {
+			r.logger.Debug(r.logTag, "error recursing to %q: %s", recursor, err.Error())
+			return err
+		}
 
-			if writeErr := responseWriter.WriteMsg(response); writeErr != nil {
-				r.logger.Error(r.logTag, "error writing response: %s", writeErr.Error())
-			} else {
-				r.logRecursor(before, request, response.Rcode, "recursor="+recursor)
-			}
+		answerLock.Lock()
+		defer answerLock.Unlock()
 
-			return nil
+		if answer == nil {
+			answer, answeredBy = exchangeAnswer, recursor
 		}
 
-		r.logger.Debug(r.logTag, "error recursing to %q: %s", recursor, err.Error())
-		return err
+		return nil
 	})
 
 	if err != nil {
 		r.writeNoResponseMessage(responseWriter, request)
 		r.logRecursor(before, request, dns.RcodeServerFailure, err.Error())
+		return
+	}
+
+	answerLock.Lock()
+	defer answerLock.Unlock()
+
+	response := r.compressIfNeeded(responseWriter, request, answer)
+
+	if writeErr := responseWriter.WriteMsg(response); writeErr != nil {
+		r.logger.Error(r.logTag, "error writing response: %s", writeErr.Error())
+	} else {
+		r.logRecursor(before, request, response.Rcode, "recursor="+answeredBy)
 	}
 }
 
@@ -385,6 +385,113 @@ var _ = Describe("ForwardHandler", func() {
 					Expect(fmt.Sprintf(msg, args...)).To(Equal("error writing response: failed to write message"))
 				})
 			})
+
+			Context("when a recursor uses DNS-over-TLS", func() {
+				var (
+					tlsExchanger *handlersfakes.FakeExchanger
+					nets         []string
+				)
+
+				BeforeEach(func() {
+					nets = []string{}
+					tlsExchanger = &handlersfakes.FakeExchanger{}
+					tlsExchanger.ExchangeReturns(&dns.Msg{}, 0, nil)
+
+					fakeExchangerFactory = func(net string) handlers.Exchanger {
+						nets = append(nets, net)
+						if net == "tcp-tls" {
+							return tlsExchanger
+						}
+						return fakeExchanger
+					}
+
+					fakeRecursorPool.PerformStrategicallyStub = func(f func(string) error) error {
+						return f("tls://9.9.9.9:853#dns.quad9.net")
+					}
+
+					recursionHandler = handlers.NewForwardHandler(fakeRecursorPool, fakeExchangerFactory, fakeClock, fakeLogger)
+				})
+
+				It("exchanges over TLS with the address without its scheme", func() {
+					m := &dns.Msg{}
+					m.SetQuestion("example.com.", dns.TypeANY)
+
+					recursionHandler.ServeDNS(fakeWriter, m)
+
+					Expect(nets).To(ContainElement("tcp-tls"))
+					Expect(fakeExchanger.ExchangeCallCount()).To(Equal(0))
+					Expect(tlsExchanger.ExchangeCallCount()).To(Equal(1))
+
+					_, recursor := tlsExchanger.ExchangeArgsForCall(0)
+					Expect(recursor).To(Equal("9.9.9.9:853#dns.quad9.net"))
+				})
+			})
+
+			Context("when the recursor pool queries several recursors", func() {
+				BeforeEach(func() {
+					fakeExchanger.ExchangeStub = func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+						return &dns.Msg{Answer: []dns.RR{&dns.A{A: net.ParseIP(address)}}}, 0, nil
+					}
+
+					fakeRecursorPool.PerformStrategicallyStub = func(f func(string) error) error {
+						Expect(f("10.244.5.4")).To(Succeed())
+						Expect(f("127.0.0.1")).To(Succeed())
+						return nil
+					}
+				})
+
+				It("only writes the first answer", func() {
+					m := &dns.Msg{}
+					m.SetQuestion("example.com.", dns.TypeANY)
+
+					recursionHandler.ServeDNS(fakeWriter, m)
+
+					Expect(fakeWriter.WriteMsgCallCount()).To(Equal(1))
+					message := fakeWriter.WriteMsgArgsForCall(0)
+					Expect(message.Answer).To(Equal([]dns.RR{&dns.A{A: net.ParseIP("10.244.5.4")}}))
+				})
+			})
+
+			Context("when a recursor uses DNS-over-HTTPS", func() {
+				var (
+					httpsExchanger *handlersfakes.FakeExchanger
+					nets           []string
+				)
+
+				BeforeEach(func() {
+					nets = []string{}
+					httpsExchanger = &handlersfakes.FakeExchanger{}
+					httpsExchanger.ExchangeReturns(&dns.Msg{}, 0, nil)
+
+					fakeExchangerFactory = func(net string) handlers.Exchanger {
+						nets = append(nets, net)
+						if net == "https" {
+							return httpsExchanger
+						}
+						return fakeExchanger
+					}
+
+					fakeRecursorPool.PerformStrategicallyStub = func(f func(string) error) error {
+						return f("https://dns.google/dns-query")
+					}
+
+					recursionHandler = handlers.NewForwardHandler(fakeRecursorPool, fakeExchangerFactory, fakeClock, fakeLogger)
+				})
+
+				It("exchanges over HTTPS with the url of the recursor", func() {
+					m := &dns.Msg{}
+					m.SetQuestion("example.com.", dns.TypeANY)
+
+					recursionHandler.ServeDNS(fakeWriter, m)
+
+					Expect(nets).To(ContainElement("https"))
+					Expect(fakeExchanger.ExchangeCallCount()).To(Equal(0))
+					Expect(httpsExchanger.ExchangeCallCount()).To(Equal(1))
+
+					_, recursor := httpsExchanger.ExchangeArgsForCall(0)
+					Expect(recursor).To(Equal("https://dns.google/dns-query"))
+				})
+			})
 		})
 	})
 })
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"strings"
 
 	"bosh-dns/dns/config"
 
@@ -26,6 +27,10 @@ func (l FSLoader) Load(filename string) (Config, error) {
 	}
 
 	for i := range handlers {
+		if handlers[i].Source.Type == "https" && !strings.HasPrefix(handlers[i].Source.URL, config.HTTPSRecursorScheme) {
+			return Config{}, bosherr.Errorf("handler for '%s' of type https requires an https url", handlers[i].Domain)
+		}
+
 		handlers[i].Source.Recursors, err = config.AppendDefaultDNSPortIfMissing(handlers[i].Source.Recursors)
 		if err != nil {
 			return Config{}, err
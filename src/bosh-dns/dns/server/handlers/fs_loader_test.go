@@ -72,6 +72,35 @@ var _ = Describe("FSLoader", func() {
 			})
 		})
 
+		Context("DNS-over-HTTPS handlers", func() {
+			It("parses the url of the recursor", func() {
+				fs.WriteFileString("/test/handlers.json", `[
+					{
+						"domain": "local.internal.",
+						"source": { "type": "https", "url": "https://dns.local.internal/dns-query"}
+					}
+				]`)
+
+				config, err := parser.Load("/test/handlers.json")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(config.Handlers[0].Source.Type).To(Equal("https"))
+				Expect(config.Handlers[0].Source.URL).To(Equal("https://dns.local.internal/dns-query"))
+			})
+
+			It("errors when the url is not an https url", func() {
+				fs.WriteFileString("/test/handlers.json", `[
+					{
+						"domain": "local.internal.",
+						"source": { "type": "https", "url": "http://dns.local.internal/dns-query"}
+					}
+				]`)
+
+				_, err := parser.Load("/test/handlers.json")
+				Expect(err).To(MatchError("handler for 'local.internal.' of type https requires an https url"))
+			})
+		})
+
 		Context("missing file", func() {
 			It("errors", func() {
 				_, err := parser.Load("/test/handlers.json")
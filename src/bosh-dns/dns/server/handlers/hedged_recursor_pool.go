@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// HedgingMetrics counts how often the queries of hedged recursor pools were
+// also sent to another recursor, and how often that recursor answered first.
+type HedgingMetrics struct {
+	queries       uint64
+	hedged        uint64
+	hedgeWins     uint64
+	hedgesSkipped uint64
+}
+
+type HedgingReport struct {
+	Queries       uint64 `json:"queries"`
+	Hedged        uint64 `json:"hedged"`
+	HedgeWins     uint64 `json:"hedge_wins"`
+	HedgesSkipped uint64 `json:"hedges_skipped"`
+}
+
+func NewHedgingMetrics() *HedgingMetrics {
+	return &HedgingMetrics{}
+}
+
+func (m *HedgingMetrics) Report() HedgingReport {
+	return HedgingReport{
+		Queries:       atomic.LoadUint64(&m.queries),
+		Hedged:        atomic.LoadUint64(&m.hedged),
+		HedgeWins:     atomic.LoadUint64(&m.hedgeWins),
+		HedgesSkipped: atomic.LoadUint64(&m.hedgesSkipped),
+	}
+}
+
+// DefaultRecursorPool names the pool of the recursors answering the queries
+// no handler is configured for.
+const DefaultRecursorPool = "default"
+
+// HedgingRegistry keeps the hedging metrics of each hedged recursor pool,
+// keyed by the domain of its handler, or DefaultRecursorPool.
+type HedgingRegistry struct {
+	metrics map[string]*HedgingMetrics
+	mutex   *sync.Mutex
+}
+
+func NewHedgingRegistry() *HedgingRegistry {
+	return &HedgingRegistry{
+		metrics: map[string]*HedgingMetrics{},
+		mutex:   &sync.Mutex{},
+	}
+}
+
+// Metrics returns the metrics of the named pool, which are created on first
+// use.
+func (r *HedgingRegistry) Metrics(pool string) *HedgingMetrics {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	metrics, found := r.metrics[pool]
+	if !found {
+		metrics = NewHedgingMetrics()
+		r.metrics[pool] = metrics
+	}
+
+	return metrics
+}
+
+func (r *HedgingRegistry) Report() map[string]HedgingReport {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	reports := map[string]HedgingReport{}
+	for pool, metrics := range r.metrics {
+		reports[pool] = metrics.Report()
+	}
+
+	return reports
+}
+
+type hedgedRecursorPool struct {
+	preferredRecursorIndex uint64
+
+	clock      clock.Clock
+	hedgeDelay time.Duration
+	hedges     chan struct{}
+	metrics    *HedgingMetrics
+
+	logger    logger.Logger
+	logTag    string
+	recursors []string
+}
+
+type hedgedResult struct {
+	index int
+	hedge bool
+	err   error
+}
+
+// NewHedgedRecursorPool queries the preferred recursor and, when it has not
+// answered within hedgeDelay, also the next one, and so on. The first answer
+// wins and its recursor becomes the preferred one. A failed query is
+// immediately followed by a query to the next recursor. At most maxHedges
+// hedged queries of the pool are in flight at once. With a maxHedges of 0
+// queries are never hedged, so recursors are only queried in turn and no
+// metrics are recorded.
+func NewHedgedRecursorPool(recursors []string, hedgeDelay time.Duration, maxHedges int, metrics *HedgingMetrics, clock clock.Clock, logger logger.Logger) RecursorPool {
+	if recursors == nil {
+		recursors = []string{}
+	}
+
+	logTag := "HedgedRecursor"
+	if len(recursors) > 0 {
+		logger.Info(logTag, fmt.Sprintf("starting preference: %s\n", recursors[0]))
+	}
+
+	return &hedgedRecursorPool{
+		clock:      clock,
+		hedgeDelay: hedgeDelay,
+		hedges:     make(chan struct{}, maxHedges),
+		metrics:    metrics,
+		logger:     logger,
+		logTag:     logTag,
+		recursors:  recursors,
+	}
+}
+
+func (q *hedgedRecursorPool) PerformStrategically(work func(string) error) error {
+	recursorCount := len(q.recursors)
+	if recursorCount == 0 {
+		return errors.New("no response from recursors")
+	}
+
+	offset := int(atomic.LoadUint64(&q.preferredRecursorIndex) % uint64(recursorCount))
+
+	if cap(q.hedges) == 0 {
+		return q.performInTurn(work, offset)
+	}
+
+	atomic.AddUint64(&q.metrics.queries, 1)
+
+	// buffered, so that queries still in flight after the first answer do
+	// not block
+	results := make(chan hedgedResult, recursorCount)
+	next, inFlight, hedged := 0, 0, false
+
+	perform := func(hedge bool) {
+		index := (offset + next) % recursorCount
+		next++
+		inFlight++
+
+		go func() {
+			err := work(q.recursors[index])
+			if hedge {
+				<-q.hedges
+			}
+			results <- hedgedResult{index: index, hedge: hedge, err: err}
+		}()
+	}
+
+	perform(false)
+
+	timer := q.clock.NewTimer(q.hedgeDelay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case result := <-results:
+			inFlight--
+
+			if result.err == nil {
+				if result.hedge {
+					atomic.AddUint64(&q.metrics.hedgeWins, 1)
+				}
+
+				if result.index != offset {
+					q.shiftPreference(result.index)
+				}
+
+				return nil
+			}
+
+			if next < recursorCount {
+				perform(false)
+
+				if !timer.Stop() {
+					<-timer.C()
+				}
+				timer.Reset(q.hedgeDelay)
+			} else if inFlight == 0 {
+				return errors.New("no response from recursors")
+			}
+		case <-timer.C():
+			if next >= recursorCount {
+				continue
+			}
+
+			select {
+			case q.hedges <- struct{}{}:
+				if !hedged {
+					hedged = true
+					atomic.AddUint64(&q.metrics.hedged, 1)
+				}
+
+				perform(true)
+				timer.Reset(q.hedgeDelay)
+			default:
+				// hedging is retried once the hedged queries in flight drop
+				// below the cap
+				atomic.AddUint64(&q.metrics.hedgesSkipped, 1)
+				timer.Reset(q.hedgeDelay)
+			}
+		}
+	}
+}
+
+func (q *hedgedRecursorPool) performInTurn(work func(string) error, offset int) error {
+	for i := 0; i < len(q.recursors); i++ {
+		index := (offset + i) % len(q.recursors)
+
+		if work(q.recursors[index]) == nil {
+			if index != offset {
+				q.shiftPreference(index)
+			}
+
+			return nil
+		}
+	}
+
+	return errors.New("no response from recursors")
+}
+
+func (q *hedgedRecursorPool) shiftPreference(index int) {
+	atomic.StoreUint64(&q.preferredRecursorIndex, uint64(index))
+	q.logger.Info(q.logTag, fmt.Sprintf("shifting recursor preference: %s\n", q.recursors[index]))
+}
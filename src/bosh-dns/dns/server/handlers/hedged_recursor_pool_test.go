@@ -0,0 +1,266 @@
+package handlers_test
+
+import (
+	. "bosh-dns/dns/server/handlers"
+
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"github.com/cloudfoundry/bosh-utils/logger/loggerfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HedgedRecursorPool", func() {
+	const hedgeDelay = 100 * time.Millisecond
+
+	var (
+		pool       RecursorPool
+		metrics    *HedgingMetrics
+		maxHedges  int
+		fakeClock  *fakeclock.FakeClock
+		fakeLogger *loggerfakes.FakeLogger
+		attempts   chan string
+		responses  map[string]chan error
+		work       func(string) error
+	)
+
+	BeforeEach(func() {
+		maxHedges = 10
+		metrics = NewHedgingMetrics()
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		fakeLogger = &loggerfakes.FakeLogger{}
+		attempts = make(chan string, 10)
+		responses = map[string]chan error{
+			"one":   make(chan error, 10),
+			"two":   make(chan error, 10),
+			"three": make(chan error, 10),
+		}
+
+		// queries may outlive their spec, so they must not read the variables
+		// reassigned by the next one
+		attempted, responded := attempts, responses
+		work = func(recursor string) error {
+			attempted <- recursor
+			return <-responded[recursor]
+		}
+	})
+
+	JustBeforeEach(func() {
+		pool = NewHedgedRecursorPool([]string{"one", "two", "three"}, hedgeDelay, maxHedges, metrics, fakeClock, fakeLogger)
+	})
+
+	perform := func() chan error {
+		result := make(chan error, 1)
+		go func() {
+			result <- pool.PerformStrategically(work)
+		}()
+		return result
+	}
+
+	It("returns an error if there are no recursors configured", func() {
+		pool = NewHedgedRecursorPool([]string{}, hedgeDelay, maxHedges, metrics, fakeClock, fakeLogger)
+		Expect(pool.PerformStrategically(func(string) error { return nil })).To(HaveOccurred())
+
+		pool = NewHedgedRecursorPool(nil, hedgeDelay, maxHedges, metrics, fakeClock, fakeLogger)
+		Expect(pool.PerformStrategically(func(string) error { return nil })).To(HaveOccurred())
+	})
+
+	It("only queries the preferred recursor when it answers within the hedge delay", func() {
+		responses["one"] <- nil
+
+		Eventually(perform()).Should(Receive(BeNil()))
+		Expect(attempts).To(Receive(Equal("one")))
+		Expect(attempts).NotTo(Receive())
+
+		Expect(metrics.Report()).To(Equal(HedgingReport{Queries: 1}))
+	})
+
+	Context("when the preferred recursor does not answer within the hedge delay", func() {
+		var result chan error
+
+		JustBeforeEach(func() {
+			result = perform()
+			Eventually(attempts).Should(Receive(Equal("one")))
+
+			fakeClock.WaitForWatcherAndIncrement(hedgeDelay)
+			Eventually(attempts).Should(Receive(Equal("two")))
+		})
+
+		It("takes the answer of the hedged query when it arrives first", func() {
+			responses["two"] <- nil
+			Eventually(result).Should(Receive(BeNil()))
+
+			Expect(metrics.Report()).To(Equal(HedgingReport{Queries: 1, Hedged: 1, HedgeWins: 1}))
+
+			responses["one"] <- nil
+		})
+
+		It("prefers the recursor of the hedged query afterwards", func() {
+			responses["two"] <- nil
+			Eventually(result).Should(Receive(BeNil()))
+			responses["one"] <- nil
+
+			responses["two"] <- nil
+			Eventually(perform()).Should(Receive(BeNil()))
+			Eventually(attempts).Should(Receive(Equal("two")))
+			Expect(attempts).NotTo(Receive())
+
+			_, msg, _ := fakeLogger.InfoArgsForCall(fakeLogger.InfoCallCount() - 1)
+			Expect(msg).To(Equal("shifting recursor preference: two\n"))
+		})
+
+		It("takes the answer of the preferred recursor when it arrives first", func() {
+			responses["one"] <- nil
+			Eventually(result).Should(Receive(BeNil()))
+
+			Expect(metrics.Report()).To(Equal(HedgingReport{Queries: 1, Hedged: 1}))
+
+			responses["two"] <- nil
+		})
+
+		It("keeps hedging with the next recursors", func() {
+			fakeClock.WaitForWatcherAndIncrement(hedgeDelay)
+			Eventually(attempts).Should(Receive(Equal("three")))
+
+			responses["three"] <- nil
+			Eventually(result).Should(Receive(BeNil()))
+
+			Expect(metrics.Report()).To(Equal(HedgingReport{Queries: 1, Hedged: 1, HedgeWins: 1}))
+
+			responses["one"] <- nil
+			responses["two"] <- nil
+		})
+	})
+
+	It("queries the next recursor immediately when the preferred one fails", func() {
+		responses["one"] <- errors.New("flaked out!")
+		responses["two"] <- nil
+
+		Eventually(perform()).Should(Receive(BeNil()))
+		Expect(attempts).To(Receive(Equal("one")))
+		Expect(attempts).To(Receive(Equal("two")))
+
+		Expect(metrics.Report()).To(Equal(HedgingReport{Queries: 1}))
+	})
+
+	It("returns an error when all recursors fail", func() {
+		for _, recursor := range []string{"one", "two", "three"} {
+			responses[recursor] <- errors.New("flaked out!")
+		}
+
+		Eventually(perform()).Should(Receive(MatchError("no response from recursors")))
+	})
+
+	Context("when hedging is disabled", func() {
+		BeforeEach(func() {
+			maxHedges = 0
+			metrics = nil
+		})
+
+		It("queries the recursors in turn without a hedge delay", func() {
+			result := perform()
+			Eventually(attempts).Should(Receive(Equal("one")))
+			Consistently(fakeClock.WatcherCount).Should(Equal(0))
+
+			responses["one"] <- errors.New("flaked out!")
+			Eventually(attempts).Should(Receive(Equal("two")))
+
+			responses["two"] <- nil
+			Eventually(result).Should(Receive(BeNil()))
+			Expect(fakeClock.WatcherCount()).To(Equal(0))
+		})
+
+		It("prefers the recursor which answered afterwards", func() {
+			responses["one"] <- errors.New("flaked out!")
+			responses["two"] <- nil
+			Eventually(perform()).Should(Receive(BeNil()))
+			Expect(attempts).To(Receive(Equal("one")))
+			Expect(attempts).To(Receive(Equal("two")))
+
+			responses["two"] <- nil
+			Eventually(perform()).Should(Receive(BeNil()))
+			Expect(attempts).To(Receive(Equal("two")))
+		})
+
+		It("returns an error when all recursors fail", func() {
+			for _, recursor := range []string{"one", "two", "three"} {
+				responses[recursor] <- errors.New("flaked out!")
+			}
+
+			Eventually(perform()).Should(Receive(MatchError("no response from recursors")))
+		})
+	})
+
+	Context("when the hedged queries in flight reach the cap", func() {
+		BeforeEach(func() {
+			maxHedges = 1
+		})
+
+		It("does not hedge", func() {
+			first := perform()
+			Eventually(attempts).Should(Receive(Equal("one")))
+			fakeClock.WaitForWatcherAndIncrement(hedgeDelay)
+			Eventually(attempts).Should(Receive(Equal("two")))
+
+			second := perform()
+			Eventually(attempts).Should(Receive(Equal("one")))
+			fakeClock.WaitForNWatchersAndIncrement(hedgeDelay, 2)
+
+			// neither can the first query hedge to the third recursor
+			Eventually(metrics.Report).Should(Equal(HedgingReport{Queries: 2, Hedged: 1, HedgesSkipped: 2}))
+			Consistently(attempts).ShouldNot(Receive())
+
+			responses["one"] <- nil
+			responses["one"] <- nil
+			Eventually(first).Should(Receive(BeNil()))
+			Eventually(second).Should(Receive(BeNil()))
+
+			responses["two"] <- nil
+		})
+
+		It("hedges once the hedged queries in flight drop below the cap", func() {
+			first := perform()
+			Eventually(attempts).Should(Receive(Equal("one")))
+			fakeClock.WaitForWatcherAndIncrement(hedgeDelay)
+			Eventually(attempts).Should(Receive(Equal("two")))
+
+			second := perform()
+			Eventually(attempts).Should(Receive(Equal("one")))
+			fakeClock.WaitForNWatchersAndIncrement(hedgeDelay, 2)
+			Eventually(metrics.Report).Should(Equal(HedgingReport{Queries: 2, Hedged: 1, HedgesSkipped: 2}))
+
+			responses["two"] <- nil
+			Eventually(first).Should(Receive(BeNil()))
+
+			fakeClock.WaitForWatcherAndIncrement(hedgeDelay)
+			Eventually(attempts).Should(Receive(Equal("two")))
+
+			responses["two"] <- nil
+			Eventually(second).Should(Receive(BeNil()))
+			Expect(metrics.Report()).To(Equal(HedgingReport{Queries: 2, Hedged: 2, HedgeWins: 2, HedgesSkipped: 2}))
+
+			responses["one"] <- nil
+			responses["one"] <- nil
+		})
+	})
+})
+
+var _ = Describe("HedgingRegistry", func() {
+	It("keeps the metrics of each pool", func() {
+		registry := NewHedgingRegistry()
+		Expect(registry.Report()).To(BeEmpty())
+
+		Expect(registry.Metrics(DefaultRecursorPool)).To(BeIdenticalTo(registry.Metrics(DefaultRecursorPool)))
+		Expect(registry.Metrics("corp.example.com.")).NotTo(BeIdenticalTo(registry.Metrics(DefaultRecursorPool)))
+
+		pool := NewHedgedRecursorPool([]string{"one"}, time.Second, 1, registry.Metrics("corp.example.com."), fakeclock.NewFakeClock(time.Now()), &loggerfakes.FakeLogger{})
+		Expect(pool.PerformStrategically(func(string) error { return nil })).To(Succeed())
+
+		Expect(registry.Report()).To(Equal(map[string]HedgingReport{
+			"default":           {},
+			"corp.example.com.": {Queries: 1},
+		}))
+	})
+})
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	dohMediaType   = "application/dns-message"
+	dohGetTemplate = "{?dns}"
+
+	// maxDoHMessageSize is the largest message that fits in the two byte
+	// length of DNS over TCP.
+	maxDoHMessageSize = 65535
+)
+
+// httpsExchanger exchanges queries with DNS-over-HTTPS recursors, as
+// described in RFC 8484. Connections are kept open and, when the recursor
+// supports it, queries are multiplexed over a single HTTP/2 connection.
+type httpsExchanger struct {
+	client *http.Client
+}
+
+func newHTTPSExchanger(tlsConfig *tls.Config, timeout time.Duration) *httpsExchanger {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+
+	// the certificate of a DNS-over-HTTPS recursor is always verified
+	// against the host of its URL
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.ServerName = ""
+
+	return &httpsExchanger{
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				TLSClientConfig:     tlsConfig,
+				ForceAttemptHTTP2:   true,
+				MaxIdleConnsPerHost: maxIdleTLSConns,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+	}
+}
+
+// Exchange POSTs the query to the recursor URL, or sends it as a GET request
+// when the URL ends in the {?dns} template. The ID of the query is sent as 0
+// so that responses can be cached by HTTP caches.
+func (e *httpsExchanger) Exchange(m *dns.Msg, recursorURL string) (*dns.Msg, time.Duration, error) {
+	query := m.Copy()
+	query.Id = 0
+
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var request *http.Request
+	if strings.HasSuffix(recursorURL, dohGetTemplate) {
+		request, err = http.NewRequest(
+			http.MethodGet,
+			strings.TrimSuffix(recursorURL, dohGetTemplate)+"?dns="+base64.RawURLEncoding.EncodeToString(packed),
+			nil,
+		)
+	} else {
+		request, err = http.NewRequest(http.MethodPost, recursorURL, bytes.NewReader(packed))
+		if err == nil {
+			request.Header.Set("Content-Type", dohMediaType)
+		}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	request.Header.Set("Accept", dohMediaType)
+
+	start := time.Now()
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, response.Body)
+		return nil, 0, fmt.Errorf("recursor responded with status %d", response.StatusCode)
+	}
+
+	if contentType := response.Header.Get("Content-Type"); contentType != dohMediaType {
+		io.Copy(ioutil.Discard, response.Body)
+		return nil, 0, fmt.Errorf("recursor responded with content type '%s'", contentType)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxDoHMessageSize))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	answer := &dns.Msg{}
+	err = answer.Unpack(body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	answer.Id = m.Id
+
+	return answer, time.Since(start), nil
+}
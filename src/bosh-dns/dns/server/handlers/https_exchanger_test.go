@@ -0,0 +1,156 @@
+package handlers_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bosh-dns/dns/server/handlers"
+	"github.com/miekg/dns"
+)
+
+type dohRequest struct {
+	method     string
+	protoMajor int
+	remoteAddr string
+	query      *dns.Msg
+}
+
+var _ = Describe("DNS-over-HTTPS exchanger", func() {
+	var (
+		server       *httptest.Server
+		requests     []dohRequest
+		requestsLock sync.Mutex
+		status       int
+		rootCAs      *x509.CertPool
+		exchanger    handlers.Exchanger
+	)
+
+	BeforeEach(func() {
+		requests = []dohRequest{}
+		status = http.StatusOK
+
+		server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var packed []byte
+			var err error
+			if r.Method == http.MethodGet {
+				packed, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+			} else {
+				Expect(r.Header.Get("Content-Type")).To(Equal("application/dns-message"))
+				packed, err = ioutil.ReadAll(r.Body)
+			}
+			Expect(err).NotTo(HaveOccurred())
+
+			query := &dns.Msg{}
+			Expect(query.Unpack(packed)).To(Succeed())
+
+			requestsLock.Lock()
+			requests = append(requests, dohRequest{method: r.Method, protoMajor: r.ProtoMajor, remoteAddr: r.RemoteAddr, query: query})
+			requestsLock.Unlock()
+
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+
+			answer := &dns.Msg{}
+			answer.SetReply(query)
+			answer.Answer = append(answer.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: query.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 0},
+				A:   net.ParseIP("10.0.0.2"),
+			})
+			packed, err = answer.Pack()
+			Expect(err).NotTo(HaveOccurred())
+
+			w.Header().Set("Content-Type", "application/dns-message")
+			w.Write(packed)
+		}))
+		server.EnableHTTP2 = true
+		server.StartTLS()
+
+		rootCAs = x509.NewCertPool()
+		rootCAs.AddCert(server.Certificate())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		exchanger = handlers.NewExchangerFactory(time.Second, &tls.Config{RootCAs: rootCAs})("https")
+	})
+
+	query := func(url string) (*dns.Msg, error) {
+		m := &dns.Msg{}
+		m.SetQuestion("example.com.", dns.TypeA)
+		m.Id = 4242
+
+		answer, _, err := exchanger.Exchange(m, url)
+		return answer, err
+	}
+
+	It("POSTs queries to the recursor", func() {
+		answer, err := query(server.URL + "/dns-query")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(answer.Id).To(Equal(uint16(4242)))
+		Expect(answer.Answer).To(HaveLen(1))
+		Expect(answer.Answer[0].(*dns.A).A.String()).To(Equal("10.0.0.2"))
+
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].method).To(Equal(http.MethodPost))
+		Expect(requests[0].query.Id).To(Equal(uint16(0)))
+		Expect(requests[0].query.Question[0].Name).To(Equal("example.com."))
+	})
+
+	It("sends queries as GET requests when the url ends in the dns template", func() {
+		answer, err := query(server.URL + "/dns-query{?dns}")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(answer.Answer).To(HaveLen(1))
+
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].method).To(Equal(http.MethodGet))
+		Expect(requests[0].query.Question[0].Name).To(Equal("example.com."))
+	})
+
+	It("reuses a single HTTP/2 connection", func() {
+		for i := 0; i < 3; i++ {
+			_, err := query(server.URL + "/dns-query")
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Expect(requests).To(HaveLen(3))
+		for _, request := range requests {
+			Expect(request.protoMajor).To(Equal(2))
+			Expect(request.remoteAddr).To(Equal(requests[0].remoteAddr))
+		}
+	})
+
+	It("fails when the recursor does not respond with OK", func() {
+		status = http.StatusBadRequest
+
+		_, err := query(server.URL + "/dns-query")
+		Expect(err).To(MatchError("recursor responded with status 400"))
+	})
+
+	Context("when the recursor is not trusted", func() {
+		BeforeEach(func() {
+			rootCAs = x509.NewCertPool()
+		})
+
+		It("fails", func() {
+			_, err := query(server.URL + "/dns-query")
+			Expect(err).To(HaveOccurred())
+			Expect(requests).To(BeEmpty())
+		})
+	})
+})
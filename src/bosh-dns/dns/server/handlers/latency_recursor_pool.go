@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// LatencyDecay is the weight of the latest query in the moving averages of
+// the latency and error rate of a recursor.
+const LatencyDecay = 0.3
+
+type latencyRecursorPool struct {
+	clock         clock.Clock
+	errorPenalty  time.Duration
+	probeInterval time.Duration
+
+	logger logger.Logger
+	logTag string
+
+	recursors []*recursorWithLatency
+	preferred *recursorWithLatency
+	lastProbe time.Time
+	mutex     *sync.Mutex
+}
+
+type recursorWithLatency struct {
+	name string
+
+	measured     bool
+	lastMeasured time.Time
+	rtt          float64
+	errorRate    float64
+}
+
+// NewLatencyRecursorPool prefers the recursor with the lowest moving average
+// of its latency, where each failed query counts as errorPenalty. Every
+// probeInterval, the query is first sent to the recursor measured the
+// longest ago instead, so that a recursor that has recovered is preferred
+// again.
+func NewLatencyRecursorPool(recursors []string, errorPenalty, probeInterval time.Duration, clock clock.Clock, logger logger.Logger) RecursorPool {
+	recursorsWithLatency := []*recursorWithLatency{}
+	for _, name := range recursors {
+		recursorsWithLatency = append(recursorsWithLatency, &recursorWithLatency{name: name})
+	}
+
+	logTag := "LatencyRecursor"
+	var preferred *recursorWithLatency
+	if len(recursorsWithLatency) > 0 {
+		preferred = recursorsWithLatency[0]
+		logger.Info(logTag, fmt.Sprintf("starting preference: %s\n", preferred.name))
+	}
+
+	return &latencyRecursorPool{
+		clock:         clock,
+		errorPenalty:  errorPenalty,
+		probeInterval: probeInterval,
+		logger:        logger,
+		logTag:        logTag,
+		recursors:     recursorsWithLatency,
+		preferred:     preferred,
+		lastProbe:     clock.Now(),
+		mutex:         &sync.Mutex{},
+	}
+}
+
+func (q *latencyRecursorPool) PerformStrategically(work func(string) error) error {
+	for _, recursor := range q.order() {
+		start := q.clock.Now()
+		err := work(recursor.name)
+		q.registerResult(recursor, q.clock.Since(start), err != nil)
+
+		if err == nil {
+			return nil
+		}
+	}
+
+	return errors.New("no response from recursors")
+}
+
+// order lists the recursors from the best to the worst score, unless a probe
+// is due.
+func (q *latencyRecursorPool) order() []*recursorWithLatency {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	ordered := make([]*recursorWithLatency, len(q.recursors))
+	copy(ordered, q.recursors)
+
+	// recursors that were never measured are tried before the others
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].measured != ordered[j].measured {
+			return !ordered[i].measured
+		}
+
+		return q.score(ordered[i]) < q.score(ordered[j])
+	})
+
+	if len(ordered) > 1 && q.clock.Since(q.lastProbe) >= q.probeInterval {
+		q.lastProbe = q.clock.Now()
+
+		probe := 1
+		for i := 2; i < len(ordered); i++ {
+			if ordered[i].lastMeasured.Before(ordered[probe].lastMeasured) {
+				probe = i
+			}
+		}
+
+		ordered[0], ordered[probe] = ordered[probe], ordered[0]
+	}
+
+	return ordered
+}
+
+func (q *latencyRecursorPool) score(recursor *recursorWithLatency) float64 {
+	return recursor.rtt + recursor.errorRate*float64(q.errorPenalty)
+}
+
+func (q *latencyRecursorPool) registerResult(recursor *recursorWithLatency, rtt time.Duration, wasError bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	errorSample := 0.0
+	if wasError {
+		errorSample = 1
+	}
+
+	if recursor.measured {
+		recursor.errorRate = LatencyDecay*errorSample + (1-LatencyDecay)*recursor.errorRate
+	} else {
+		recursor.errorRate = errorSample
+	}
+	recursor.measured = true
+
+	// the latency is only known once a query succeeded
+	if !wasError {
+		if recursor.rtt == 0 {
+			recursor.rtt = float64(rtt)
+		} else {
+			recursor.rtt = LatencyDecay*float64(rtt) + (1-LatencyDecay)*recursor.rtt
+		}
+	}
+	recursor.lastMeasured = q.clock.Now()
+
+	q.updatePreference()
+}
+
+func (q *latencyRecursorPool) updatePreference() {
+	var preferred *recursorWithLatency
+	for _, recursor := range q.recursors {
+		if !recursor.measured {
+			continue
+		}
+
+		if preferred == nil || q.score(recursor) < q.score(preferred) {
+			preferred = recursor
+		}
+	}
+
+	if preferred != nil && preferred != q.preferred {
+		q.preferred = preferred
+		q.logger.Info(q.logTag, fmt.Sprintf("shifting recursor preference: %s\n", preferred.name))
+	}
+}
@@ -0,0 +1,121 @@
+package handlers_test
+
+import (
+	. "bosh-dns/dns/server/handlers"
+
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"github.com/cloudfoundry/bosh-utils/logger/loggerfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LatencyRecursorPool", func() {
+	var (
+		pool       RecursorPool
+		fakeClock  *fakeclock.FakeClock
+		fakeLogger *loggerfakes.FakeLogger
+		latencies  map[string]time.Duration
+		failing    map[string]bool
+		attempts   []string
+		work       func(string) error
+	)
+
+	BeforeEach(func() {
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		fakeLogger = &loggerfakes.FakeLogger{}
+		latencies = map[string]time.Duration{
+			"one":   50 * time.Millisecond,
+			"two":   10 * time.Millisecond,
+			"three": 30 * time.Millisecond,
+		}
+		failing = map[string]bool{}
+		attempts = []string{}
+
+		work = func(recursor string) error {
+			attempts = append(attempts, recursor)
+			fakeClock.Increment(latencies[recursor])
+			if failing[recursor] {
+				return errors.New("flaked out!")
+			}
+			return nil
+		}
+	})
+
+	JustBeforeEach(func() {
+		pool = NewLatencyRecursorPool([]string{"one", "two", "three"}, 2*time.Second, time.Minute, fakeClock, fakeLogger)
+	})
+
+	performTimes := func(times int) {
+		for i := 0; i < times; i++ {
+			Expect(pool.PerformStrategically(work)).To(Succeed())
+		}
+	}
+
+	It("returns an error if there are no recursors configured", func() {
+		pool = NewLatencyRecursorPool([]string{}, time.Second, time.Minute, fakeClock, fakeLogger)
+		Expect(pool.PerformStrategically(func(string) error { return nil })).To(HaveOccurred())
+
+		pool = NewLatencyRecursorPool(nil, time.Second, time.Minute, fakeClock, fakeLogger)
+		Expect(pool.PerformStrategically(func(string) error { return nil })).To(HaveOccurred())
+	})
+
+	It("measures each recursor once before preferring the fastest", func() {
+		performTimes(10)
+
+		Expect(attempts[:3]).To(Equal([]string{"one", "two", "three"}))
+		for _, attempt := range attempts[3:] {
+			Expect(attempt).To(Equal("two"))
+		}
+
+		tag, msg, _ := fakeLogger.InfoArgsForCall(fakeLogger.InfoCallCount() - 1)
+		Expect(tag).To(Equal("LatencyRecursor"))
+		Expect(msg).To(Equal("shifting recursor preference: two\n"))
+	})
+
+	It("prefers another recursor once the preferred one becomes slow", func() {
+		performTimes(3)
+		latencies["two"] = time.Second
+
+		performTimes(10)
+
+		Expect(attempts[len(attempts)-1]).To(Equal("three"))
+	})
+
+	It("falls back to the next best recursor when the preferred one fails", func() {
+		performTimes(3)
+		failing["two"] = true
+
+		attempts = []string{}
+		performTimes(1)
+		Expect(attempts).To(Equal([]string{"two", "three"}))
+
+		attempts = []string{}
+		performTimes(1)
+		Expect(attempts).To(Equal([]string{"three"}))
+	})
+
+	It("returns an error when all recursors fail", func() {
+		failing["one"], failing["two"], failing["three"] = true, true, true
+
+		err := pool.PerformStrategically(work)
+		Expect(err).To(MatchError("no response from recursors"))
+		Expect(attempts).To(Equal([]string{"one", "two", "three"}))
+	})
+
+	It("periodically probes the recursor measured the longest ago", func() {
+		performTimes(3)
+
+		attempts = []string{}
+		performTimes(5)
+		Expect(attempts).To(Equal([]string{"two", "two", "two", "two", "two"}))
+
+		fakeClock.Increment(time.Minute)
+
+		attempts = []string{}
+		performTimes(2)
+		Expect(attempts).To(Equal([]string{"one", "two"}))
+	})
+})
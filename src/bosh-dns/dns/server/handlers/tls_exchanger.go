@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// maxIdleTLSConns bounds the idle connections kept open to each
+// DNS-over-TLS recursor.
+const maxIdleTLSConns = 8
+
+// tlsExchanger exchanges queries with DNS-over-TLS recursors. Connections are
+// kept open for later queries, as a handshake per query would be slow. The
+// address of a recursor may be followed by the name to verify its certificate
+// against, e.g. 1.1.1.1:853#cloudflare-dns.com.
+type tlsExchanger struct {
+	tlsConfig *tls.Config
+	timeout   time.Duration
+
+	recursors map[string]*tlsRecursor
+	mutex     *sync.Mutex
+}
+
+type tlsRecursor struct {
+	address   string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	idle      chan *dns.Conn
+}
+
+func newTLSExchanger(tlsConfig *tls.Config, timeout time.Duration) *tlsExchanger {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+
+	return &tlsExchanger{
+		tlsConfig: tlsConfig,
+		timeout:   timeout,
+		recursors: map[string]*tlsRecursor{},
+		mutex:     &sync.Mutex{},
+	}
+}
+
+func (e *tlsExchanger) Exchange(m *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	return e.recursor(address).exchange(m)
+}
+
+func (e *tlsExchanger) recursor(address string) *tlsRecursor {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if recursor, found := e.recursors[address]; found {
+		return recursor
+	}
+
+	tlsConfig := e.tlsConfig.Clone()
+	hostPort := address
+	if index := strings.Index(address, "#"); index >= 0 {
+		hostPort, tlsConfig.ServerName = address[:index], address[index+1:]
+	}
+
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName, _, _ = net.SplitHostPort(hostPort)
+	}
+
+	recursor := &tlsRecursor{
+		address:   hostPort,
+		tlsConfig: tlsConfig,
+		timeout:   e.timeout,
+		idle:      make(chan *dns.Conn, maxIdleTLSConns),
+	}
+	e.recursors[address] = recursor
+
+	return recursor
+}
+
+func (r *tlsRecursor) exchange(m *dns.Msg) (*dns.Msg, time.Duration, error) {
+	select {
+	case conn := <-r.idle:
+		answer, rtt, err := r.exchangeWithConn(conn, m)
+		if err == nil {
+			r.release(conn)
+			return answer, rtt, nil
+		}
+
+		conn.Close()
+
+		// the recursor may have closed the idle connection in the meantime,
+		// which is retried on a new connection. A recursor not answering in
+		// time is not asked again.
+		if !closedByRecursor(err) {
+			return nil, 0, err
+		}
+	default:
+	}
+
+	conn, err := dns.DialTimeoutWithTLS("tcp", r.address, r.tlsConfig, r.timeout)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	answer, rtt, err := r.exchangeWithConn(conn, m)
+	if err != nil {
+		conn.Close()
+		return nil, 0, err
+	}
+
+	r.release(conn)
+
+	return answer, rtt, nil
+}
+
+func (r *tlsRecursor) exchangeWithConn(conn *dns.Conn, m *dns.Msg) (*dns.Msg, time.Duration, error) {
+	start := time.Now()
+	if r.timeout > 0 {
+		conn.SetDeadline(start.Add(r.timeout))
+	}
+
+	if err := conn.WriteMsg(m); err != nil {
+		return nil, 0, tlsWriteError{err}
+	}
+
+	answer, err := conn.ReadMsg()
+	if err == nil && answer.Id != m.Id {
+		err = dns.ErrId
+	}
+
+	return answer, time.Since(start), err
+}
+
+func (r *tlsRecursor) release(conn *dns.Conn) {
+	select {
+	case r.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// tlsWriteError marks a query that could not be sent on a connection.
+type tlsWriteError struct {
+	error
+}
+
+func (e tlsWriteError) Unwrap() error {
+	return e.error
+}
+
+func closedByRecursor(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return false
+	}
+
+	if _, ok := err.(tlsWriteError); ok {
+		return true
+	}
+
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
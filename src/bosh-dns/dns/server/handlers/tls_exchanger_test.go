@@ -0,0 +1,220 @@
+package handlers_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bosh-dns/dns/server/handlers"
+	"bosh-dns/dns/server/internal/internalfakes"
+
+	"code.cloudfoundry.org/clock"
+	"github.com/cloudfoundry/bosh-utils/logger/loggerfakes"
+	"github.com/miekg/dns"
+)
+
+type trackingListener struct {
+	net.Listener
+	accepted chan net.Conn
+}
+
+func (l trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted <- conn
+	}
+	return conn, err
+}
+
+var _ = Describe("DNS-over-TLS exchanger", func() {
+	var (
+		server    *dns.Server
+		address   string
+		accepted  chan net.Conn
+		silent    int32
+		rootCAs   *x509.CertPool
+		timeout   time.Duration
+		exchanger handlers.Exchanger
+	)
+
+	BeforeEach(func() {
+		cert, caCert := generateRecursorCertificate("recursor.example.com")
+		rootCAs = x509.NewCertPool()
+		rootCAs.AddCert(caCert)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		address = listener.Addr().String()
+
+		accepted = make(chan net.Conn, 10)
+		silent = 0
+		timeout = time.Second
+		server = &dns.Server{
+			Listener: tls.NewListener(
+				trackingListener{Listener: listener, accepted: accepted},
+				&tls.Config{Certificates: []tls.Certificate{cert}},
+			),
+			Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+				if atomic.LoadInt32(&silent) == 1 {
+					return
+				}
+
+				m := &dns.Msg{}
+				m.SetReply(r)
+				m.Answer = append(m.Answer, &dns.A{
+					Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 0},
+					A:   net.ParseIP("10.0.0.1"),
+				})
+				w.WriteMsg(m)
+			}),
+		}
+
+		go server.ActivateAndServe()
+	})
+
+	AfterEach(func() {
+		server.Shutdown()
+	})
+
+	JustBeforeEach(func() {
+		exchanger = handlers.NewExchangerFactory(timeout, &tls.Config{RootCAs: rootCAs})("tcp-tls")
+	})
+
+	query := func(address string) (*dns.Msg, error) {
+		m := &dns.Msg{}
+		m.SetQuestion("example.com.", dns.TypeA)
+
+		answer, _, err := exchanger.Exchange(m, address)
+		return answer, err
+	}
+
+	It("answers queries from the recursor verified against the name after the address", func() {
+		answer, err := query(fmt.Sprintf("%s#recursor.example.com", address))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(answer.Answer).To(HaveLen(1))
+		Expect(answer.Answer[0].(*dns.A).A.String()).To(Equal("10.0.0.1"))
+	})
+
+	It("reuses the connection for later queries", func() {
+		for i := 0; i < 3; i++ {
+			_, err := query(fmt.Sprintf("%s#recursor.example.com", address))
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Expect(accepted).To(HaveLen(1))
+	})
+
+	It("retries on a new connection when the recursor closed the idle one", func() {
+		_, err := query(fmt.Sprintf("%s#recursor.example.com", address))
+		Expect(err).NotTo(HaveOccurred())
+
+		var conn net.Conn
+		Expect(accepted).To(Receive(&conn))
+		conn.Close()
+
+		answer, err := query(fmt.Sprintf("%s#recursor.example.com", address))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(answer.Answer).To(HaveLen(1))
+		Expect(accepted).To(HaveLen(1))
+	})
+
+	Context("when the recursor does not answer in time", func() {
+		BeforeEach(func() {
+			timeout = 100 * time.Millisecond
+		})
+
+		It("does not retry on a new connection", func() {
+			_, err := query(fmt.Sprintf("%s#recursor.example.com", address))
+			Expect(err).NotTo(HaveOccurred())
+
+			atomic.StoreInt32(&silent, 1)
+
+			_, err = query(fmt.Sprintf("%s#recursor.example.com", address))
+			Expect(err).To(HaveOccurred())
+			Expect(err.(net.Error).Timeout()).To(BeTrue())
+			Expect(accepted).To(HaveLen(1))
+		})
+	})
+
+	It("fails when the certificate does not match the name of the recursor", func() {
+		_, err := query(fmt.Sprintf("%s#other.example.com", address))
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("when it is a recursor of a failover pool", func() {
+		It("fails over to the next recursor when the certificate does not verify", func() {
+			fakeWriter := &internalfakes.FakeResponseWriter{}
+			fakeWriter.RemoteAddrReturns(&net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 53})
+			fakeLogger := &loggerfakes.FakeLogger{}
+
+			unverified := fmt.Sprintf("tls://%s#other.example.com", address)
+			verified := fmt.Sprintf("tls://%s#recursor.example.com", address)
+			forwardHandler := handlers.NewForwardHandler(
+				handlers.NewFailoverRecursorPool([]string{unverified, verified}, fakeLogger),
+				handlers.NewExchangerFactory(timeout, &tls.Config{RootCAs: rootCAs}),
+				clock.NewClock(),
+				fakeLogger,
+			)
+
+			m := &dns.Msg{}
+			m.SetQuestion("example.com.", dns.TypeA)
+			forwardHandler.ServeDNS(fakeWriter, m)
+
+			Expect(fakeWriter.WriteMsgCallCount()).To(Equal(1))
+			response := fakeWriter.WriteMsgArgsForCall(0)
+			Expect(response.Rcode).To(Equal(dns.RcodeSuccess))
+			Expect(response.Answer).To(HaveLen(1))
+			Expect(response.Answer[0].(*dns.A).A.String()).To(Equal("10.0.0.1"))
+
+			_, message, _ := fakeLogger.InfoArgsForCall(fakeLogger.InfoCallCount() - 1)
+			Expect(message).To(ContainSubstring("recursor=" + verified))
+		})
+	})
+
+	Context("when the recursor is not trusted", func() {
+		BeforeEach(func() {
+			rootCAs = x509.NewCertPool()
+		})
+
+		It("fails", func() {
+			_, err := query(fmt.Sprintf("%s#recursor.example.com", address))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+func generateRecursorCertificate(name string) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+
+	cert, err := x509.ParseCertificate(certDER)
+	Expect(err).NotTo(HaveOccurred())
+
+	return tls.Certificate{Certificate: [][]byte{certDER}, PrivateKey: key}, cert
+}
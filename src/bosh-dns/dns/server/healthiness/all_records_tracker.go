@@ -0,0 +1,188 @@
+package healthiness
+
+import (
+	"sort"
+	"time"
+
+	"bosh-dns/dns/server/records"
+
+	"code.cloudfoundry.org/clock"
+)
+
+//go:generate counterfeiter . AllRecordsSource
+
+type AllRecordsSource interface {
+	AllRecords() []records.Record
+	Subscribe() <-chan bool
+}
+
+//go:generate counterfeiter . IPTracker
+
+type IPTracker interface {
+	TrackIPs(ips []string)
+	UntrackIPs(ips []string)
+}
+
+// TrackSelector picks the records of a deployment and instance group. Empty
+// fields match any value.
+type TrackSelector struct {
+	Deployment string
+	Group      string
+}
+
+func (s TrackSelector) matches(record records.Record) bool {
+	return (s.Deployment == "" || s.Deployment == record.Deployment) &&
+		(s.Group == "" || s.Group == record.Group)
+}
+
+// AllRecordsTracker keeps the health of every selected record tracked ahead
+// of queries. New IPs are handed to the tracker at most rate per second so
+// that first checks of a large deployment do not all start at once.
+type AllRecordsTracker struct {
+	source    AllRecordsSource
+	tracker   IPTracker
+	selectors []TrackSelector
+	rate      int
+	clock     clock.Clock
+}
+
+func NewAllRecordsTracker(source AllRecordsSource, tracker IPTracker, selectors []TrackSelector, rate int, clock clock.Clock) *AllRecordsTracker {
+	if rate < 1 {
+		rate = 1
+	}
+
+	return &AllRecordsTracker{
+		source:    source,
+		tracker:   tracker,
+		selectors: selectors,
+		rate:      rate,
+		clock:     clock,
+	}
+}
+
+func (t *AllRecordsTracker) Run(shutdown chan struct{}) {
+	updates := t.source.Subscribe()
+
+	selected := t.selectedIPs()
+	tracked := map[string]struct{}{}
+
+	// the rate is spent in windows of a second, which record updates do not
+	// cut short
+	var windowStart time.Time
+	budget := 0
+
+	for {
+		if t.clock.Since(windowStart) >= time.Second {
+			windowStart = t.clock.Now()
+			budget = t.rate
+		}
+
+		budget -= t.admit(selected, tracked, budget)
+
+		if len(tracked) < len(selected) {
+			if !t.wait(time.Second-t.clock.Since(windowStart), updates, shutdown) {
+				return
+			}
+		} else {
+			select {
+			case ok := <-updates:
+				if !ok {
+					return
+				}
+			case <-shutdown:
+				return
+			}
+		}
+
+		selected = t.selectedIPs()
+	}
+}
+
+// wait returns after the given duration or when the records change,
+// whichever comes first. It returns false when the tracker has to stop.
+func (t *AllRecordsTracker) wait(duration time.Duration, updates <-chan bool, shutdown chan struct{}) bool {
+	timer := t.clock.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case ok := <-updates:
+		return ok
+	case <-timer.C():
+		return true
+	case <-shutdown:
+		return false
+	}
+}
+
+// admit releases IPs which are no longer selected and tracks up to budget
+// more of the selected ones. It returns how many IPs it tracked.
+func (t *AllRecordsTracker) admit(selected []string, tracked map[string]struct{}, budget int) int {
+	isSelected := map[string]struct{}{}
+	for _, ip := range selected {
+		isSelected[ip] = struct{}{}
+	}
+
+	removed := []string{}
+	for ip := range tracked {
+		if _, found := isSelected[ip]; !found {
+			delete(tracked, ip)
+			removed = append(removed, ip)
+		}
+	}
+
+	if len(removed) > 0 {
+		sort.Strings(removed)
+		t.tracker.UntrackIPs(removed)
+	}
+
+	added := []string{}
+	for _, ip := range selected {
+		if len(added) == budget {
+			break
+		}
+
+		if _, found := tracked[ip]; !found {
+			tracked[ip] = struct{}{}
+			added = append(added, ip)
+		}
+	}
+
+	if len(added) > 0 {
+		t.tracker.TrackIPs(added)
+	}
+
+	return len(added)
+}
+
+func (t *AllRecordsTracker) selectedIPs() []string {
+	unique := map[string]struct{}{}
+
+	for _, record := range t.source.AllRecords() {
+		if t.selects(record) {
+			unique[record.IP] = struct{}{}
+		}
+	}
+
+	ips := make([]string, 0, len(unique))
+	for ip := range unique {
+		ips = append(ips, ip)
+	}
+
+	sort.Strings(ips)
+
+	return ips
+}
+
+func (t *AllRecordsTracker) selects(record records.Record) bool {
+	if len(t.selectors) == 0 {
+		return true
+	}
+
+	for _, selector := range t.selectors {
+		if selector.matches(record) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,162 @@
+package healthiness_test
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"bosh-dns/dns/server/healthiness"
+	"bosh-dns/dns/server/healthiness/healthinessfakes"
+	"bosh-dns/dns/server/records"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AllRecordsTracker", func() {
+	var (
+		fakeSource  *healthinessfakes.FakeAllRecordsSource
+		fakeTracker *healthinessfakes.FakeIPTracker
+		fakeClock   *fakeclock.FakeClock
+		updates     chan bool
+		shutdown    chan struct{}
+		stopped     chan struct{}
+
+		selectors []healthiness.TrackSelector
+		rate      int
+
+		trackedIPs     map[string]struct{}
+		trackedIPsLock sync.Mutex
+	)
+
+	lastTracked := func() []string {
+		trackedIPsLock.Lock()
+		defer trackedIPsLock.Unlock()
+
+		ips := []string{}
+		for ip := range trackedIPs {
+			ips = append(ips, ip)
+		}
+		sort.Strings(ips)
+
+		return ips
+	}
+
+	BeforeEach(func() {
+		fakeSource = &healthinessfakes.FakeAllRecordsSource{}
+		fakeTracker = &healthinessfakes.FakeIPTracker{}
+		trackedIPs = map[string]struct{}{}
+		fakeTracker.TrackIPsStub = func(ips []string) {
+			trackedIPsLock.Lock()
+			defer trackedIPsLock.Unlock()
+
+			for _, ip := range ips {
+				trackedIPs[ip] = struct{}{}
+			}
+		}
+		fakeTracker.UntrackIPsStub = func(ips []string) {
+			trackedIPsLock.Lock()
+			defer trackedIPsLock.Unlock()
+
+			for _, ip := range ips {
+				delete(trackedIPs, ip)
+			}
+		}
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		updates = make(chan bool)
+		shutdown = make(chan struct{})
+		stopped = make(chan struct{})
+
+		fakeSource.SubscribeReturns(updates)
+		fakeSource.AllRecordsReturns([]records.Record{
+			{IP: "10.0.0.3", Deployment: "cf", Group: "router"},
+			{IP: "10.0.0.1", Deployment: "cf", Group: "api"},
+			{IP: "10.0.0.1", Deployment: "cf", Group: "api", Domain: "other."},
+			{IP: "10.0.0.2", Deployment: "mysql", Group: "db"},
+		})
+
+		selectors = nil
+		rate = 100
+	})
+
+	JustBeforeEach(func() {
+		tracker := healthiness.NewAllRecordsTracker(fakeSource, fakeTracker, selectors, rate, fakeClock)
+
+		go func() {
+			tracker.Run(shutdown)
+			close(stopped)
+		}()
+	})
+
+	AfterEach(func() {
+		close(shutdown)
+		Eventually(stopped).Should(BeClosed())
+	})
+
+	It("tracks the ip of every record", func() {
+		Eventually(lastTracked).Should(Equal([]string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}))
+	})
+
+	Context("when selecting deployments and groups", func() {
+		BeforeEach(func() {
+			selectors = []healthiness.TrackSelector{
+				{Deployment: "cf", Group: "router"},
+				{Deployment: "mysql"},
+			}
+		})
+
+		It("tracks only the selected records", func() {
+			Eventually(lastTracked).Should(Equal([]string{"10.0.0.2", "10.0.0.3"}))
+		})
+	})
+
+	Context("when there are more new ips than the rate", func() {
+		BeforeEach(func() {
+			rate = 2
+		})
+
+		It("tracks up to rate more ips per second", func() {
+			Eventually(lastTracked).Should(Equal([]string{"10.0.0.1", "10.0.0.2"}))
+			Consistently(lastTracked).Should(Equal([]string{"10.0.0.1", "10.0.0.2"}))
+
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(lastTracked).Should(Equal([]string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}))
+		})
+
+		It("does not track more ips when the records change within the second", func() {
+			Eventually(lastTracked).Should(Equal([]string{"10.0.0.1", "10.0.0.2"}))
+
+			updates <- true
+			Consistently(lastTracked).Should(Equal([]string{"10.0.0.1", "10.0.0.2"}))
+
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(lastTracked).Should(Equal([]string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}))
+		})
+	})
+
+	It("releases ips which are gone after the records change", func() {
+		Eventually(lastTracked).Should(HaveLen(3))
+
+		fakeSource.AllRecordsReturns([]records.Record{
+			{IP: "10.0.0.2", Deployment: "mysql", Group: "db"},
+			{IP: "10.0.0.4", Deployment: "mysql", Group: "db"},
+		})
+		updates <- true
+
+		Eventually(lastTracked).Should(Equal([]string{"10.0.0.2", "10.0.0.4"}))
+
+		Expect(fakeTracker.UntrackIPsCallCount()).To(Equal(1))
+		Expect(fakeTracker.UntrackIPsArgsForCall(0)).To(Equal([]string{"10.0.0.1", "10.0.0.3"}))
+		Expect(fakeTracker.TrackIPsArgsForCall(fakeTracker.TrackIPsCallCount() - 1)).To(Equal([]string{"10.0.0.4"}))
+	})
+
+	It("stops when the records are no longer updated", func() {
+		Eventually(lastTracked).Should(HaveLen(3))
+		close(updates)
+
+		Eventually(stopped).Should(BeClosed())
+		shutdown = make(chan struct{})
+	})
+})
@@ -0,0 +1,159 @@
+package healthiness
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+//go:generate counterfeiter . HealthCheckAssigner
+
+type HealthCheckAssigner interface {
+	Assign(domain string, ips []string)
+	Untrack(ip string)
+}
+
+// DomainHealthChecker picks the health check of an IP based on the domains
+// it was resolved for. Domains are either fully qualified names or wildcards
+// (`*.example.com`) matching any subdomain. IPs which were never resolved for
+// a configured domain are checked with the default checker. Checkers of
+// specific IPs take precedence over both. An IP resolved for several
+// configured domains uses the checker of the first of them in lexical order.
+type DomainHealthChecker struct {
+	defaultChecker HealthChecker
+	domainCheckers map[string]HealthChecker
+	staticCheckers map[string]HealthChecker
+
+	domainIPs  map[string]map[string]struct{}
+	ipDomains  map[string]map[string]HealthChecker
+	ipCheckers map[string]HealthChecker
+	mutex      *sync.RWMutex
+}
+
+func NewDomainHealthChecker(defaultChecker HealthChecker, domainCheckers map[string]HealthChecker, staticCheckers map[string]HealthChecker) *DomainHealthChecker {
+	checkers := map[string]HealthChecker{}
+	for domain, checker := range domainCheckers {
+		checkers[dns.Fqdn(strings.ToLower(domain))] = checker
+	}
+
+	return &DomainHealthChecker{
+		defaultChecker: defaultChecker,
+		domainCheckers: checkers,
+		staticCheckers: staticCheckers,
+
+		domainIPs:  map[string]map[string]struct{}{},
+		ipDomains:  map[string]map[string]HealthChecker{},
+		ipCheckers: map[string]HealthChecker{},
+		mutex:      &sync.RWMutex{},
+	}
+}
+
+// Assign records the IPs a domain currently resolves to. IPs the domain no
+// longer resolves to stop using its checker.
+func (c *DomainHealthChecker) Assign(domain string, ips []string) {
+	checker, found := c.checkerFor(domain)
+	if !found {
+		return
+	}
+
+	domain = dns.Fqdn(strings.ToLower(domain))
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	assigned := map[string]struct{}{}
+	for _, ip := range ips {
+		assigned[ip] = struct{}{}
+
+		if _, found := c.ipDomains[ip]; !found {
+			c.ipDomains[ip] = map[string]HealthChecker{}
+		}
+
+		c.ipDomains[ip][domain] = checker
+		c.pickChecker(ip)
+	}
+
+	for ip := range c.domainIPs[domain] {
+		if _, found := assigned[ip]; found {
+			continue
+		}
+
+		delete(c.ipDomains[ip], domain)
+		c.pickChecker(ip)
+	}
+
+	if len(assigned) == 0 {
+		delete(c.domainIPs, domain)
+	} else {
+		c.domainIPs[domain] = assigned
+	}
+}
+
+// Untrack forgets the domains an IP was resolved for.
+func (c *DomainHealthChecker) Untrack(ip string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for domain := range c.ipDomains[ip] {
+		delete(c.domainIPs[domain], ip)
+		if len(c.domainIPs[domain]) == 0 {
+			delete(c.domainIPs, domain)
+		}
+	}
+
+	delete(c.ipDomains, ip)
+	delete(c.ipCheckers, ip)
+}
+
+func (c *DomainHealthChecker) GetStatus(ip string) (HealthStatus, error) {
+	if checker, found := c.staticCheckers[ip]; found {
+		return checker.GetStatus(ip)
+	}
+
+	c.mutex.RLock()
+	checker, found := c.ipCheckers[ip]
+	c.mutex.RUnlock()
+
+	if !found {
+		checker = c.defaultChecker
+	}
+
+	return checker.GetStatus(ip)
+}
+
+func (c *DomainHealthChecker) checkerFor(domain string) (HealthChecker, bool) {
+	domain = dns.Fqdn(strings.ToLower(domain))
+
+	if checker, found := c.domainCheckers[domain]; found {
+		return checker, true
+	}
+
+	labels := dns.SplitDomainName(domain)
+	for i := 1; i < len(labels); i++ {
+		wildcard := dns.Fqdn("*." + strings.Join(labels[i:], "."))
+		if checker, found := c.domainCheckers[wildcard]; found {
+			return checker, true
+		}
+	}
+
+	return nil, false
+}
+
+func (c *DomainHealthChecker) pickChecker(ip string) {
+	if len(c.ipDomains[ip]) == 0 {
+		delete(c.ipDomains, ip)
+		delete(c.ipCheckers, ip)
+		return
+	}
+
+	domains := []string{}
+	for domain := range c.ipDomains[ip] {
+		domains = append(domains, domain)
+	}
+
+	sort.Strings(domains)
+
+	c.ipCheckers[ip] = c.ipDomains[ip][domains[0]]
+}
@@ -0,0 +1,128 @@
+package healthiness_test
+
+import (
+	"bosh-dns/dns/server/healthiness"
+	"bosh-dns/dns/server/healthiness/healthinessfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DomainHealthChecker", func() {
+	var (
+		defaultChecker  *healthinessfakes.FakeHealthChecker
+		tcpChecker      *healthinessfakes.FakeHealthChecker
+		wildcardChecker *healthinessfakes.FakeHealthChecker
+		staticChecker   *healthinessfakes.FakeHealthChecker
+
+		healthChecker *healthiness.DomainHealthChecker
+	)
+
+	BeforeEach(func() {
+		defaultChecker = &healthinessfakes.FakeHealthChecker{}
+		tcpChecker = &healthinessfakes.FakeHealthChecker{}
+		wildcardChecker = &healthinessfakes.FakeHealthChecker{}
+		staticChecker = &healthinessfakes.FakeHealthChecker{}
+
+		healthChecker = healthiness.NewDomainHealthChecker(defaultChecker, map[string]healthiness.HealthChecker{
+			"db.internal":      tcpChecker,
+			"*.apps.internal.": wildcardChecker,
+		}, map[string]healthiness.HealthChecker{
+			"192.168.0.1": staticChecker,
+		})
+	})
+
+	It("uses the checker of a static ip whatever domain it was resolved for", func() {
+		healthChecker.Assign("db.internal.", []string{"192.168.0.1"})
+
+		healthChecker.GetStatus("192.168.0.1")
+
+		Expect(staticChecker.GetStatusCallCount()).To(Equal(1))
+		Expect(tcpChecker.GetStatusCallCount()).To(Equal(0))
+		Expect(defaultChecker.GetStatusCallCount()).To(Equal(0))
+	})
+
+	It("uses the default checker for unassigned ips", func() {
+		defaultChecker.GetStatusReturns(healthiness.StatusHealthy, nil)
+
+		Expect(healthChecker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+		Expect(defaultChecker.GetStatusArgsForCall(0)).To(Equal("10.0.0.1"))
+	})
+
+	It("uses the checker of the domain the ip was resolved for", func() {
+		healthChecker.Assign("DB.internal.", []string{"10.0.0.1", "10.0.0.2"})
+		healthChecker.Assign("web.apps.internal.", []string{"10.0.0.3"})
+		healthChecker.Assign("q-s0.g.n.d.bosh.", []string{"10.0.0.4"})
+
+		healthChecker.GetStatus("10.0.0.1")
+		healthChecker.GetStatus("10.0.0.2")
+		healthChecker.GetStatus("10.0.0.3")
+		healthChecker.GetStatus("10.0.0.4")
+
+		Expect(tcpChecker.GetStatusCallCount()).To(Equal(2))
+		Expect(wildcardChecker.GetStatusCallCount()).To(Equal(1))
+		Expect(wildcardChecker.GetStatusArgsForCall(0)).To(Equal("10.0.0.3"))
+		Expect(defaultChecker.GetStatusCallCount()).To(Equal(1))
+		Expect(defaultChecker.GetStatusArgsForCall(0)).To(Equal("10.0.0.4"))
+	})
+
+	It("matches wildcards against subdomains only", func() {
+		healthChecker.Assign("apps.internal.", []string{"10.0.0.1"})
+		healthChecker.Assign("a.b.apps.internal.", []string{"10.0.0.2"})
+
+		healthChecker.GetStatus("10.0.0.1")
+		healthChecker.GetStatus("10.0.0.2")
+
+		Expect(defaultChecker.GetStatusCallCount()).To(Equal(1))
+		Expect(wildcardChecker.GetStatusCallCount()).To(Equal(1))
+		Expect(wildcardChecker.GetStatusArgsForCall(0)).To(Equal("10.0.0.2"))
+	})
+
+	It("keeps a configured check when the ip is also resolved for other domains", func() {
+		healthChecker.Assign("db.internal.", []string{"10.0.0.1"})
+		healthChecker.Assign("q-s0.g.n.d.bosh.", []string{"10.0.0.1"})
+
+		healthChecker.GetStatus("10.0.0.1")
+
+		Expect(tcpChecker.GetStatusCallCount()).To(Equal(1))
+		Expect(defaultChecker.GetStatusCallCount()).To(Equal(0))
+	})
+
+	It("picks the same checker for an ip shared by configured domains whatever the order of assignments", func() {
+		healthChecker.Assign("web.apps.internal.", []string{"10.0.0.1"})
+		healthChecker.Assign("db.internal.", []string{"10.0.0.1"})
+		healthChecker.GetStatus("10.0.0.1")
+
+		healthChecker.Assign("web.apps.internal.", []string{"10.0.0.1"})
+		healthChecker.GetStatus("10.0.0.1")
+
+		Expect(tcpChecker.GetStatusCallCount()).To(Equal(2))
+		Expect(wildcardChecker.GetStatusCallCount()).To(Equal(0))
+	})
+
+	It("stops using the checker of a domain which no longer resolves to an ip", func() {
+		healthChecker.Assign("web.apps.internal.", []string{"10.0.0.1"})
+		healthChecker.Assign("db.internal.", []string{"10.0.0.1", "10.0.0.2"})
+
+		healthChecker.Assign("db.internal.", []string{"10.0.0.2"})
+		healthChecker.GetStatus("10.0.0.1")
+		Expect(wildcardChecker.GetStatusCallCount()).To(Equal(1))
+
+		healthChecker.Assign("web.apps.internal.", nil)
+		healthChecker.GetStatus("10.0.0.1")
+		Expect(defaultChecker.GetStatusCallCount()).To(Equal(1))
+		Expect(tcpChecker.GetStatusCallCount()).To(Equal(0))
+	})
+
+	It("uses the default checker for untracked ips", func() {
+		healthChecker.Assign("db.internal.", []string{"10.0.0.1", "10.0.0.2"})
+
+		healthChecker.Untrack("10.0.0.1")
+		healthChecker.GetStatus("10.0.0.1")
+		healthChecker.GetStatus("10.0.0.2")
+
+		Expect(defaultChecker.GetStatusCallCount()).To(Equal(1))
+		Expect(defaultChecker.GetStatusArgsForCall(0)).To(Equal("10.0.0.1"))
+		Expect(tcpChecker.GetStatusCallCount()).To(Equal(1))
+	})
+})
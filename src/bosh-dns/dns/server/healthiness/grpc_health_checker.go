@@ -0,0 +1,51 @@
+package healthiness
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"bosh-dns/dns/server/healthiness/internal/grpchealth"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+type grpcHealthChecker struct {
+	port    int
+	service string
+	timeout time.Duration
+}
+
+// NewGRPCHealthChecker checks peers with the standard gRPC health checking
+// protocol. An empty service asks for the health of the server as a whole.
+func NewGRPCHealthChecker(port int, service string, timeout time.Duration) HealthChecker {
+	return &grpcHealthChecker{
+		port:    port,
+		service: service,
+		timeout: timeout,
+	}
+}
+
+func (hc *grpcHealthChecker) GetStatus(ip string) (HealthStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), hc.timeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, net.JoinHostPort(ip, strconv.Itoa(hc.port)), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return StatusUnhealthy, err
+	}
+	defer conn.Close()
+
+	status, err := grpchealth.Check(ctx, conn, hc.service)
+	if err != nil {
+		return StatusUnhealthy, err
+	}
+
+	if status != grpchealth.ServingStatusServing {
+		return StatusUnhealthy, fmt.Errorf("service reported status %s", status)
+	}
+
+	return StatusHealthy, nil
+}
@@ -0,0 +1,77 @@
+package healthiness_test
+
+import (
+	"net"
+	"time"
+
+	"bosh-dns/dns/server/healthiness"
+	"bosh-dns/dns/server/healthiness/internal/grpchealth"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeGRPCHealthServer struct {
+	statuses map[string]grpchealth.ServingStatus
+}
+
+func (s fakeGRPCHealthServer) Check(_ context.Context, req *grpchealth.HealthCheckRequest) (*grpchealth.HealthCheckResponse, error) {
+	return &grpchealth.HealthCheckResponse{Status: s.statuses[req.Service]}, nil
+}
+
+var _ = Describe("GRPCHealthChecker", func() {
+	var (
+		listener net.Listener
+		server   *grpc.Server
+		port     int
+	)
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		port = listener.Addr().(*net.TCPAddr).Port
+
+		server = grpc.NewServer()
+		grpchealth.RegisterHealthServer(server, fakeGRPCHealthServer{
+			statuses: map[string]grpchealth.ServingStatus{
+				"":         grpchealth.ServingStatusServing,
+				"serving":  grpchealth.ServingStatusServing,
+				"stopping": grpchealth.ServingStatusNotServing,
+			},
+		})
+
+		go server.Serve(listener)
+	})
+
+	AfterEach(func() {
+		server.Stop()
+	})
+
+	It("is healthy when the server is serving", func() {
+		Expect(healthiness.NewGRPCHealthChecker(port, "", time.Second).GetStatus("127.0.0.1")).To(Equal(healthiness.StatusHealthy))
+	})
+
+	It("checks the configured service", func() {
+		Expect(healthiness.NewGRPCHealthChecker(port, "serving", time.Second).GetStatus("127.0.0.1")).To(Equal(healthiness.StatusHealthy))
+
+		status, err := healthiness.NewGRPCHealthChecker(port, "stopping", time.Second).GetStatus("127.0.0.1")
+		Expect(err).To(MatchError("service reported status NOT_SERVING"))
+		Expect(status).To(Equal(healthiness.StatusUnhealthy))
+
+		status, err = healthiness.NewGRPCHealthChecker(port, "unknown", time.Second).GetStatus("127.0.0.1")
+		Expect(err).To(HaveOccurred())
+		Expect(status).To(Equal(healthiness.StatusUnhealthy))
+	})
+
+	It("is unhealthy when the server cannot be reached", func() {
+		server.Stop()
+
+		status, err := healthiness.NewGRPCHealthChecker(port, "", 200*time.Millisecond).GetStatus("127.0.0.1")
+		Expect(err).To(HaveOccurred())
+		Expect(status).To(Equal(healthiness.StatusUnhealthy))
+	})
+})
@@ -30,23 +30,32 @@ type healthStatus struct {
 	State string
 }
 
-func (hc *healthChecker) GetStatus(ip string) bool {
+func (hc *healthChecker) GetStatus(ip string) (HealthStatus, error) {
 	endpoint := fmt.Sprintf("https://%s/health", net.JoinHostPort(ip, fmt.Sprintf("%d", hc.port)))
 
 	response, err := hc.client.Get(endpoint)
 	if err != nil {
-		return false
+		return StatusUnhealthy, err
 	} else if response.StatusCode != 200 {
-		return false
+		return StatusUnhealthy, fmt.Errorf("health server responded with status %d", response.StatusCode)
 	}
 
 	responseBytes, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return false // untested
+		return StatusUnhealthy, err // untested
 	}
 
 	var parsedResponse healthStatus
 	_ = json.Unmarshal(responseBytes, &parsedResponse)
 
-	return parsedResponse.State == "running"
+	switch parsedResponse.State {
+	case "running":
+		return StatusHealthy, nil
+	case "degraded":
+		return StatusDegraded, nil
+	case "draining":
+		return StatusDraining, nil
+	default:
+		return StatusUnhealthy, fmt.Errorf("health server reported state '%s'", parsedResponse.State)
+	}
 }
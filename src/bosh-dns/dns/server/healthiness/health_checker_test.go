@@ -49,28 +49,54 @@ var _ = Describe("HealthChecker", func() {
 				responseBody = `{"state":"running"}`
 			})
 
-			It("returns true", func() {
-				Expect(healthChecker.GetStatus(ip)).To(BeTrue())
+			It("returns healthy", func() {
+				Expect(healthChecker.GetStatus(ip)).To(Equal(healthiness.StatusHealthy))
 				Expect(fakeClient.GetCallCount()).To(Equal(1))
 				Expect(fakeClient.GetArgsForCall(0)).To(Equal(fmt.Sprintf("https://%s:8081/health", ip)))
 			})
 
 			It("brackets IPv6 addresses", func() {
 				ip := "2601:0646:0102:0095:0000:0000:0000:0024"
-				Expect(healthChecker.GetStatus(ip)).To(BeTrue())
+				Expect(healthChecker.GetStatus(ip)).To(Equal(healthiness.StatusHealthy))
 				Expect(fakeClient.GetCallCount()).To(Equal(1))
 				Expect(fakeClient.GetArgsForCall(0)).To(Equal(fmt.Sprintf("https://[%s]:8081/health", ip)))
 			})
 		})
 
+		Context("when degraded", func() {
+			BeforeEach(func() {
+				ip = "127.0.0.2"
+				responseBody = `{"state":"degraded"}`
+			})
+
+			It("returns degraded", func() {
+				Expect(healthChecker.GetStatus(ip)).To(Equal(healthiness.StatusDegraded))
+			})
+		})
+
+		Context("when draining", func() {
+			BeforeEach(func() {
+				ip = "127.0.0.2"
+				responseBody = `{"state":"draining"}`
+			})
+
+			It("returns draining", func() {
+				status, err := healthChecker.GetStatus(ip)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(status).To(Equal(healthiness.StatusDraining))
+			})
+		})
+
 		Context("when unhealthy", func() {
 			BeforeEach(func() {
 				ip = "127.0.0.2"
 				responseBody = `{"state":"stopped"}`
 			})
 
-			It("returns false", func() {
-				Expect(healthChecker.GetStatus(ip)).To(BeFalse())
+			It("returns unhealthy", func() {
+				status, err := healthChecker.GetStatus(ip)
+				Expect(err).To(MatchError("health server reported state 'stopped'"))
+				Expect(status).To(Equal(healthiness.StatusUnhealthy))
 				Expect(fakeClient.GetCallCount()).To(Equal(1))
 				Expect(fakeClient.GetArgsForCall(0)).To(Equal(fmt.Sprintf("https://%s:8081/health", ip)))
 			})
@@ -81,10 +107,12 @@ var _ = Describe("HealthChecker", func() {
 				ip = "127.0.0.3"
 			})
 
-			It("returns false", func() {
+			It("returns unhealthy", func() {
 				fakeClient.GetReturns(nil, errors.New("fake connect err"))
 
-				Expect(healthChecker.GetStatus(ip)).To(BeFalse())
+				status, err := healthChecker.GetStatus(ip)
+				Expect(err).To(MatchError("fake connect err"))
+				Expect(status).To(Equal(healthiness.StatusUnhealthy))
 				Expect(fakeClient.GetCallCount()).To(Equal(1))
 				Expect(fakeClient.GetArgsForCall(0)).To(Equal(fmt.Sprintf("https://%s:8081/health", ip)))
 			})
@@ -96,8 +124,10 @@ var _ = Describe("HealthChecker", func() {
 				responseBody = `duck?`
 			})
 
-			It("returns false", func() {
-				Expect(healthChecker.GetStatus(ip)).To(BeFalse())
+			It("returns unhealthy", func() {
+				status, err := healthChecker.GetStatus(ip)
+				Expect(err).To(MatchError("health server reported state ''"))
+				Expect(status).To(Equal(healthiness.StatusUnhealthy))
 				Expect(fakeClient.GetCallCount()).To(Equal(1))
 				Expect(fakeClient.GetArgsForCall(0)).To(Equal(fmt.Sprintf("https://%s:8081/health", ip)))
 			})
@@ -109,8 +139,10 @@ var _ = Describe("HealthChecker", func() {
 				responseCode = 400
 			})
 
-			It("returns false", func() {
-				Expect(healthChecker.GetStatus(ip)).To(BeFalse())
+			It("returns unhealthy", func() {
+				status, err := healthChecker.GetStatus(ip)
+				Expect(err).To(MatchError("health server responded with status 400"))
+				Expect(status).To(Equal(healthiness.StatusUnhealthy))
 				Expect(fakeClient.GetCallCount()).To(Equal(1))
 				Expect(fakeClient.GetArgsForCall(0)).To(Equal(fmt.Sprintf("https://%s:8081/health", ip)))
 			})
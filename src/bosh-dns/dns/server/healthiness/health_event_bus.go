@@ -0,0 +1,75 @@
+package healthiness
+
+import (
+	"sync"
+	"time"
+)
+
+// HealthEvent is a change of the health of an IP. Domains lists the tracked
+// domains resolving to the IP when the change was decided.
+type HealthEvent struct {
+	IP             string       `json:"ip"`
+	Domains        []string     `json:"domains"`
+	Status         HealthStatus `json:"status"`
+	PreviousStatus HealthStatus `json:"previous_status"`
+	Reason         string       `json:"reason,omitempty"`
+	Timestamp      time.Time    `json:"timestamp"`
+}
+
+//go:generate counterfeiter . HealthEventPublisher
+
+type HealthEventPublisher interface {
+	Publish(event HealthEvent)
+}
+
+// HealthEventBus fans events out to its subscribers. Publishing never blocks:
+// subscribers which fall more than their buffer behind miss events.
+type HealthEventBus struct {
+	bufferSize int
+
+	subscribers map[chan HealthEvent]struct{}
+	mutex       *sync.Mutex
+}
+
+func NewHealthEventBus(bufferSize int) *HealthEventBus {
+	return &HealthEventBus{
+		bufferSize: bufferSize,
+
+		subscribers: map[chan HealthEvent]struct{}{},
+		mutex:       &sync.Mutex{},
+	}
+}
+
+func (b *HealthEventBus) Publish(event HealthEvent) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// Subscribe returns the events published from now on. The returned function
+// ends the subscription and closes the channel.
+func (b *HealthEventBus) Subscribe() (<-chan HealthEvent, func()) {
+	subscriber := make(chan HealthEvent, b.bufferSize)
+
+	b.mutex.Lock()
+	b.subscribers[subscriber] = struct{}{}
+	b.mutex.Unlock()
+
+	once := &sync.Once{}
+
+	return subscriber, func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			delete(b.subscribers, subscriber)
+			b.mutex.Unlock()
+
+			close(subscriber)
+		})
+	}
+}
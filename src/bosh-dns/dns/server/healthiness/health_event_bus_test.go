@@ -0,0 +1,49 @@
+package healthiness_test
+
+import (
+	"bosh-dns/dns/server/healthiness"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HealthEventBus", func() {
+	var bus *healthiness.HealthEventBus
+
+	BeforeEach(func() {
+		bus = healthiness.NewHealthEventBus(1)
+	})
+
+	It("delivers published events to every subscriber", func() {
+		first, unsubscribeFirst := bus.Subscribe()
+		defer unsubscribeFirst()
+		second, unsubscribeSecond := bus.Subscribe()
+		defer unsubscribeSecond()
+
+		bus.Publish(healthiness.HealthEvent{IP: "10.0.0.1"})
+
+		Expect(first).To(Receive(Equal(healthiness.HealthEvent{IP: "10.0.0.1"})))
+		Expect(second).To(Receive(Equal(healthiness.HealthEvent{IP: "10.0.0.1"})))
+	})
+
+	It("drops events for subscribers which fall behind", func() {
+		events, unsubscribe := bus.Subscribe()
+		defer unsubscribe()
+
+		bus.Publish(healthiness.HealthEvent{IP: "10.0.0.1"})
+		bus.Publish(healthiness.HealthEvent{IP: "10.0.0.2"})
+
+		Expect(events).To(Receive(Equal(healthiness.HealthEvent{IP: "10.0.0.1"})))
+		Expect(events).NotTo(Receive())
+	})
+
+	It("stops delivering events once unsubscribed", func() {
+		events, unsubscribe := bus.Subscribe()
+		unsubscribe()
+		unsubscribe()
+
+		bus.Publish(healthiness.HealthEvent{IP: "10.0.0.1"})
+
+		Expect(events).To(BeClosed())
+	})
+})
@@ -0,0 +1,43 @@
+package healthiness
+
+import "time"
+
+// HealthCheckResult is the outcome of a single check of an IP.
+type HealthCheckResult struct {
+	Timestamp time.Time
+	Status    HealthStatus
+	Latency   time.Duration
+	Error     string
+}
+
+// healthHistory is a ring buffer of the last results of an IP.
+type healthHistory struct {
+	results []HealthCheckResult
+	next    int
+	full    bool
+}
+
+func newHealthHistory(size int) *healthHistory {
+	return &healthHistory{results: make([]HealthCheckResult, size)}
+}
+
+func (h *healthHistory) add(result HealthCheckResult) {
+	if len(h.results) == 0 {
+		return
+	}
+
+	h.results[h.next] = result
+	h.next = (h.next + 1) % len(h.results)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns the results from the oldest to the newest.
+func (h *healthHistory) list() []HealthCheckResult {
+	if !h.full {
+		return append([]HealthCheckResult{}, h.results[:h.next]...)
+	}
+
+	return append(append([]HealthCheckResult{}, h.results[h.next:]...), h.results[:h.next]...)
+}
@@ -0,0 +1,136 @@
+package healthiness
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+//go:generate counterfeiter . SnapshotRecordSet
+
+type SnapshotRecordSet interface {
+	Snapshot() HealthSnapshot
+	Restore(snapshot HealthSnapshot)
+}
+
+const snapshotterLogTag = "HealthSnapshotter"
+
+// HealthSnapshotter persists the health view of a record set, so that a
+// restarted server does not have to start from an empty one.
+type HealthSnapshotter struct {
+	recordSet SnapshotRecordSet
+	fs        boshsys.FileSystem
+	path      string
+	clock     clock.Clock
+	interval  time.Duration
+	maxAge    time.Duration
+	logger    boshlog.Logger
+}
+
+func NewHealthSnapshotter(
+	recordSet SnapshotRecordSet,
+	fs boshsys.FileSystem,
+	path string,
+	clock clock.Clock,
+	interval time.Duration,
+	maxAge time.Duration,
+	logger boshlog.Logger,
+) *HealthSnapshotter {
+	return &HealthSnapshotter{
+		recordSet: recordSet,
+		fs:        fs,
+		path:      path,
+		clock:     clock,
+		interval:  interval,
+		maxAge:    maxAge,
+		logger:    logger,
+	}
+}
+
+// Restore loads the snapshot into the record set unless it is missing or
+// older than the maximum age.
+func (s *HealthSnapshotter) Restore() error {
+	if !s.fs.FileExists(s.path) {
+		return nil
+	}
+
+	contents, err := s.fs.ReadFile(s.path)
+	if err != nil {
+		return bosherr.WrapError(err, "Reading health snapshot")
+	}
+
+	var snapshot HealthSnapshot
+	err = json.Unmarshal(contents, &snapshot)
+	if err != nil {
+		return bosherr.WrapError(err, "Unmarshalling health snapshot")
+	}
+
+	age := s.clock.Since(snapshot.SavedAt)
+	if age > s.maxAge {
+		s.logger.Info(snapshotterLogTag, "Ignoring health snapshot saved %s ago", age)
+		return nil
+	}
+
+	s.recordSet.Restore(snapshot)
+	s.logger.Info(snapshotterLogTag, "Restored health of %d domains and %d ips", len(snapshot.Domains), len(snapshot.States))
+
+	return nil
+}
+
+func (s *HealthSnapshotter) Save() error {
+	snapshot := s.recordSet.Snapshot()
+	snapshot.SavedAt = s.clock.Now()
+
+	contents, err := json.Marshal(snapshot)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling health snapshot")
+	}
+
+	err = s.fs.MkdirAll(filepath.Dir(s.path), 0755)
+	if err != nil {
+		return bosherr.WrapError(err, "Creating health snapshot directory")
+	}
+
+	// readers must never see a partially written snapshot
+	tmpPath := s.path + ".tmp"
+
+	err = s.fs.WriteFile(tmpPath, contents)
+	if err != nil {
+		return bosherr.WrapError(err, "Writing health snapshot")
+	}
+
+	err = s.fs.Rename(tmpPath, s.path)
+	if err != nil {
+		return bosherr.WrapError(err, "Renaming health snapshot")
+	}
+
+	return nil
+}
+
+// Run saves a snapshot every interval and a last one on shutdown.
+func (s *HealthSnapshotter) Run(shutdown <-chan struct{}) {
+	ticker := s.clock.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			s.save()
+		case <-shutdown:
+			s.save()
+			return
+		}
+	}
+}
+
+func (s *HealthSnapshotter) save() {
+	err := s.Save()
+	if err != nil {
+		s.logger.Error(snapshotterLogTag, "Failed to save health snapshot: %s", err)
+	}
+}
@@ -0,0 +1,136 @@
+package healthiness_test
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"bosh-dns/dns/server/healthiness"
+	"bosh-dns/dns/server/healthiness/healthinessfakes"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsysfakes "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HealthSnapshotter", func() {
+	const snapshotPath = "/var/vcap/data/bosh-dns/health-state.json"
+
+	var (
+		fakeRecordSet *healthinessfakes.FakeSnapshotRecordSet
+		fs            *boshsysfakes.FakeFileSystem
+		fakeClock     *fakeclock.FakeClock
+		snapshotter   *healthiness.HealthSnapshotter
+		snapshot      healthiness.HealthSnapshot
+	)
+
+	BeforeEach(func() {
+		fakeRecordSet = &healthinessfakes.FakeSnapshotRecordSet{}
+		fs = boshsysfakes.NewFakeFileSystem()
+		fakeClock = fakeclock.NewFakeClock(time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC))
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+
+		snapshotter = healthiness.NewHealthSnapshotter(fakeRecordSet, fs, snapshotPath, fakeClock, time.Minute, 5*time.Minute, logger)
+
+		snapshot = healthiness.HealthSnapshot{
+			Domains: []string{"q-s0.g.n.d.bosh.", "alias."},
+			States: map[string]healthiness.HealthState{
+				"10.0.0.1": {Status: healthiness.StatusUnhealthy, ConsecutiveFailures: 2},
+			},
+		}
+		fakeRecordSet.SnapshotReturns(snapshot)
+	})
+
+	writeSnapshot := func(savedAt time.Time) {
+		saved := snapshot
+		saved.SavedAt = savedAt
+
+		contents, err := json.Marshal(saved)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fs.WriteFile(snapshotPath, contents)).To(Succeed())
+	}
+
+	Describe("Save", func() {
+		It("writes the snapshot with the time it was saved", func() {
+			Expect(snapshotter.Save()).To(Succeed())
+
+			contents, err := fs.ReadFile(snapshotPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			var saved healthiness.HealthSnapshot
+			Expect(json.Unmarshal(contents, &saved)).To(Succeed())
+			Expect(saved.SavedAt).To(Equal(fakeClock.Now()))
+			Expect(saved.Domains).To(Equal(snapshot.Domains))
+			Expect(saved.States).To(HaveKeyWithValue("10.0.0.1", healthiness.HealthState{Status: healthiness.StatusUnhealthy, ConsecutiveFailures: 2}))
+			Expect(fs.FileExists(snapshotPath + ".tmp")).To(BeFalse())
+		})
+
+		It("returns an error when the snapshot cannot be written", func() {
+			fs.WriteFileError = errors.New("fake-write-error")
+
+			Expect(snapshotter.Save()).To(MatchError(ContainSubstring("fake-write-error")))
+		})
+	})
+
+	Describe("Restore", func() {
+		It("restores a snapshot younger than the maximum age", func() {
+			writeSnapshot(fakeClock.Now().Add(-4 * time.Minute))
+
+			Expect(snapshotter.Restore()).To(Succeed())
+
+			Expect(fakeRecordSet.RestoreCallCount()).To(Equal(1))
+			restored := fakeRecordSet.RestoreArgsForCall(0)
+			Expect(restored.Domains).To(Equal(snapshot.Domains))
+			Expect(restored.States).To(Equal(snapshot.States))
+		})
+
+		It("ignores a snapshot older than the maximum age", func() {
+			writeSnapshot(fakeClock.Now().Add(-6 * time.Minute))
+
+			Expect(snapshotter.Restore()).To(Succeed())
+			Expect(fakeRecordSet.RestoreCallCount()).To(Equal(0))
+		})
+
+		It("does nothing when there is no snapshot", func() {
+			Expect(snapshotter.Restore()).To(Succeed())
+			Expect(fakeRecordSet.RestoreCallCount()).To(Equal(0))
+		})
+
+		It("returns an error when the snapshot is malformed", func() {
+			Expect(fs.WriteFileString(snapshotPath, "{")).To(Succeed())
+
+			Expect(snapshotter.Restore()).To(MatchError(ContainSubstring("Unmarshalling health snapshot")))
+			Expect(fakeRecordSet.RestoreCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("Run", func() {
+		var (
+			shutdown chan struct{}
+			stopped  chan struct{}
+		)
+
+		BeforeEach(func() {
+			shutdown = make(chan struct{})
+			stopped = make(chan struct{})
+
+			go func() {
+				snapshotter.Run(shutdown)
+				close(stopped)
+			}()
+		})
+
+		It("saves a snapshot every interval and on shutdown", func() {
+			fakeClock.WaitForWatcherAndIncrement(time.Minute)
+			Eventually(fakeRecordSet.SnapshotCallCount).Should(Equal(1))
+
+			close(shutdown)
+			Eventually(stopped).Should(BeClosed())
+			Expect(fakeRecordSet.SnapshotCallCount()).To(Equal(2))
+			Expect(fs.FileExists(snapshotPath)).To(BeTrue())
+		})
+	})
+})
@@ -1,101 +1,539 @@
 package healthiness
 
 import (
+	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
+	dnsconfig "bosh-dns/dns/config"
+
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/workpool"
 )
 
 //go:generate counterfeiter . HealthChecker
 
+// HealthChecker returns the reason of a failing check as its error. The
+// status of a failing check is always StatusUnhealthy.
 type HealthChecker interface {
-	GetStatus(ip string) bool
+	GetStatus(ip string) (HealthStatus, error)
 }
 
+type HealthStatus string
+
+const (
+	StatusHealthy   HealthStatus = "healthy"
+	StatusDegraded  HealthStatus = "degraded"
+	StatusUnhealthy HealthStatus = "unhealthy"
+
+	// StatusDraining is reported for instances which are being taken down,
+	// which are only answered with when no other instance is available.
+	StatusDraining HealthStatus = "draining"
+
+	// StatusUnknown is reported for IPs which have not been checked yet when
+	// they are configured to rank below checked ones.
+	StatusUnknown HealthStatus = "unknown"
+)
+
 //go:generate counterfeiter . HealthWatcher
 
 type HealthWatcher interface {
-	IsHealthy(ip string) bool
+	Status(ip string) HealthStatus
+	Statuses(ips []string) map[string]HealthStatus
+	HealthStates() map[string]HealthState
+	History() map[string][]HealthCheckResult
+	Restore(states map[string]HealthState)
 	Untrack(ip string)
+	Events() <-chan HealthEvent
 	Run(signal <-chan struct{})
 }
 
+type HealthState struct {
+	Status               HealthStatus `json:"status"`
+	ConsecutiveSuccesses int          `json:"consecutive_successes"`
+	ConsecutiveFailures  int          `json:"consecutive_failures"`
+	LastCheck            time.Time    `json:"last_check"`
+	NextCheck            time.Time    `json:"next_check"`
+	Reason               string       `json:"reason,omitempty"`
+	Flapping             bool         `json:"flapping,omitempty"`
+
+	// checks are scheduled on ticks of the check interval; backing off
+	// skips whole ticks so that slow checks do not drift the schedule
+	skippedTicks int
+}
+
+type HealthWatcherConfig struct {
+	CheckInterval time.Duration
+
+	// MaxCheckInterval enables exponential backoff of checks for unhealthy
+	// IPs, up to this interval, when it is longer than CheckInterval.
+	MaxCheckInterval time.Duration
+
+	// Consecutive results needed before a known IP changes its state.
+	HealthyThreshold   int
+	UnhealthyThreshold int
+
+	// Workers bounds the checks running at the same time.
+	Workers int
+
+	// Checks due on a tick are spread evenly across the check interval.
+	// Jitter additionally delays each of them by up to this duration.
+	Jitter time.Duration
+
+	// DefaultHealth is one of the DefaultHealth modes. When waiting, up to
+	// DefaultHealthWait is spent per lookup on the first checks of new IPs.
+	DefaultHealth     string
+	DefaultHealthWait time.Duration
+
+	// HistorySize bounds the check results kept of every IP.
+	HistorySize int
+
+	// An IP changing its state more than FlappingThreshold times within
+	// FlappingWindow is flapping. When HoldFlapping is set, flapping IPs are
+	// reported unhealthy until they are stable again.
+	FlappingThreshold int
+	FlappingWindow    time.Duration
+	HoldFlapping      bool
+}
+
 type healthWatcher struct {
-	checker       HealthChecker
-	checkInterval time.Duration
-	clock         clock.Clock
+	checker HealthChecker
+	config  HealthWatcherConfig
+	clock   clock.Clock
+	random  *rand.Rand
 
 	checkWorkPool *workpool.WorkPool
-	state         map[string]bool
+	state         map[string]HealthState
+	pending       map[string]chan struct{}
+	history       map[string]*healthHistory
+	transitions   map[string][]time.Time
 	stateMutex    *sync.RWMutex
+
+	events chan HealthEvent
 }
 
-func NewHealthWatcher(checker HealthChecker, clock clock.Clock, checkInterval time.Duration) *healthWatcher {
-	wp, _ := workpool.NewWorkPool(1000)
+// healthEventBuffer bounds the events waiting to be consumed, checks never
+// block on a slow consumer.
+const healthEventBuffer = 1000
+
+func NewHealthWatcher(checker HealthChecker, clock clock.Clock, config HealthWatcherConfig) *healthWatcher {
+	if config.Workers < 1 {
+		config.Workers = 1000
+	}
+
+	wp, _ := workpool.NewWorkPool(config.Workers)
+
+	if config.HealthyThreshold < 1 {
+		config.HealthyThreshold = 1
+	}
+
+	if config.UnhealthyThreshold < 1 {
+		config.UnhealthyThreshold = 1
+	}
 
 	return &healthWatcher{
-		checker:       checker,
-		checkInterval: checkInterval,
-		clock:         clock,
+		checker: checker,
+		config:  config,
+		clock:   clock,
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
 
 		checkWorkPool: wp,
-		state:         map[string]bool{},
+		state:         map[string]HealthState{},
+		pending:       map[string]chan struct{}{},
+		history:       map[string]*healthHistory{},
+		transitions:   map[string][]time.Time{},
 		stateMutex:    &sync.RWMutex{},
+
+		events: make(chan HealthEvent, healthEventBuffer),
+	}
+}
+
+// Status never waits for the first check of an unknown IP.
+func (hw *healthWatcher) Status(ip string) HealthStatus {
+	return hw.statuses([]string{ip}, false)[ip]
+}
+
+func (hw *healthWatcher) Statuses(ips []string) map[string]HealthStatus {
+	return hw.statuses(ips, hw.config.DefaultHealth == dnsconfig.DefaultHealthWait)
+}
+
+func (hw *healthWatcher) statuses(ips []string, wait bool) map[string]HealthStatus {
+	statuses := make(map[string]HealthStatus, len(ips))
+	pending := []chan struct{}{}
+	unchecked := []string{}
+
+	hw.stateMutex.Lock()
+	for _, ip := range ips {
+		if state, found := hw.state[ip]; found {
+			statuses[ip] = hw.reportedStatus(state)
+			continue
+		}
+
+		done, first := hw.firstCheck(ip)
+		pending = append(pending, done)
+		if first {
+			unchecked = append(unchecked, ip)
+		}
+	}
+	hw.stateMutex.Unlock()
+
+	hw.submit(unchecked)
+
+	if wait && len(pending) > 0 {
+		hw.waitFor(pending)
+
+		hw.stateMutex.RLock()
+		for _, ip := range ips {
+			if state, found := hw.state[ip]; found {
+				statuses[ip] = hw.reportedStatus(state)
+			}
+		}
+		hw.stateMutex.RUnlock()
+	}
+
+	for _, ip := range ips {
+		if _, found := statuses[ip]; !found {
+			statuses[ip] = hw.defaultStatus()
+		}
+	}
+
+	return statuses
+}
+
+// firstCheck reports whether the first check of an unknown IP still has to
+// be submitted, which happens once no matter how often it is asked for. It
+// must be called with the state lock held.
+func (hw *healthWatcher) firstCheck(ip string) (chan struct{}, bool) {
+	if done, found := hw.pending[ip]; found {
+		return done, false
+	}
+
+	done := make(chan struct{})
+	hw.pending[ip] = done
+
+	return done, true
+}
+
+// submit must not be called with the state lock held: submitting blocks
+// while all workers are busy, and they need the lock to finish their checks.
+func (hw *healthWatcher) submit(ips []string) {
+	for _, ip := range ips {
+		// closing on ip, we need to ensure it's fixed within this context
+		ip := ip
+		hw.checkWorkPool.Submit(func() {
+			hw.runCheck(ip)
+		})
 	}
 }
 
-func (hw *healthWatcher) IsHealthy(ip string) bool {
+func (hw *healthWatcher) waitFor(pending []chan struct{}) {
+	timer := hw.clock.NewTimer(hw.config.DefaultHealthWait)
+	defer timer.Stop()
+
+	for _, done := range pending {
+		select {
+		case <-done:
+		case <-timer.C():
+			return
+		}
+	}
+}
+
+func (hw *healthWatcher) reportedStatus(state HealthState) HealthStatus {
+	if hw.config.HoldFlapping && state.Flapping {
+		return StatusUnhealthy
+	}
+
+	return state.Status
+}
+
+func (hw *healthWatcher) defaultStatus() HealthStatus {
+	if hw.config.DefaultHealth == dnsconfig.DefaultHealthLowPriority {
+		return StatusUnknown
+	}
+
+	return StatusHealthy
+}
+
+func (hw *healthWatcher) HealthStates() map[string]HealthState {
 	hw.stateMutex.RLock()
 	defer hw.stateMutex.RUnlock()
 
-	if health, found := hw.state[ip]; found {
-		return health
+	states := make(map[string]HealthState, len(hw.state))
+	for ip, state := range hw.state {
+		states[ip] = state
 	}
 
-	hw.checkWorkPool.Submit(func() {
-		hw.runCheck(ip)
-	})
+	return states
+}
 
-	return true
+// History returns the last check results of every IP, from the oldest to
+// the newest.
+func (hw *healthWatcher) History() map[string][]HealthCheckResult {
+	hw.stateMutex.RLock()
+	defer hw.stateMutex.RUnlock()
+
+	history := make(map[string][]HealthCheckResult, len(hw.history))
+	for ip, results := range hw.history {
+		history[ip] = results.list()
+	}
+
+	return history
+}
+
+// Restore seeds the state of IPs which are not known yet, e.g. from before a
+// restart, and checks them right away to replace the restored view.
+func (hw *healthWatcher) Restore(states map[string]HealthState) {
+	restored := []string{}
+
+	hw.stateMutex.Lock()
+	for ip, state := range states {
+		if _, found := hw.state[ip]; found {
+			continue
+		}
+
+		state.skippedTicks = 0
+		hw.state[ip] = state
+		restored = append(restored, ip)
+	}
+	hw.stateMutex.Unlock()
+
+	hw.submit(restored)
 }
 
 func (hw *healthWatcher) Untrack(ip string) {
 	hw.stateMutex.Lock()
 	delete(hw.state, ip)
+	delete(hw.history, ip)
+	delete(hw.transitions, ip)
 	hw.stateMutex.Unlock()
 }
 
+// Events delivers the changes of the health of IPs decided by checks.
+func (hw *healthWatcher) Events() <-chan HealthEvent {
+	return hw.events
+}
+
 func (hw *healthWatcher) Run(signal <-chan struct{}) {
-	timer := hw.clock.NewTimer(hw.checkInterval)
+	timer := hw.clock.NewTimer(hw.config.CheckInterval)
 	defer timer.Stop()
 
 	for {
 		select {
 		case <-timer.C():
-			hw.stateMutex.RLock()
-			for ip := range hw.state {
-				// closing on ip, we need to ensure it's fixed within this context
-				ip := ip
-				hw.checkWorkPool.Submit(func() {
-					hw.runCheck(ip)
-				})
-			}
-			hw.stateMutex.RUnlock()
+			timer.Reset(hw.config.CheckInterval)
 
-			timer.Reset(hw.checkInterval)
+			if !hw.sweep(hw.dueIPs(), signal) {
+				return
+			}
 		case <-signal:
 			return
 		}
 	}
 }
 
+func (hw *healthWatcher) dueIPs() []string {
+	hw.stateMutex.Lock()
+	defer hw.stateMutex.Unlock()
+
+	due := []string{}
+	for ip, state := range hw.state {
+		if state.skippedTicks > 0 {
+			state.skippedTicks--
+			hw.state[ip] = state
+			continue
+		}
+
+		due = append(due, ip)
+	}
+
+	sort.Strings(due)
+
+	return due
+}
+
+// sweep submits the checks of a tick at evenly spaced offsets within the
+// check interval instead of all at once. It reports false when signalled.
+func (hw *healthWatcher) sweep(ips []string, signal <-chan struct{}) bool {
+	if len(ips) == 0 {
+		return true
+	}
+
+	spacing := hw.config.CheckInterval / time.Duration(len(ips))
+
+	offsets := make([]time.Duration, len(ips))
+	for i := range ips {
+		offsets[i] = time.Duration(i) * spacing
+		if hw.config.Jitter > 0 {
+			offsets[i] += time.Duration(hw.random.Int63n(int64(hw.config.Jitter)))
+		}
+
+		if offsets[i] >= hw.config.CheckInterval {
+			offsets[i] = hw.config.CheckInterval - 1
+		}
+	}
+
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	// waiting relative to the start keeps late timers from delaying the rest
+	start := hw.clock.Now()
+	for i, ip := range ips {
+		if wait := offsets[i] - hw.clock.Since(start); wait > 0 {
+			timer := hw.clock.NewTimer(wait)
+
+			select {
+			case <-timer.C():
+			case <-signal:
+				timer.Stop()
+				return false
+			}
+		}
+
+		hw.submit([]string{ip})
+	}
+
+	return true
+}
+
 func (hw *healthWatcher) runCheck(ip string) {
-	status := hw.checker.GetStatus(ip)
+	start := hw.clock.Now()
+	status, err := hw.checker.GetStatus(ip)
+	now := hw.clock.Now()
+
+	reason := ""
+	if err != nil {
+		status = StatusUnhealthy
+		reason = err.Error()
+	}
 
 	hw.stateMutex.Lock()
 	defer hw.stateMutex.Unlock()
 
-	hw.state[ip] = status
+	hw.addHistory(ip, HealthCheckResult{
+		Timestamp: now,
+		Status:    status,
+		Latency:   now.Sub(start),
+		Error:     reason,
+	})
+
+	state, found := hw.state[ip]
+	previousStatus := hw.reportedStatus(state)
+	if !found {
+		// nothing to protect against flapping yet, the first result decides
+		previousStatus = StatusUnknown
+		state.Status = status
+	}
+	previousDecidedStatus := state.Status
+
+	if status != StatusUnhealthy {
+		state.ConsecutiveSuccesses++
+		state.ConsecutiveFailures = 0
+
+		// moving between healthy and degraded is not a recovery, only
+		// coming back from unhealthy has to pass the threshold
+		if state.Status != StatusUnhealthy || state.ConsecutiveSuccesses >= hw.config.HealthyThreshold {
+			state.Status = status
+		}
+	} else {
+		state.ConsecutiveFailures++
+		state.ConsecutiveSuccesses = 0
+
+		if state.ConsecutiveFailures >= hw.config.UnhealthyThreshold {
+			state.Status = StatusUnhealthy
+		}
+	}
+
+	if found && state.Status != previousDecidedStatus && hw.config.FlappingThreshold > 0 {
+		hw.transitions[ip] = append(hw.transitions[ip], now)
+	}
+	state.Flapping = hw.flapping(ip, now)
+
+	interval := hw.nextCheckInterval(state)
+
+	state.LastCheck = now
+	state.NextCheck = now.Add(interval)
+	state.Reason = reason
+	if hw.config.CheckInterval > 0 {
+		state.skippedTicks = int(interval/hw.config.CheckInterval) - 1
+	}
+
+	hw.state[ip] = state
+
+	if reportedStatus := hw.reportedStatus(state); reportedStatus != previousStatus {
+		if reportedStatus != state.Status {
+			reason = "flapping"
+		}
+
+		hw.publish(HealthEvent{
+			IP:             ip,
+			Status:         reportedStatus,
+			PreviousStatus: previousStatus,
+			Reason:         reason,
+			Timestamp:      now,
+		})
+	}
+
+	if done, found := hw.pending[ip]; found {
+		close(done)
+		delete(hw.pending, ip)
+	}
+}
+
+// addHistory must be called with the state lock held.
+func (hw *healthWatcher) addHistory(ip string, result HealthCheckResult) {
+	if hw.config.HistorySize < 1 {
+		return
+	}
+
+	history, found := hw.history[ip]
+	if !found {
+		history = newHealthHistory(hw.config.HistorySize)
+		hw.history[ip] = history
+	}
+
+	history.add(result)
+}
+
+// flapping forgets the state changes of an IP outside of the flapping window
+// and reports whether too many are left. It must be called with the state
+// lock held.
+func (hw *healthWatcher) flapping(ip string, now time.Time) bool {
+	transitions := hw.transitions[ip]
+	for len(transitions) > 0 && now.Sub(transitions[0]) >= hw.config.FlappingWindow {
+		transitions = transitions[1:]
+	}
+
+	if len(transitions) == 0 {
+		delete(hw.transitions, ip)
+	} else {
+		hw.transitions[ip] = transitions
+	}
+
+	return hw.config.FlappingThreshold > 0 && len(transitions) > hw.config.FlappingThreshold
+}
+
+func (hw *healthWatcher) publish(event HealthEvent) {
+	select {
+	case hw.events <- event:
+	default:
+	}
+}
+
+func (hw *healthWatcher) nextCheckInterval(state HealthState) time.Duration {
+	interval := hw.config.CheckInterval
+
+	if state.Status != StatusUnhealthy || hw.config.MaxCheckInterval <= interval {
+		return interval
+	}
+
+	for i := hw.config.UnhealthyThreshold; i < state.ConsecutiveFailures; i++ {
+		interval *= 2
+
+		if interval >= hw.config.MaxCheckInterval {
+			return hw.config.MaxCheckInterval
+		}
+	}
+
+	return interval
 }
@@ -1,8 +1,11 @@
 package healthiness_test
 
 import (
+	"errors"
+	"sync"
 	"time"
 
+	dnsconfig "bosh-dns/dns/config"
 	"bosh-dns/dns/server/healthiness"
 	"bosh-dns/dns/server/healthiness/healthinessfakes"
 
@@ -17,17 +20,47 @@ var _ = Describe("HealthWatcher", func() {
 		fakeChecker *healthinessfakes.FakeHealthChecker
 		fakeClock   *fakeclock.FakeClock
 		interval    time.Duration
+		config      healthiness.HealthWatcherConfig
 		signal      chan struct{}
 		stopped     chan struct{}
 
 		healthWatcher healthiness.HealthWatcher
+
+		checkMutex sync.Mutex
+		check      func(string) (healthiness.HealthStatus, error)
 	)
 
+	// the watcher checks on its own goroutines, so the checker is switched
+	// through a guarded stub instead of GetStatusReturns
+	setCheck := func(stub func(string) (healthiness.HealthStatus, error)) {
+		checkMutex.Lock()
+		defer checkMutex.Unlock()
+		check = stub
+	}
+
+	setStatus := func(status healthiness.HealthStatus, err error) {
+		setCheck(func(string) (healthiness.HealthStatus, error) {
+			return status, err
+		})
+	}
+
 	BeforeEach(func() {
 		fakeChecker = &healthinessfakes.FakeHealthChecker{}
+		setStatus("", nil)
+		fakeChecker.GetStatusStub = func(ip string) (healthiness.HealthStatus, error) {
+			checkMutex.Lock()
+			stub := check
+			checkMutex.Unlock()
+
+			return stub(ip)
+		}
 		fakeClock = fakeclock.NewFakeClock(time.Now())
 		interval = time.Second
-		healthWatcher = healthiness.NewHealthWatcher(fakeChecker, fakeClock, interval)
+		config = healthiness.HealthWatcherConfig{CheckInterval: interval}
+	})
+
+	JustBeforeEach(func() {
+		healthWatcher = healthiness.NewHealthWatcher(fakeChecker, fakeClock, config)
 		signal = make(chan struct{})
 		stopped = make(chan struct{})
 
@@ -43,7 +76,7 @@ var _ = Describe("HealthWatcher", func() {
 		Eventually(fakeClock.WatcherCount).Should(Equal(0))
 	})
 
-	Describe("IsHealthy", func() {
+	Describe("Status", func() {
 		var ip string
 
 		BeforeEach(func() {
@@ -52,13 +85,13 @@ var _ = Describe("HealthWatcher", func() {
 
 		Context("when the status is not known", func() {
 			It("is always healthy", func() {
-				Expect(healthWatcher.IsHealthy(ip)).To(BeTrue())
+				Expect(healthWatcher.Status(ip)).To(Equal(healthiness.StatusHealthy))
 			})
 		})
 
 		Context("when the status is known", func() {
 			JustBeforeEach(func() {
-				healthWatcher.IsHealthy(ip)
+				healthWatcher.Status(ip)
 				Eventually(fakeChecker.GetStatusCallCount).Should(Equal(1))
 				Expect(fakeChecker.GetStatusArgsForCall(0)).To(Equal(ip))
 			})
@@ -66,56 +99,646 @@ var _ = Describe("HealthWatcher", func() {
 			Context("and the ip is healthy", func() {
 				BeforeEach(func() {
 					ip = "127.0.0.2"
-					fakeChecker.GetStatusReturns(true)
+					setStatus(healthiness.StatusHealthy, nil)
 				})
 
 				It("returns healthy", func() {
-					Expect(healthWatcher.IsHealthy(ip)).To(BeTrue())
+					Expect(healthWatcher.Status(ip)).To(Equal(healthiness.StatusHealthy))
 				})
 			})
 
 			Context("and the ip is unhealthy", func() {
 				BeforeEach(func() {
 					ip = "127.0.0.3"
-					fakeChecker.GetStatusReturns(false)
+					setStatus(healthiness.StatusUnhealthy, errors.New("fake-err"))
 				})
 
 				It("returns unhealthy", func() {
-					Expect(healthWatcher.IsHealthy(ip)).To(BeFalse())
+					Expect(healthWatcher.Status(ip)).To(Equal(healthiness.StatusUnhealthy))
 				})
 			})
 
 			Context("and the status changes", func() {
 				BeforeEach(func() {
-					fakeChecker.GetStatusReturns(true)
+					setStatus(healthiness.StatusHealthy, nil)
 				})
 
 				It("goes unhealthy if the new status is stopped", func() {
-					Expect(healthWatcher.IsHealthy(ip)).To(BeTrue())
+					Expect(healthWatcher.Status(ip)).To(Equal(healthiness.StatusHealthy))
 					Eventually(fakeChecker.GetStatusCallCount).Should(Equal(1))
 
-					fakeChecker.GetStatusReturns(false)
+					setStatus(healthiness.StatusUnhealthy, errors.New("fake-err"))
 
-					Consistently(func() bool {
-						return healthWatcher.IsHealthy(ip)
-					}).Should(BeTrue())
+					Consistently(func() healthiness.HealthStatus {
+						return healthWatcher.Status(ip)
+					}).Should(Equal(healthiness.StatusHealthy))
 
 					fakeClock.WaitForWatcherAndIncrement(interval)
 
-					Eventually(func() bool {
-						return healthWatcher.IsHealthy(ip)
-					}).Should(BeFalse())
+					Eventually(func() healthiness.HealthStatus {
+						return healthWatcher.Status(ip)
+					}).Should(Equal(healthiness.StatusUnhealthy))
 				})
 			})
 		})
 	})
 
-	Describe("Untrack", func() {
+	Describe("scheduling", func() {
+		BeforeEach(func() {
+			setStatus(healthiness.StatusHealthy, nil)
+		})
+
+		It("spreads the checks of a tick evenly across the check interval", func() {
+			for _, ip := range []string{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.4"} {
+				healthWatcher.Status(ip)
+			}
+			Eventually(fakeChecker.GetStatusCallCount).Should(Equal(4))
+
+			fakeClock.WaitForWatcherAndIncrement(interval)
+			Eventually(fakeChecker.GetStatusCallCount).Should(Equal(5))
+			Expect(fakeChecker.GetStatusArgsForCall(4)).To(Equal("127.0.0.1"))
+
+			for i, ip := range []string{"127.0.0.2", "127.0.0.3", "127.0.0.4"} {
+				Eventually(fakeClock.WatcherCount).Should(Equal(2))
+				Consistently(fakeChecker.GetStatusCallCount, 20*time.Millisecond).Should(Equal(5 + i))
+
+				fakeClock.Increment(interval / 4)
+				Eventually(fakeChecker.GetStatusCallCount).Should(Equal(6 + i))
+				Expect(fakeChecker.GetStatusArgsForCall(5 + i)).To(Equal(ip))
+			}
+		})
+
+		Context("with jitter", func() {
+			BeforeEach(func() {
+				config.Jitter = 100 * time.Millisecond
+			})
+
+			It("delays checks by up to the jitter", func() {
+				healthWatcher.Status("127.0.0.1")
+				Eventually(fakeChecker.GetStatusCallCount).Should(Equal(1))
+
+				fakeClock.WaitForWatcherAndIncrement(interval)
+				Eventually(fakeClock.WatcherCount).Should(Equal(2))
+				Consistently(fakeChecker.GetStatusCallCount, 20*time.Millisecond).Should(Equal(1))
+
+				fakeClock.Increment(100 * time.Millisecond)
+				Eventually(fakeChecker.GetStatusCallCount).Should(Equal(2))
+			})
+		})
+	})
+
+	Describe("thresholds", func() {
+		var ip string
+
+		BeforeEach(func() {
+			ip = "127.0.0.2"
+			config.HealthyThreshold = 2
+			config.UnhealthyThreshold = 3
+			setStatus(healthiness.StatusHealthy, nil)
+		})
+
+		JustBeforeEach(func() {
+			healthWatcher.Status(ip)
+			Eventually(fakeChecker.GetStatusCallCount).Should(Equal(1))
+		})
+
+		tick := func(expectedChecks int) {
+			fakeClock.WaitForWatcherAndIncrement(interval)
+			Eventually(fakeChecker.GetStatusCallCount).Should(Equal(expectedChecks))
+		}
+
+		healthStatus := func() healthiness.HealthStatus {
+			return healthWatcher.Status(ip)
+		}
+
+		It("goes unhealthy only after the unhealthy threshold of consecutive failures", func() {
+			setStatus(healthiness.StatusUnhealthy, errors.New("fake-err"))
+
+			tick(2)
+			Consistently(healthStatus).Should(Equal(healthiness.StatusHealthy))
+			tick(3)
+			Consistently(healthStatus).Should(Equal(healthiness.StatusHealthy))
+			tick(4)
+			Eventually(healthStatus).Should(Equal(healthiness.StatusUnhealthy))
+		})
+
+		It("resets the failure count on success", func() {
+			setStatus(healthiness.StatusUnhealthy, errors.New("fake-err"))
+			tick(2)
+			tick(3)
+
+			setStatus(healthiness.StatusHealthy, nil)
+			tick(4)
+
+			setStatus(healthiness.StatusUnhealthy, errors.New("fake-err"))
+			tick(5)
+			tick(6)
+			Consistently(healthStatus).Should(Equal(healthiness.StatusHealthy))
+		})
+
+		It("recovers only after the healthy threshold of consecutive successes", func() {
+			setStatus(healthiness.StatusUnhealthy, errors.New("fake-err"))
+			tick(2)
+			tick(3)
+			tick(4)
+			Eventually(healthStatus).Should(Equal(healthiness.StatusUnhealthy))
+
+			setStatus(healthiness.StatusHealthy, nil)
+			tick(5)
+			Consistently(healthStatus).Should(Equal(healthiness.StatusUnhealthy))
+			tick(6)
+			Eventually(healthStatus).Should(Equal(healthiness.StatusHealthy))
+		})
+
+		It("moves between healthy and degraded without waiting for a threshold", func() {
+			setStatus(healthiness.StatusDegraded, nil)
+			tick(2)
+			Eventually(healthStatus).Should(Equal(healthiness.StatusDegraded))
+
+			setStatus(healthiness.StatusHealthy, nil)
+			tick(3)
+			Eventually(healthStatus).Should(Equal(healthiness.StatusHealthy))
+		})
+
+		It("counts degraded results towards recovery", func() {
+			setStatus(healthiness.StatusUnhealthy, errors.New("fake-err"))
+			tick(2)
+			tick(3)
+			tick(4)
+			Eventually(healthStatus).Should(Equal(healthiness.StatusUnhealthy))
+
+			setStatus(healthiness.StatusDegraded, nil)
+			tick(5)
+			Consistently(healthStatus).Should(Equal(healthiness.StatusUnhealthy))
+			tick(6)
+			Eventually(healthStatus).Should(Equal(healthiness.StatusDegraded))
+		})
+
+		Context("when the ip is first seen failing", func() {
+			BeforeEach(func() {
+				setStatus(healthiness.StatusUnhealthy, errors.New("fake-err"))
+			})
+
+			It("is unhealthy right away", func() {
+				Eventually(healthStatus).Should(Equal(healthiness.StatusUnhealthy))
+			})
+		})
+	})
+
+	Describe("backoff", func() {
+		var ip string
+
+		BeforeEach(func() {
+			ip = "127.0.0.2"
+			config.MaxCheckInterval = 4 * interval
+			setStatus(healthiness.StatusUnhealthy, errors.New("fake-err"))
+		})
+
+		JustBeforeEach(func() {
+			healthWatcher.Status(ip)
+			Eventually(fakeChecker.GetStatusCallCount).Should(Equal(1))
+		})
+
+		checksAfterTicks := func(ticks int) int {
+			for i := 0; i < ticks; i++ {
+				before := fakeChecker.GetStatusCallCount()
+				fakeClock.WaitForWatcherAndIncrement(interval)
+				Consistently(fakeChecker.GetStatusCallCount, 20*time.Millisecond).Should(BeNumerically("<=", before+1))
+			}
+
+			return fakeChecker.GetStatusCallCount()
+		}
+
+		It("doubles the interval between checks of a failing ip up to the maximum", func() {
+			// the next check is due 1, 2, 4 and then 4 ticks after each failure
+			Expect(checksAfterTicks(1)).To(Equal(2))
+			Expect(checksAfterTicks(1)).To(Equal(2))
+			Expect(checksAfterTicks(1)).To(Equal(3))
+			Expect(checksAfterTicks(3)).To(Equal(3))
+			Expect(checksAfterTicks(1)).To(Equal(4))
+			Expect(checksAfterTicks(3)).To(Equal(4))
+			Expect(checksAfterTicks(1)).To(Equal(5))
+		})
+
+		It("reports when the next check is due", func() {
+			checksAfterTicks(3)
+
+			state := healthWatcher.HealthStates()[ip]
+			Expect(state.ConsecutiveFailures).To(Equal(3))
+			Expect(state.NextCheck.Sub(state.LastCheck)).To(Equal(4 * interval))
+		})
+
+		It("returns to the regular interval once the ip is healthy again", func() {
+			checksAfterTicks(3)
+			setStatus(healthiness.StatusHealthy, nil)
+
+			Expect(checksAfterTicks(4)).To(Equal(4))
+			Expect(checksAfterTicks(1)).To(Equal(5))
+			Expect(checksAfterTicks(1)).To(Equal(6))
+		})
+	})
+
+	Describe("History", func() {
+		var ip string
+
+		BeforeEach(func() {
+			ip = "127.0.0.2"
+			config.HistorySize = 2
+			setStatus(healthiness.StatusHealthy, nil)
+		})
+
+		JustBeforeEach(func() {
+			healthWatcher.Status(ip)
+			Eventually(fakeChecker.GetStatusCallCount).Should(Equal(1))
+		})
+
+		tick := func(expectedChecks int) {
+			fakeClock.WaitForWatcherAndIncrement(interval)
+			Eventually(fakeChecker.GetStatusCallCount).Should(Equal(expectedChecks))
+		}
+
+		It("keeps the last results of each ip", func() {
+			firstCheck := fakeClock.Now()
+			Eventually(func() []healthiness.HealthCheckResult {
+				return healthWatcher.History()[ip]
+			}).Should(Equal([]healthiness.HealthCheckResult{
+				{Timestamp: firstCheck, Status: healthiness.StatusHealthy},
+			}))
+
+			setCheck(func(string) (healthiness.HealthStatus, error) {
+				fakeClock.Increment(time.Millisecond)
+				return healthiness.StatusUnhealthy, errors.New("fake-err")
+			})
+			tick(2)
+			secondCheck := fakeClock.Now()
+			tick(3)
+			thirdCheck := fakeClock.Now()
+
+			Eventually(func() []healthiness.HealthCheckResult {
+				return healthWatcher.History()[ip]
+			}).Should(Equal([]healthiness.HealthCheckResult{
+				{Timestamp: secondCheck, Status: healthiness.StatusUnhealthy, Latency: time.Millisecond, Error: "fake-err"},
+				{Timestamp: thirdCheck, Status: healthiness.StatusUnhealthy, Latency: time.Millisecond, Error: "fake-err"},
+			}))
+		})
+
+		It("forgets the results of untracked ips", func() {
+			Eventually(healthWatcher.History).Should(HaveKey(ip))
+
+			healthWatcher.Untrack(ip)
+
+			Expect(healthWatcher.History()).To(BeEmpty())
+		})
+	})
+
+	Describe("flapping", func() {
 		var ip string
 
 		BeforeEach(func() {
 			ip = "127.0.0.2"
-			healthWatcher.IsHealthy(ip)
+			config.FlappingThreshold = 2
+			config.FlappingWindow = 10 * interval
+			setStatus(healthiness.StatusHealthy, nil)
+		})
+
+		JustBeforeEach(func() {
+			healthWatcher.Status(ip)
+			Eventually(fakeChecker.GetStatusCallCount).Should(Equal(1))
+		})
+
+		tick := func(expectedChecks int) {
+			fakeClock.WaitForWatcherAndIncrement(interval)
+			Eventually(fakeChecker.GetStatusCallCount).Should(Equal(expectedChecks))
+		}
+
+		flapping := func() bool {
+			return healthWatcher.HealthStates()[ip].Flapping
+		}
+
+		flap := func() {
+			setStatus(healthiness.StatusUnhealthy, errors.New("fake-err"))
+			tick(2)
+			setStatus(healthiness.StatusHealthy, nil)
+			tick(3)
+			Consistently(flapping).Should(BeFalse())
+
+			setStatus(healthiness.StatusUnhealthy, errors.New("fake-err"))
+			tick(4)
+			Eventually(flapping).Should(BeTrue())
+
+			setStatus(healthiness.StatusHealthy, nil)
+			tick(5)
+		}
+
+		It("flags ips changing their state too often within the window", func() {
+			flap()
+
+			Expect(flapping()).To(BeTrue())
+			Expect(healthWatcher.Status(ip)).To(Equal(healthiness.StatusHealthy))
+		})
+
+		It("clears the flag once the changes are outside of the window", func() {
+			flap()
+
+			for checks := 6; checks < 13; checks++ {
+				tick(checks)
+				Expect(flapping()).To(BeTrue())
+			}
+
+			tick(13)
+			Eventually(flapping).Should(BeFalse())
+		})
+
+		Context("when flapping ips are held unhealthy", func() {
+			BeforeEach(func() {
+				config.HoldFlapping = true
+			})
+
+			It("reports them unhealthy until they are stable", func() {
+				flap()
+
+				Eventually(func() healthiness.HealthStatus {
+					return healthWatcher.Status(ip)
+				}).Should(Equal(healthiness.StatusUnhealthy))
+
+				for checks := 6; checks < 13; checks++ {
+					tick(checks)
+				}
+				Expect(healthWatcher.Status(ip)).To(Equal(healthiness.StatusUnhealthy))
+
+				tick(13)
+				Eventually(func() healthiness.HealthStatus {
+					return healthWatcher.Status(ip)
+				}).Should(Equal(healthiness.StatusHealthy))
+			})
+
+			It("publishes the held status with flapping as the reason", func() {
+				Eventually(healthWatcher.Events()).Should(Receive())
+
+				setStatus(healthiness.StatusDegraded, nil)
+				tick(2)
+				Eventually(healthWatcher.Events()).Should(Receive())
+				setStatus(healthiness.StatusHealthy, nil)
+				tick(3)
+				Eventually(healthWatcher.Events()).Should(Receive())
+				setStatus(healthiness.StatusDegraded, nil)
+				tick(4)
+
+				var event healthiness.HealthEvent
+				Eventually(healthWatcher.Events()).Should(Receive(&event))
+				Expect(event).To(Equal(healthiness.HealthEvent{
+					IP:             ip,
+					Status:         healthiness.StatusUnhealthy,
+					PreviousStatus: healthiness.StatusHealthy,
+					Reason:         "flapping",
+					Timestamp:      fakeClock.Now(),
+				}))
+			})
+		})
+	})
+
+	Describe("HealthStates", func() {
+		It("is empty when nothing is tracked", func() {
+			Expect(healthWatcher.HealthStates()).To(BeEmpty())
+		})
+
+		It("reports the counters of each tracked ip", func() {
+			setCheck(func(ip string) (healthiness.HealthStatus, error) {
+				if ip == "127.0.0.2" {
+					return healthiness.StatusHealthy, nil
+				}
+
+				return healthiness.StatusUnhealthy, errors.New("fake-err")
+			})
+
+			healthWatcher.Status("127.0.0.2")
+			healthWatcher.Status("127.0.0.3")
+			Eventually(fakeChecker.GetStatusCallCount).Should(Equal(2))
+
+			fakeClock.WaitForWatcherAndIncrement(interval)
+			checkedAt := fakeClock.Now()
+			Eventually(fakeChecker.GetStatusCallCount).Should(Equal(3))
+
+			// the second check of the tick is spread to the middle of the interval
+			Eventually(fakeClock.WatcherCount).Should(Equal(2))
+			fakeClock.Increment(interval / 2)
+			Eventually(fakeChecker.GetStatusCallCount).Should(Equal(4))
+
+			Eventually(func() int {
+				return healthWatcher.HealthStates()["127.0.0.3"].ConsecutiveFailures
+			}).Should(Equal(2))
+
+			states := healthWatcher.HealthStates()
+			Expect(states).To(HaveLen(2))
+
+			Expect(states["127.0.0.2"].Status).To(Equal(healthiness.StatusHealthy))
+			Expect(states["127.0.0.2"].ConsecutiveSuccesses).To(Equal(2))
+			Expect(states["127.0.0.2"].ConsecutiveFailures).To(Equal(0))
+			Expect(states["127.0.0.2"].LastCheck).To(Equal(checkedAt))
+			Expect(states["127.0.0.2"].NextCheck).To(Equal(checkedAt.Add(interval)))
+
+			Expect(states["127.0.0.3"].Status).To(Equal(healthiness.StatusUnhealthy))
+			Expect(states["127.0.0.3"].ConsecutiveSuccesses).To(Equal(0))
+		})
+	})
+
+	Describe("Events", func() {
+		It("publishes the first result of an ip as a change from unknown", func() {
+			setStatus(healthiness.StatusHealthy, nil)
+			healthWatcher.Status("127.0.0.2")
+
+			var event healthiness.HealthEvent
+			Eventually(healthWatcher.Events()).Should(Receive(&event))
+			Expect(event).To(Equal(healthiness.HealthEvent{
+				IP:             "127.0.0.2",
+				Status:         healthiness.StatusHealthy,
+				PreviousStatus: healthiness.StatusUnknown,
+				Timestamp:      fakeClock.Now(),
+			}))
+		})
+
+		It("publishes changes of the status with the reason of the failing check", func() {
+			setStatus(healthiness.StatusHealthy, nil)
+			healthWatcher.Status("127.0.0.2")
+			Eventually(healthWatcher.Events()).Should(Receive())
+
+			fakeClock.WaitForWatcherAndIncrement(interval)
+			Eventually(fakeChecker.GetStatusCallCount).Should(Equal(2))
+			Consistently(healthWatcher.Events()).ShouldNot(Receive())
+
+			setStatus(healthiness.StatusUnhealthy, errors.New("connection refused"))
+			fakeClock.WaitForWatcherAndIncrement(interval)
+
+			var event healthiness.HealthEvent
+			Eventually(healthWatcher.Events()).Should(Receive(&event))
+			Expect(event).To(Equal(healthiness.HealthEvent{
+				IP:             "127.0.0.2",
+				Status:         healthiness.StatusUnhealthy,
+				PreviousStatus: healthiness.StatusHealthy,
+				Reason:         "connection refused",
+				Timestamp:      fakeClock.Now(),
+			}))
+			Expect(healthWatcher.HealthStates()["127.0.0.2"].Reason).To(Equal("connection refused"))
+		})
+
+		It("treats a failing check as unhealthy whatever its status", func() {
+			setStatus(healthiness.StatusHealthy, errors.New("fake-err"))
+			healthWatcher.Status("127.0.0.2")
+
+			var event healthiness.HealthEvent
+			Eventually(healthWatcher.Events()).Should(Receive(&event))
+			Expect(event.Status).To(Equal(healthiness.StatusUnhealthy))
+		})
+	})
+
+	Describe("Restore", func() {
+		JustBeforeEach(func() {
+			healthWatcher.Restore(map[string]healthiness.HealthState{
+				"127.0.0.2": {Status: healthiness.StatusUnhealthy, ConsecutiveFailures: 4},
+			})
+		})
+
+		Context("while the restored ip is being checked", func() {
+			var release chan struct{}
+
+			BeforeEach(func() {
+				release = make(chan struct{})
+				setCheck(func(string) (healthiness.HealthStatus, error) {
+					<-release
+					return healthiness.StatusHealthy, nil
+				})
+			})
+
+			It("uses the restored state until the check completes", func() {
+				Eventually(fakeChecker.GetStatusCallCount).Should(Equal(1))
+				Expect(fakeChecker.GetStatusArgsForCall(0)).To(Equal("127.0.0.2"))
+				Expect(healthWatcher.Status("127.0.0.2")).To(Equal(healthiness.StatusUnhealthy))
+				Expect(healthWatcher.HealthStates()["127.0.0.2"].ConsecutiveFailures).To(Equal(4))
+
+				close(release)
+
+				Eventually(func() healthiness.HealthStatus {
+					return healthWatcher.Status("127.0.0.2")
+				}).Should(Equal(healthiness.StatusHealthy))
+			})
+		})
+
+		It("does not override the state of ips already known", func() {
+			setStatus(healthiness.StatusHealthy, nil)
+			Eventually(fakeChecker.GetStatusCallCount).Should(Equal(1))
+			Eventually(func() healthiness.HealthStatus {
+				return healthWatcher.Status("127.0.0.2")
+			}).Should(Equal(healthiness.StatusHealthy))
+
+			healthWatcher.Restore(map[string]healthiness.HealthState{
+				"127.0.0.2": {Status: healthiness.StatusUnhealthy},
+			})
+
+			Expect(healthWatcher.Status("127.0.0.2")).To(Equal(healthiness.StatusHealthy))
+			Consistently(fakeChecker.GetStatusCallCount).Should(Equal(1))
+		})
+	})
+
+	Describe("ips which have not been checked yet", func() {
+		var release chan struct{}
+
+		BeforeEach(func() {
+			release = make(chan struct{})
+			setCheck(func(string) (healthiness.HealthStatus, error) {
+				<-release
+				return healthiness.StatusUnhealthy, errors.New("fake-err")
+			})
+		})
+
+		AfterEach(func() {
+			close(release)
+		})
+
+		It("checks them only once until the first result is known", func() {
+			healthWatcher.Status("127.0.0.2")
+			healthWatcher.Statuses([]string{"127.0.0.2", "127.0.0.3"})
+			healthWatcher.Status("127.0.0.2")
+
+			Eventually(fakeChecker.GetStatusCallCount).Should(Equal(2))
+			Consistently(fakeChecker.GetStatusCallCount).Should(Equal(2))
+		})
+
+		Context("by default", func() {
+			It("treats them as healthy", func() {
+				Expect(healthWatcher.Statuses([]string{"127.0.0.2"})).To(Equal(map[string]healthiness.HealthStatus{
+					"127.0.0.2": healthiness.StatusHealthy,
+				}))
+			})
+		})
+
+		Context("when they have a low priority", func() {
+			BeforeEach(func() {
+				config.DefaultHealth = dnsconfig.DefaultHealthLowPriority
+			})
+
+			It("reports them as unknown", func() {
+				Expect(healthWatcher.Status("127.0.0.2")).To(Equal(healthiness.StatusUnknown))
+				Expect(healthWatcher.Statuses([]string{"127.0.0.2"})).To(Equal(map[string]healthiness.HealthStatus{
+					"127.0.0.2": healthiness.StatusUnknown,
+				}))
+			})
+		})
+
+		Context("when waiting for their first check", func() {
+			var statuses chan map[string]healthiness.HealthStatus
+
+			BeforeEach(func() {
+				config.DefaultHealth = dnsconfig.DefaultHealthWait
+				config.DefaultHealthWait = 100 * time.Millisecond
+				statuses = make(chan map[string]healthiness.HealthStatus, 1)
+			})
+
+			JustBeforeEach(func() {
+				go func() {
+					statuses <- healthWatcher.Statuses([]string{"127.0.0.2", "127.0.0.3"})
+				}()
+
+				Eventually(fakeChecker.GetStatusCallCount).Should(Equal(2))
+			})
+
+			It("reports the results of checks completing within the budget", func() {
+				Consistently(statuses).ShouldNot(Receive())
+
+				release <- struct{}{}
+				release <- struct{}{}
+
+				Eventually(statuses).Should(Receive(Equal(map[string]healthiness.HealthStatus{
+					"127.0.0.2": healthiness.StatusUnhealthy,
+					"127.0.0.3": healthiness.StatusUnhealthy,
+				})))
+			})
+
+			It("treats ips as healthy when their check exceeds the budget", func() {
+				release <- struct{}{}
+				Eventually(healthWatcher.HealthStates).Should(HaveLen(1))
+
+				Eventually(fakeClock.WatcherCount).Should(Equal(2))
+				fakeClock.Increment(100 * time.Millisecond)
+
+				var received map[string]healthiness.HealthStatus
+				Eventually(statuses).Should(Receive(&received))
+				Expect(received).To(HaveLen(2))
+				Expect(received).To(ContainElement(healthiness.StatusHealthy))
+				Expect(received).To(ContainElement(healthiness.StatusUnhealthy))
+			})
+
+			It("does not wait in Status", func() {
+				Expect(healthWatcher.Status("127.0.0.4")).To(Equal(healthiness.StatusHealthy))
+			})
+		})
+	})
+
+	Describe("Untrack", func() {
+		var ip string
+
+		JustBeforeEach(func() {
+			ip = "127.0.0.2"
+			healthWatcher.Status(ip)
 			Eventually(fakeChecker.GetStatusCallCount).Should(Equal(1))
 			Expect(fakeChecker.GetStatusArgsForCall(0)).To(Equal(ip))
 		})
@@ -7,22 +7,24 @@ import (
 )
 
 type FakeHealthChecker struct {
-	GetStatusStub        func(ip string) bool
+	GetStatusStub        func(ip string) (healthiness.HealthStatus, error)
 	getStatusMutex       sync.RWMutex
 	getStatusArgsForCall []struct {
 		ip string
 	}
 	getStatusReturns struct {
-		result1 bool
+		result1 healthiness.HealthStatus
+		result2 error
 	}
 	getStatusReturnsOnCall map[int]struct {
-		result1 bool
+		result1 healthiness.HealthStatus
+		result2 error
 	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeHealthChecker) GetStatus(ip string) bool {
+func (fake *FakeHealthChecker) GetStatus(ip string) (healthiness.HealthStatus, error) {
 	fake.getStatusMutex.Lock()
 	ret, specificReturn := fake.getStatusReturnsOnCall[len(fake.getStatusArgsForCall)]
 	fake.getStatusArgsForCall = append(fake.getStatusArgsForCall, struct {
@@ -34,9 +36,9 @@ func (fake *FakeHealthChecker) GetStatus(ip string) bool {
 		return fake.GetStatusStub(ip)
 	}
 	if specificReturn {
-		return ret.result1
+		return ret.result1, ret.result2
 	}
-	return fake.getStatusReturns.result1
+	return fake.getStatusReturns.result1, fake.getStatusReturns.result2
 }
 
 func (fake *FakeHealthChecker) GetStatusCallCount() int {
@@ -51,23 +53,26 @@ func (fake *FakeHealthChecker) GetStatusArgsForCall(i int) string {
 	return fake.getStatusArgsForCall[i].ip
 }
 
-func (fake *FakeHealthChecker) GetStatusReturns(result1 bool) {
+func (fake *FakeHealthChecker) GetStatusReturns(result1 healthiness.HealthStatus, result2 error) {
 	fake.GetStatusStub = nil
 	fake.getStatusReturns = struct {
-		result1 bool
-	}{result1}
+		result1 healthiness.HealthStatus
+		result2 error
+	}{result1, result2}
 }
 
-func (fake *FakeHealthChecker) GetStatusReturnsOnCall(i int, result1 bool) {
+func (fake *FakeHealthChecker) GetStatusReturnsOnCall(i int, result1 healthiness.HealthStatus, result2 error) {
 	fake.GetStatusStub = nil
 	if fake.getStatusReturnsOnCall == nil {
 		fake.getStatusReturnsOnCall = make(map[int]struct {
-			result1 bool
+			result1 healthiness.HealthStatus
+			result2 error
 		})
 	}
 	fake.getStatusReturnsOnCall[i] = struct {
-		result1 bool
-	}{result1}
+		result1 healthiness.HealthStatus
+		result2 error
+	}{result1, result2}
 }
 
 func (fake *FakeHealthChecker) Invocations() map[string][][]interface{} {
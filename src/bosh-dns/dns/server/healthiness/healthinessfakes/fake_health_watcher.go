@@ -7,22 +7,65 @@ import (
 )
 
 type FakeHealthWatcher struct {
-	IsHealthyStub        func(ip string) bool
-	isHealthyMutex       sync.RWMutex
-	isHealthyArgsForCall []struct {
+	StatusStub        func(ip string) healthiness.HealthStatus
+	statusMutex       sync.RWMutex
+	statusArgsForCall []struct {
 		ip string
 	}
-	isHealthyReturns struct {
-		result1 bool
+	statusReturns struct {
+		result1 healthiness.HealthStatus
 	}
-	isHealthyReturnsOnCall map[int]struct {
-		result1 bool
+	statusReturnsOnCall map[int]struct {
+		result1 healthiness.HealthStatus
+	}
+	StatusesStub        func(ips []string) map[string]healthiness.HealthStatus
+	statusesMutex       sync.RWMutex
+	statusesArgsForCall []struct {
+		ips []string
+	}
+	statusesReturns struct {
+		result1 map[string]healthiness.HealthStatus
+	}
+	statusesReturnsOnCall map[int]struct {
+		result1 map[string]healthiness.HealthStatus
+	}
+	HealthStatesStub        func() map[string]healthiness.HealthState
+	healthStatesMutex       sync.RWMutex
+	healthStatesArgsForCall []struct{}
+	healthStatesReturns     struct {
+		result1 map[string]healthiness.HealthState
+	}
+	healthStatesReturnsOnCall map[int]struct {
+		result1 map[string]healthiness.HealthState
+	}
+	HistoryStub        func() map[string][]healthiness.HealthCheckResult
+	historyMutex       sync.RWMutex
+	historyArgsForCall []struct{}
+	historyReturns     struct {
+		result1 map[string][]healthiness.HealthCheckResult
+	}
+	historyReturnsOnCall map[int]struct {
+		result1 map[string][]healthiness.HealthCheckResult
+	}
+	RestoreStub        func(states map[string]healthiness.HealthState)
+	restoreMutex       sync.RWMutex
+	restoreArgsForCall []struct {
+		states map[string]healthiness.HealthState
 	}
 	UntrackStub        func(ip string)
 	untrackMutex       sync.RWMutex
 	untrackArgsForCall []struct {
 		ip string
 	}
+	EventsStub        func() <-chan healthiness.HealthEvent
+	eventsMutex       sync.RWMutex
+	eventsArgsForCall []struct{}
+	eventsReturns     struct {
+		result1 <-chan healthiness.HealthEvent
+	}
+	eventsReturnsOnCall map[int]struct {
+		result1 <-chan healthiness.HealthEvent
+	}
 	RunStub        func(signal <-chan struct{})
 	runMutex       sync.RWMutex
 	runArgsForCall []struct {
@@ -32,54 +75,211 @@ type FakeHealthWatcher struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeHealthWatcher) IsHealthy(ip string) bool {
-	fake.isHealthyMutex.Lock()
-	ret, specificReturn := fake.isHealthyReturnsOnCall[len(fake.isHealthyArgsForCall)]
-	fake.isHealthyArgsForCall = append(fake.isHealthyArgsForCall, struct {
+func (fake *FakeHealthWatcher) Status(ip string) healthiness.HealthStatus {
+	fake.statusMutex.Lock()
+	ret, specificReturn := fake.statusReturnsOnCall[len(fake.statusArgsForCall)]
+	fake.statusArgsForCall = append(fake.statusArgsForCall, struct {
 		ip string
 	}{ip})
-	fake.recordInvocation("IsHealthy", []interface{}{ip})
-	fake.isHealthyMutex.Unlock()
-	if fake.IsHealthyStub != nil {
-		return fake.IsHealthyStub(ip)
+	fake.recordInvocation("Status", []interface{}{ip})
+	fake.statusMutex.Unlock()
+	if fake.StatusStub != nil {
+		return fake.StatusStub(ip)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.statusReturns.result1
+}
+
+func (fake *FakeHealthWatcher) StatusCallCount() int {
+	fake.statusMutex.RLock()
+	defer fake.statusMutex.RUnlock()
+	return len(fake.statusArgsForCall)
+}
+
+func (fake *FakeHealthWatcher) StatusArgsForCall(i int) string {
+	fake.statusMutex.RLock()
+	defer fake.statusMutex.RUnlock()
+	return fake.statusArgsForCall[i].ip
+}
+
+func (fake *FakeHealthWatcher) StatusReturns(result1 healthiness.HealthStatus) {
+	fake.StatusStub = nil
+	fake.statusReturns = struct {
+		result1 healthiness.HealthStatus
+	}{result1}
+}
+
+func (fake *FakeHealthWatcher) StatusReturnsOnCall(i int, result1 healthiness.HealthStatus) {
+	fake.StatusStub = nil
+	if fake.statusReturnsOnCall == nil {
+		fake.statusReturnsOnCall = make(map[int]struct {
+			result1 healthiness.HealthStatus
+		})
+	}
+	fake.statusReturnsOnCall[i] = struct {
+		result1 healthiness.HealthStatus
+	}{result1}
+}
+
+func (fake *FakeHealthWatcher) Statuses(ips []string) map[string]healthiness.HealthStatus {
+	var ipsCopy []string
+	if ips != nil {
+		ipsCopy = make([]string, len(ips))
+		copy(ipsCopy, ips)
+	}
+	fake.statusesMutex.Lock()
+	ret, specificReturn := fake.statusesReturnsOnCall[len(fake.statusesArgsForCall)]
+	fake.statusesArgsForCall = append(fake.statusesArgsForCall, struct {
+		ips []string
+	}{ipsCopy})
+	fake.recordInvocation("Statuses", []interface{}{ipsCopy})
+	fake.statusesMutex.Unlock()
+	if fake.StatusesStub != nil {
+		return fake.StatusesStub(ips)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.statusesReturns.result1
+}
+
+func (fake *FakeHealthWatcher) StatusesCallCount() int {
+	fake.statusesMutex.RLock()
+	defer fake.statusesMutex.RUnlock()
+	return len(fake.statusesArgsForCall)
+}
+
+func (fake *FakeHealthWatcher) StatusesArgsForCall(i int) []string {
+	fake.statusesMutex.RLock()
+	defer fake.statusesMutex.RUnlock()
+	return fake.statusesArgsForCall[i].ips
+}
+
+func (fake *FakeHealthWatcher) StatusesReturns(result1 map[string]healthiness.HealthStatus) {
+	fake.StatusesStub = nil
+	fake.statusesReturns = struct {
+		result1 map[string]healthiness.HealthStatus
+	}{result1}
+}
+
+func (fake *FakeHealthWatcher) StatusesReturnsOnCall(i int, result1 map[string]healthiness.HealthStatus) {
+	fake.StatusesStub = nil
+	if fake.statusesReturnsOnCall == nil {
+		fake.statusesReturnsOnCall = make(map[int]struct {
+			result1 map[string]healthiness.HealthStatus
+		})
+	}
+	fake.statusesReturnsOnCall[i] = struct {
+		result1 map[string]healthiness.HealthStatus
+	}{result1}
+}
+
+func (fake *FakeHealthWatcher) HealthStates() map[string]healthiness.HealthState {
+	fake.healthStatesMutex.Lock()
+	ret, specificReturn := fake.healthStatesReturnsOnCall[len(fake.healthStatesArgsForCall)]
+	fake.healthStatesArgsForCall = append(fake.healthStatesArgsForCall, struct{}{})
+	fake.recordInvocation("HealthStates", []interface{}{})
+	fake.healthStatesMutex.Unlock()
+	if fake.HealthStatesStub != nil {
+		return fake.HealthStatesStub()
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.isHealthyReturns.result1
+	return fake.healthStatesReturns.result1
 }
 
-func (fake *FakeHealthWatcher) IsHealthyCallCount() int {
-	fake.isHealthyMutex.RLock()
-	defer fake.isHealthyMutex.RUnlock()
-	return len(fake.isHealthyArgsForCall)
+func (fake *FakeHealthWatcher) HealthStatesCallCount() int {
+	fake.healthStatesMutex.RLock()
+	defer fake.healthStatesMutex.RUnlock()
+	return len(fake.healthStatesArgsForCall)
 }
 
-func (fake *FakeHealthWatcher) IsHealthyArgsForCall(i int) string {
-	fake.isHealthyMutex.RLock()
-	defer fake.isHealthyMutex.RUnlock()
-	return fake.isHealthyArgsForCall[i].ip
+func (fake *FakeHealthWatcher) HealthStatesReturns(result1 map[string]healthiness.HealthState) {
+	fake.HealthStatesStub = nil
+	fake.healthStatesReturns = struct {
+		result1 map[string]healthiness.HealthState
+	}{result1}
 }
 
-func (fake *FakeHealthWatcher) IsHealthyReturns(result1 bool) {
-	fake.IsHealthyStub = nil
-	fake.isHealthyReturns = struct {
-		result1 bool
+func (fake *FakeHealthWatcher) HealthStatesReturnsOnCall(i int, result1 map[string]healthiness.HealthState) {
+	fake.HealthStatesStub = nil
+	if fake.healthStatesReturnsOnCall == nil {
+		fake.healthStatesReturnsOnCall = make(map[int]struct {
+			result1 map[string]healthiness.HealthState
+		})
+	}
+	fake.healthStatesReturnsOnCall[i] = struct {
+		result1 map[string]healthiness.HealthState
 	}{result1}
 }
 
-func (fake *FakeHealthWatcher) IsHealthyReturnsOnCall(i int, result1 bool) {
-	fake.IsHealthyStub = nil
-	if fake.isHealthyReturnsOnCall == nil {
-		fake.isHealthyReturnsOnCall = make(map[int]struct {
-			result1 bool
+func (fake *FakeHealthWatcher) History() map[string][]healthiness.HealthCheckResult {
+	fake.historyMutex.Lock()
+	ret, specificReturn := fake.historyReturnsOnCall[len(fake.historyArgsForCall)]
+	fake.historyArgsForCall = append(fake.historyArgsForCall, struct{}{})
+	fake.recordInvocation("History", []interface{}{})
+	fake.historyMutex.Unlock()
+	if fake.HistoryStub != nil {
+		return fake.HistoryStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.historyReturns.result1
+}
+
+func (fake *FakeHealthWatcher) HistoryCallCount() int {
+	fake.historyMutex.RLock()
+	defer fake.historyMutex.RUnlock()
+	return len(fake.historyArgsForCall)
+}
+
+func (fake *FakeHealthWatcher) HistoryReturns(result1 map[string][]healthiness.HealthCheckResult) {
+	fake.HistoryStub = nil
+	fake.historyReturns = struct {
+		result1 map[string][]healthiness.HealthCheckResult
+	}{result1}
+}
+
+func (fake *FakeHealthWatcher) HistoryReturnsOnCall(i int, result1 map[string][]healthiness.HealthCheckResult) {
+	fake.HistoryStub = nil
+	if fake.historyReturnsOnCall == nil {
+		fake.historyReturnsOnCall = make(map[int]struct {
+			result1 map[string][]healthiness.HealthCheckResult
 		})
 	}
-	fake.isHealthyReturnsOnCall[i] = struct {
-		result1 bool
+	fake.historyReturnsOnCall[i] = struct {
+		result1 map[string][]healthiness.HealthCheckResult
 	}{result1}
 }
 
+func (fake *FakeHealthWatcher) Restore(states map[string]healthiness.HealthState) {
+	fake.restoreMutex.Lock()
+	fake.restoreArgsForCall = append(fake.restoreArgsForCall, struct {
+		states map[string]healthiness.HealthState
+	}{states})
+	fake.recordInvocation("Restore", []interface{}{states})
+	fake.restoreMutex.Unlock()
+	if fake.RestoreStub != nil {
+		fake.RestoreStub(states)
+	}
+}
+
+func (fake *FakeHealthWatcher) RestoreCallCount() int {
+	fake.restoreMutex.RLock()
+	defer fake.restoreMutex.RUnlock()
+	return len(fake.restoreArgsForCall)
+}
+
+func (fake *FakeHealthWatcher) RestoreArgsForCall(i int) map[string]healthiness.HealthState {
+	fake.restoreMutex.RLock()
+	defer fake.restoreMutex.RUnlock()
+	return fake.restoreArgsForCall[i].states
+}
+
 func (fake *FakeHealthWatcher) Untrack(ip string) {
 	fake.untrackMutex.Lock()
 	fake.untrackArgsForCall = append(fake.untrackArgsForCall, struct {
@@ -104,6 +304,46 @@ func (fake *FakeHealthWatcher) UntrackArgsForCall(i int) string {
 	return fake.untrackArgsForCall[i].ip
 }
 
+func (fake *FakeHealthWatcher) Events() <-chan healthiness.HealthEvent {
+	fake.eventsMutex.Lock()
+	ret, specificReturn := fake.eventsReturnsOnCall[len(fake.eventsArgsForCall)]
+	fake.eventsArgsForCall = append(fake.eventsArgsForCall, struct{}{})
+	fake.recordInvocation("Events", []interface{}{})
+	fake.eventsMutex.Unlock()
+	if fake.EventsStub != nil {
+		return fake.EventsStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.eventsReturns.result1
+}
+
+func (fake *FakeHealthWatcher) EventsCallCount() int {
+	fake.eventsMutex.RLock()
+	defer fake.eventsMutex.RUnlock()
+	return len(fake.eventsArgsForCall)
+}
+
+func (fake *FakeHealthWatcher) EventsReturns(result1 <-chan healthiness.HealthEvent) {
+	fake.EventsStub = nil
+	fake.eventsReturns = struct {
+		result1 <-chan healthiness.HealthEvent
+	}{result1}
+}
+
+func (fake *FakeHealthWatcher) EventsReturnsOnCall(i int, result1 <-chan healthiness.HealthEvent) {
+	fake.EventsStub = nil
+	if fake.eventsReturnsOnCall == nil {
+		fake.eventsReturnsOnCall = make(map[int]struct {
+			result1 <-chan healthiness.HealthEvent
+		})
+	}
+	fake.eventsReturnsOnCall[i] = struct {
+		result1 <-chan healthiness.HealthEvent
+	}{result1}
+}
+
 func (fake *FakeHealthWatcher) Run(signal <-chan struct{}) {
 	fake.runMutex.Lock()
 	fake.runArgsForCall = append(fake.runArgsForCall, struct {
@@ -131,10 +371,20 @@ func (fake *FakeHealthWatcher) RunArgsForCall(i int) <-chan struct{} {
 func (fake *FakeHealthWatcher) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
-	fake.isHealthyMutex.RLock()
-	defer fake.isHealthyMutex.RUnlock()
+	fake.statusMutex.RLock()
+	defer fake.statusMutex.RUnlock()
+	fake.statusesMutex.RLock()
+	defer fake.statusesMutex.RUnlock()
+	fake.healthStatesMutex.RLock()
+	defer fake.healthStatesMutex.RUnlock()
+	fake.historyMutex.RLock()
+	defer fake.historyMutex.RUnlock()
+	fake.restoreMutex.RLock()
+	defer fake.restoreMutex.RUnlock()
 	fake.untrackMutex.RLock()
 	defer fake.untrackMutex.RUnlock()
+	fake.eventsMutex.RLock()
+	defer fake.eventsMutex.RUnlock()
 	fake.runMutex.RLock()
 	defer fake.runMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
@@ -20,6 +20,17 @@ type FakeRecordSet struct {
 		result1 []string
 		result2 error
 	}
+	HealthStrategyStub        func(domain string) string
+	healthStrategyMutex       sync.RWMutex
+	healthStrategyArgsForCall []struct {
+		domain string
+	}
+	healthStrategyReturns struct {
+		result1 string
+	}
+	healthStrategyReturnsOnCall map[int]struct {
+		result1 string
+	}
 	SubscribeStub        func() <-chan bool
 	subscribeMutex       sync.RWMutex
 	subscribeArgsForCall []struct{}
@@ -84,6 +95,54 @@ func (fake *FakeRecordSet) ResolveReturnsOnCall(i int, result1 []string, result2
 	}{result1, result2}
 }
 
+func (fake *FakeRecordSet) HealthStrategy(domain string) string {
+	fake.healthStrategyMutex.Lock()
+	ret, specificReturn := fake.healthStrategyReturnsOnCall[len(fake.healthStrategyArgsForCall)]
+	fake.healthStrategyArgsForCall = append(fake.healthStrategyArgsForCall, struct {
+		domain string
+	}{domain})
+	fake.recordInvocation("HealthStrategy", []interface{}{domain})
+	fake.healthStrategyMutex.Unlock()
+	if fake.HealthStrategyStub != nil {
+		return fake.HealthStrategyStub(domain)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.healthStrategyReturns.result1
+}
+
+func (fake *FakeRecordSet) HealthStrategyCallCount() int {
+	fake.healthStrategyMutex.RLock()
+	defer fake.healthStrategyMutex.RUnlock()
+	return len(fake.healthStrategyArgsForCall)
+}
+
+func (fake *FakeRecordSet) HealthStrategyArgsForCall(i int) string {
+	fake.healthStrategyMutex.RLock()
+	defer fake.healthStrategyMutex.RUnlock()
+	return fake.healthStrategyArgsForCall[i].domain
+}
+
+func (fake *FakeRecordSet) HealthStrategyReturns(result1 string) {
+	fake.HealthStrategyStub = nil
+	fake.healthStrategyReturns = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakeRecordSet) HealthStrategyReturnsOnCall(i int, result1 string) {
+	fake.HealthStrategyStub = nil
+	if fake.healthStrategyReturnsOnCall == nil {
+		fake.healthStrategyReturnsOnCall = make(map[int]struct {
+			result1 string
+		})
+	}
+	fake.healthStrategyReturnsOnCall[i] = struct {
+		result1 string
+	}{result1}
+}
+
 func (fake *FakeRecordSet) Subscribe() <-chan bool {
 	fake.subscribeMutex.Lock()
 	ret, specificReturn := fake.subscribeReturnsOnCall[len(fake.subscribeArgsForCall)]
@@ -129,6 +188,8 @@ func (fake *FakeRecordSet) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.resolveMutex.RLock()
 	defer fake.resolveMutex.RUnlock()
+	fake.healthStrategyMutex.RLock()
+	defer fake.healthStrategyMutex.RUnlock()
 	fake.subscribeMutex.RLock()
 	defer fake.subscribeMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
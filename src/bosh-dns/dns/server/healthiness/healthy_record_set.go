@@ -2,43 +2,66 @@ package healthiness
 
 import (
 	"bosh-dns/dns/server/healthiness/internal"
+	"bosh-dns/dns/server/records"
+	"sort"
 	"sync"
+	"time"
 )
 
 //go:generate counterfeiter . RecordSet
 
 type RecordSet interface {
 	Resolve(domain string) ([]string, error)
+	HealthStrategy(domain string) string
 	Subscribe() <-chan bool
 }
 
+type HealthSnapshot struct {
+	SavedAt time.Time              `json:"saved_at"`
+	Domains []string               `json:"domains"`
+	States  map[string]HealthState `json:"states"`
+}
+
+// pinnedDomain marks IPs tracked through TrackIPs in the domains of tracked
+// IPs, which keeps them tracked while no queried domain resolves to them.
+const pinnedDomain = ""
+
 type HealthyRecordSet struct {
 	healthWatcher HealthWatcher
+	checkAssigner HealthCheckAssigner
+	events        HealthEventPublisher
 
 	recordSet RecordSet
 
 	trackedDomains *internal.PriorityLimitedTranscript
 
 	trackedIPs      map[string]map[string]struct{}
+	pinnedIPs       map[string]struct{}
 	trackedIPsMutex *sync.Mutex
 }
 
 func NewHealthyRecordSet(
 	recordSet RecordSet,
 	healthWatcher HealthWatcher,
+	checkAssigner HealthCheckAssigner,
+	events HealthEventPublisher,
 	maximumTrackedDomains uint,
 	shutdownChan chan struct{},
 ) *HealthyRecordSet {
 	subscriptionChan := recordSet.Subscribe()
+	healthEvents := healthWatcher.Events()
 
 	hrs := &HealthyRecordSet{
 		healthWatcher: healthWatcher,
+		checkAssigner: checkAssigner,
+		events:        events,
 
 		recordSet: recordSet,
 
 		trackedDomains: internal.NewPriorityLimitedTranscript(maximumTrackedDomains),
 
 		trackedIPs:      map[string]map[string]struct{}{},
+		pinnedIPs:       map[string]struct{}{},
 		trackedIPsMutex: &sync.Mutex{},
 	}
 
@@ -52,6 +75,9 @@ func NewHealthyRecordSet(
 					return
 				}
 				hrs.refreshTrackedIPs()
+			case event := <-healthEvents:
+				event.Domains = hrs.domainsOf(event.IP)
+				hrs.events.Publish(event)
 			}
 		}
 	}()
@@ -63,12 +89,20 @@ func (hrs *HealthyRecordSet) refreshTrackedIPs() {
 	newTrackedIPs := map[string]map[string]struct{}{}
 	hrs.trackedIPsMutex.Lock()
 	defer hrs.trackedIPsMutex.Unlock()
+
+	for ip := range hrs.pinnedIPs {
+		newTrackedIPs[ip] = map[string]struct{}{pinnedDomain: {}}
+		delete(hrs.trackedIPs, ip)
+	}
 	for _, domain := range hrs.trackedDomains.Registry() {
 		ips, err := hrs.recordSet.Resolve(domain)
 		if err != nil {
+			hrs.checkAssigner.Assign(domain, nil)
 			continue
 		}
 
+		hrs.checkAssigner.Assign(domain, ips)
+
 		for _, ip := range ips {
 			if _, ok := newTrackedIPs[ip]; !ok {
 				newTrackedIPs[ip] = map[string]struct{}{}
@@ -78,16 +112,77 @@ func (hrs *HealthyRecordSet) refreshTrackedIPs() {
 			if _, found := hrs.trackedIPs[ip]; found {
 				delete(hrs.trackedIPs, ip)
 			} else {
-				hrs.healthWatcher.IsHealthy(ip)
+				hrs.healthWatcher.Status(ip)
 			}
 		}
 	}
 	for oldIP := range hrs.trackedIPs {
 		hrs.healthWatcher.Untrack(oldIP)
+		hrs.checkAssigner.Untrack(oldIP)
 	}
 	hrs.trackedIPs = newTrackedIPs
 }
 
+func (hrs *HealthyRecordSet) domainsOf(ip string) []string {
+	hrs.trackedIPsMutex.Lock()
+	defer hrs.trackedIPsMutex.Unlock()
+
+	domains := []string{}
+	for domain := range hrs.trackedIPs[ip] {
+		if domain != pinnedDomain {
+			domains = append(domains, domain)
+		}
+	}
+
+	sort.Strings(domains)
+
+	return domains
+}
+
+// TrackIPs keeps the health of the given IPs tracked whether or not they are
+// queried, until they are released with UntrackIPs.
+func (hrs *HealthyRecordSet) TrackIPs(ips []string) {
+	newIPs := []string{}
+
+	hrs.trackedIPsMutex.Lock()
+	for _, ip := range ips {
+		hrs.pinnedIPs[ip] = struct{}{}
+
+		if _, ok := hrs.trackedIPs[ip]; !ok {
+			hrs.trackedIPs[ip] = map[string]struct{}{}
+			newIPs = append(newIPs, ip)
+		}
+		hrs.trackedIPs[ip][pinnedDomain] = struct{}{}
+	}
+	hrs.trackedIPsMutex.Unlock()
+
+	for _, ip := range newIPs {
+		hrs.healthWatcher.Status(ip)
+	}
+}
+
+// UntrackIPs releases IPs given to TrackIPs. Their health stays tracked while
+// a tracked domain resolves to them.
+func (hrs *HealthyRecordSet) UntrackIPs(ips []string) {
+	hrs.trackedIPsMutex.Lock()
+	defer hrs.trackedIPsMutex.Unlock()
+
+	for _, ip := range ips {
+		if _, found := hrs.pinnedIPs[ip]; !found {
+			continue
+		}
+		delete(hrs.pinnedIPs, ip)
+
+		domains := hrs.trackedIPs[ip]
+		delete(domains, pinnedDomain)
+		if len(domains) == 0 {
+			delete(hrs.trackedIPs, ip)
+			hrs.healthWatcher.Untrack(ip)
+			hrs.checkAssigner.Untrack(ip)
+		}
+	}
+}
+
 func (hrs *HealthyRecordSet) untrackDomain(removedDomain string) {
 	hrs.trackedIPsMutex.Lock()
 	defer hrs.trackedIPsMutex.Unlock()
@@ -97,9 +192,71 @@ func (hrs *HealthyRecordSet) untrackDomain(removedDomain string) {
 			delete(domains, removedDomain)
 			if len(domains) == 0 {
 				hrs.healthWatcher.Untrack(ip)
+				hrs.checkAssigner.Untrack(ip)
+			}
+		}
+	}
+
+	hrs.checkAssigner.Assign(removedDomain, nil)
+}
+
+// Snapshot captures the tracked domains, from the least to the most recently
+// queried, and the health of their IPs.
+func (hrs *HealthyRecordSet) Snapshot() HealthSnapshot {
+	return HealthSnapshot{
+		Domains: hrs.trackedDomains.Ordered(),
+		States:  hrs.healthWatcher.HealthStates(),
+	}
+}
+
+// Restore tracks the domains of a snapshot again. The snapshotted health of
+// IPs the domains still resolve to is used until they have been re-checked.
+func (hrs *HealthyRecordSet) Restore(snapshot HealthSnapshot) {
+	hrs.trackedIPsMutex.Lock()
+
+	for _, domain := range snapshot.Domains {
+		if removed := hrs.trackedDomains.Touch(domain); removed != "" {
+			for ip, domains := range hrs.trackedIPs {
+				delete(domains, removed)
+				if len(domains) == 0 {
+					delete(hrs.trackedIPs, ip)
+				}
 			}
+
+			hrs.checkAssigner.Assign(removed, nil)
+		}
+
+		ips, err := hrs.recordSet.Resolve(domain)
+		if err != nil {
+			continue
+		}
+
+		hrs.checkAssigner.Assign(domain, ips)
+
+		for _, ip := range ips {
+			if _, ok := hrs.trackedIPs[ip]; !ok {
+				hrs.trackedIPs[ip] = map[string]struct{}{}
+			}
+			hrs.trackedIPs[ip][domain] = struct{}{}
+		}
+	}
+
+	states := map[string]HealthState{}
+	for ip := range hrs.trackedIPs {
+		if state, found := snapshot.States[ip]; found {
+			states[ip] = state
 		}
 	}
+
+	hrs.healthWatcher.Restore(states)
+
+	for ip := range hrs.trackedIPs {
+		if _, found := states[ip]; !found {
+			hrs.healthWatcher.Status(ip)
+		}
+	}
+
+	hrs.trackedIPsMutex.Unlock()
 }
 
 func (hrs *HealthyRecordSet) Resolve(fqdn string) ([]string, error) {
@@ -112,28 +269,61 @@ func (hrs *HealthyRecordSet) Resolve(fqdn string) ([]string, error) {
 		hrs.untrackDomain(removed)
 	}
 
+	hrs.checkAssigner.Assign(fqdn, ips)
+
 	healthyIPs := []string{}
+	degradedIPs := []string{}
+	unknownIPs := []string{}
+	drainingIPs := []string{}
 	unhealthyIPs := []string{}
 
 	for _, ip := range ips {
 		hrs.trackedIPsMutex.Lock()
-		hrs.trackedIPs[ip] = map[string]struct{}{}
 		if _, ok := hrs.trackedIPs[ip]; !ok {
 			hrs.trackedIPs[ip] = map[string]struct{}{}
 		}
 		hrs.trackedIPs[ip][fqdn] = struct{}{}
 		hrs.trackedIPsMutex.Unlock()
+	}
+
+	statuses := hrs.healthWatcher.Statuses(ips)
 
-		if hrs.healthWatcher.IsHealthy(ip) {
+	for _, ip := range ips {
+		switch statuses[ip] {
+		case StatusHealthy:
 			healthyIPs = append(healthyIPs, ip)
-		} else {
+		case StatusDegraded:
+			degradedIPs = append(degradedIPs, ip)
+		case StatusUnknown:
+			unknownIPs = append(unknownIPs, ip)
+		case StatusDraining:
+			drainingIPs = append(drainingIPs, ip)
+		default:
 			unhealthyIPs = append(unhealthyIPs, ip)
 		}
 	}
 
-	if len(healthyIPs) == 0 {
+	// answers are shuffled, so degraded, not yet checked and draining
+	// instances rank below healthy ones by only being returned when no better
+	// ones are left
+	switch hrs.recordSet.HealthStrategy(fqdn) {
+	case records.HealthStrategyUnhealthy:
 		return unhealthyIPs, nil
+	case records.HealthStrategyAll:
+		return ips, nil
+	case records.HealthStrategyHealthy:
+		return firstNonEmpty(healthyIPs, degradedIPs), nil
+	}
+
+	return firstNonEmpty(healthyIPs, degradedIPs, unknownIPs, drainingIPs, unhealthyIPs), nil
+}
+
+func firstNonEmpty(tiers ...[]string) []string {
+	for _, ips := range tiers {
+		if len(ips) > 0 {
+			return ips
+		}
 	}
 
-	return healthyIPs, nil
+	return []string{}
 }
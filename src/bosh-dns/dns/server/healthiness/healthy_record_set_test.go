@@ -3,18 +3,34 @@ package healthiness_test
 import (
 	"bosh-dns/dns/server/healthiness"
 	"bosh-dns/dns/server/healthiness/healthinessfakes"
+	"bosh-dns/dns/server/records"
 	"errors"
 	"fmt"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 )
 
+func statusesOf(status func(ip string) healthiness.HealthStatus) func([]string) map[string]healthiness.HealthStatus {
+	return func(ips []string) map[string]healthiness.HealthStatus {
+		statuses := map[string]healthiness.HealthStatus{}
+		for _, ip := range ips {
+			statuses[ip] = status(ip)
+		}
+
+		return statuses
+	}
+}
+
 var _ = Describe("HealthyRecordSet", func() {
 	var (
 		fakeRecordSet     *healthinessfakes.FakeRecordSet
 		fakeHealthWatcher *healthinessfakes.FakeHealthWatcher
+		fakeCheckAssigner *healthinessfakes.FakeHealthCheckAssigner
+		fakeEvents        *healthinessfakes.FakeHealthEventPublisher
 		subscriptionChan  chan bool
+		healthEvents      chan healthiness.HealthEvent
 		shutdownChan      chan struct{}
 
 		recordSet *healthiness.HealthyRecordSet
@@ -23,12 +39,16 @@ var _ = Describe("HealthyRecordSet", func() {
 	BeforeEach(func() {
 		fakeRecordSet = &healthinessfakes.FakeRecordSet{}
 		fakeHealthWatcher = &healthinessfakes.FakeHealthWatcher{}
+		fakeCheckAssigner = &healthinessfakes.FakeHealthCheckAssigner{}
+		fakeEvents = &healthinessfakes.FakeHealthEventPublisher{}
 		subscriptionChan = make(chan bool)
 		fakeRecordSet.SubscribeReturns(subscriptionChan)
+		healthEvents = make(chan healthiness.HealthEvent)
+		fakeHealthWatcher.EventsReturns(healthEvents)
 		shutdownChan = make(chan struct{})
 
 		fakeRecordSet.ResolveReturns([]string{"123.123.123.123", "123.123.123.246"}, nil)
-		recordSet = healthiness.NewHealthyRecordSet(fakeRecordSet, fakeHealthWatcher, 5, shutdownChan)
+		recordSet = healthiness.NewHealthyRecordSet(fakeRecordSet, fakeHealthWatcher, fakeCheckAssigner, fakeEvents, 5, shutdownChan)
 	})
 
 	AfterEach(func() {
@@ -44,17 +64,56 @@ var _ = Describe("HealthyRecordSet", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("publishes the health events of the watcher with the domains resolving to the ip", func() {
+		fakeRecordSet.ResolveStub = func(domain string) ([]string, error) {
+			if domain == "other.bosh." {
+				return []string{"123.123.123.123"}, nil
+			}
+
+			return []string{"123.123.123.123", "123.123.123.246"}, nil
+		}
+
+		_, err := recordSet.Resolve("i.g.n.d.d.")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = recordSet.Resolve("other.bosh.")
+		Expect(err).NotTo(HaveOccurred())
+
+		healthEvents <- healthiness.HealthEvent{IP: "123.123.123.123", Status: healthiness.StatusUnhealthy, Reason: "connection refused"}
+		healthEvents <- healthiness.HealthEvent{IP: "123.123.123.246", Status: healthiness.StatusUnhealthy}
+		healthEvents <- healthiness.HealthEvent{IP: "10.0.0.1", Status: healthiness.StatusHealthy}
+
+		Eventually(fakeEvents.PublishCallCount).Should(Equal(3))
+		Expect(fakeEvents.PublishArgsForCall(0)).To(Equal(healthiness.HealthEvent{
+			IP:      "123.123.123.123",
+			Domains: []string{"i.g.n.d.d.", "other.bosh."},
+			Status:  healthiness.StatusUnhealthy,
+			Reason:  "connection refused",
+		}))
+		Expect(fakeEvents.PublishArgsForCall(1).Domains).To(Equal([]string{"i.g.n.d.d."}))
+		Expect(fakeEvents.PublishArgsForCall(2).Domains).To(BeEmpty())
+	})
+
+	It("assigns the resolved ips to the health check of the domain", func() {
+		_, err := recordSet.Resolve("i.g.n.d.d.")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeCheckAssigner.AssignCallCount()).To(Equal(1))
+		domain, ips := fakeCheckAssigner.AssignArgsForCall(0)
+		Expect(domain).To(Equal("i.g.n.d.d."))
+		Expect(ips).To(Equal([]string{"123.123.123.123", "123.123.123.246"}))
+	})
+
 	Context("when some ips are healthy", func() {
 		BeforeEach(func() {
-			fakeHealthWatcher.IsHealthyStub = func(ip string) bool {
+			fakeHealthWatcher.StatusesStub = statusesOf(func(ip string) healthiness.HealthStatus {
 				switch ip {
 				case "123.123.123.123":
-					return true
+					return healthiness.StatusHealthy
 				case "123.123.123.246":
-					return false
+					return healthiness.StatusUnhealthy
 				}
-				return false
-			}
+				return healthiness.StatusUnhealthy
+			})
 		})
 
 		It("returns only the healthy ips", func() {
@@ -66,7 +125,9 @@ var _ = Describe("HealthyRecordSet", func() {
 
 	Context("when all ips are un-healthy", func() {
 		BeforeEach(func() {
-			fakeHealthWatcher.IsHealthyReturns(false)
+			fakeHealthWatcher.StatusesStub = statusesOf(func(string) healthiness.HealthStatus {
+				return healthiness.StatusUnhealthy
+			})
 		})
 
 		It("returns all ips", func() {
@@ -76,12 +137,216 @@ var _ = Describe("HealthyRecordSet", func() {
 		})
 	})
 
+	Describe("health strategies", func() {
+		BeforeEach(func() {
+			fakeRecordSet.ResolveReturns([]string{"123.123.123.123", "123.123.123.246", "123.123.123.5"}, nil)
+		})
+
+		Context("when some ips are healthy", func() {
+			BeforeEach(func() {
+				fakeHealthWatcher.StatusesStub = statusesOf(func(ip string) healthiness.HealthStatus {
+					if ip == "123.123.123.246" {
+						return healthiness.StatusUnhealthy
+					}
+
+					return healthiness.StatusHealthy
+				})
+			})
+
+			DescribeTable("returns the ips selected by the strategy", func(strategy string, expectedIPs ...string) {
+				fakeRecordSet.HealthStrategyReturns(strategy)
+
+				ips, err := recordSet.Resolve("q-s.g.n.d.d.")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ips).To(ConsistOf(expectedIPs))
+				Expect(fakeRecordSet.HealthStrategyArgsForCall(0)).To(Equal("q-s.g.n.d.d."))
+			},
+				Entry("smart", records.HealthStrategySmart, "123.123.123.123", "123.123.123.5"),
+				Entry("unhealthy", records.HealthStrategyUnhealthy, "123.123.123.246"),
+				Entry("all", records.HealthStrategyAll, "123.123.123.123", "123.123.123.246", "123.123.123.5"),
+				Entry("healthy", records.HealthStrategyHealthy, "123.123.123.123", "123.123.123.5"),
+			)
+		})
+
+		Context("when some ips are healthy and some are degraded", func() {
+			BeforeEach(func() {
+				fakeHealthWatcher.StatusesStub = statusesOf(func(ip string) healthiness.HealthStatus {
+					switch ip {
+					case "123.123.123.123":
+						return healthiness.StatusHealthy
+					case "123.123.123.246":
+						return healthiness.StatusDegraded
+					}
+					return healthiness.StatusUnhealthy
+				})
+			})
+
+			DescribeTable("prefers healthy ips over degraded ones", func(strategy string, expectedIPs ...string) {
+				fakeRecordSet.HealthStrategyReturns(strategy)
+
+				ips, err := recordSet.Resolve("q-s.g.n.d.d.")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ips).To(ConsistOf(expectedIPs))
+			},
+				Entry("smart", records.HealthStrategySmart, "123.123.123.123"),
+				Entry("unhealthy", records.HealthStrategyUnhealthy, "123.123.123.5"),
+				Entry("all", records.HealthStrategyAll, "123.123.123.123", "123.123.123.246", "123.123.123.5"),
+				Entry("healthy", records.HealthStrategyHealthy, "123.123.123.123"),
+			)
+		})
+
+		Context("when no ips are healthy but some are degraded", func() {
+			BeforeEach(func() {
+				fakeHealthWatcher.StatusesStub = statusesOf(func(ip string) healthiness.HealthStatus {
+					if ip == "123.123.123.5" {
+						return healthiness.StatusUnhealthy
+					}
+
+					return healthiness.StatusDegraded
+				})
+			})
+
+			DescribeTable("falls back to the degraded ips", func(strategy string, expectedIPs ...string) {
+				fakeRecordSet.HealthStrategyReturns(strategy)
+
+				ips, err := recordSet.Resolve("q-s.g.n.d.d.")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ips).To(ConsistOf(expectedIPs))
+			},
+				Entry("smart", records.HealthStrategySmart, "123.123.123.123", "123.123.123.246"),
+				Entry("unhealthy", records.HealthStrategyUnhealthy, "123.123.123.5"),
+				Entry("all", records.HealthStrategyAll, "123.123.123.123", "123.123.123.246", "123.123.123.5"),
+				Entry("healthy", records.HealthStrategyHealthy, "123.123.123.123", "123.123.123.246"),
+			)
+		})
+
+		Context("when some ips are draining", func() {
+			BeforeEach(func() {
+				fakeHealthWatcher.StatusesStub = statusesOf(func(ip string) healthiness.HealthStatus {
+					switch ip {
+					case "123.123.123.123":
+						return healthiness.StatusDegraded
+					case "123.123.123.246":
+						return healthiness.StatusDraining
+					}
+					return healthiness.StatusUnhealthy
+				})
+			})
+
+			DescribeTable("ranks them below ips which are not draining", func(strategy string, expectedIPs ...string) {
+				fakeRecordSet.HealthStrategyReturns(strategy)
+
+				ips, err := recordSet.Resolve("q-s.g.n.d.d.")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ips).To(ConsistOf(expectedIPs))
+			},
+				Entry("smart", records.HealthStrategySmart, "123.123.123.123"),
+				Entry("unhealthy", records.HealthStrategyUnhealthy, "123.123.123.5"),
+				Entry("all", records.HealthStrategyAll, "123.123.123.123", "123.123.123.246", "123.123.123.5"),
+				Entry("healthy", records.HealthStrategyHealthy, "123.123.123.123"),
+			)
+
+			Context("and no other ip is healthy or degraded", func() {
+				BeforeEach(func() {
+					fakeHealthWatcher.StatusesStub = statusesOf(func(ip string) healthiness.HealthStatus {
+						if ip == "123.123.123.5" {
+							return healthiness.StatusUnhealthy
+						}
+
+						return healthiness.StatusDraining
+					})
+				})
+
+				DescribeTable("returns the draining ips unless only healthy ones are selected", func(strategy string, expectedIPs ...string) {
+					fakeRecordSet.HealthStrategyReturns(strategy)
+
+					ips, err := recordSet.Resolve("q-s.g.n.d.d.")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(ips).To(ConsistOf(expectedIPs))
+				},
+					Entry("smart", records.HealthStrategySmart, "123.123.123.123", "123.123.123.246"),
+					Entry("healthy returns nothing", records.HealthStrategyHealthy),
+				)
+			})
+		})
+
+		Context("when some ips have not been checked yet", func() {
+			BeforeEach(func() {
+				fakeHealthWatcher.StatusesStub = statusesOf(func(ip string) healthiness.HealthStatus {
+					switch ip {
+					case "123.123.123.123":
+						return healthiness.StatusUnknown
+					case "123.123.123.246":
+						return healthiness.StatusDegraded
+					}
+					return healthiness.StatusUnhealthy
+				})
+			})
+
+			DescribeTable("ranks them below checked ips which are not failing", func(strategy string, expectedIPs ...string) {
+				fakeRecordSet.HealthStrategyReturns(strategy)
+
+				ips, err := recordSet.Resolve("q-s.g.n.d.d.")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ips).To(ConsistOf(expectedIPs))
+			},
+				Entry("smart", records.HealthStrategySmart, "123.123.123.246"),
+				Entry("unhealthy", records.HealthStrategyUnhealthy, "123.123.123.5"),
+				Entry("all", records.HealthStrategyAll, "123.123.123.123", "123.123.123.246", "123.123.123.5"),
+				Entry("healthy", records.HealthStrategyHealthy, "123.123.123.246"),
+			)
+
+			Context("and no checked ip is healthy or degraded", func() {
+				BeforeEach(func() {
+					fakeHealthWatcher.StatusesStub = statusesOf(func(ip string) healthiness.HealthStatus {
+						if ip == "123.123.123.123" {
+							return healthiness.StatusUnknown
+						}
+						return healthiness.StatusUnhealthy
+					})
+				})
+
+				DescribeTable("prefers them over failing ips unless only healthy ones are selected", func(strategy string, expectedIPs ...string) {
+					fakeRecordSet.HealthStrategyReturns(strategy)
+
+					ips, err := recordSet.Resolve("q-s.g.n.d.d.")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(ips).To(ConsistOf(expectedIPs))
+				},
+					Entry("smart", records.HealthStrategySmart, "123.123.123.123"),
+					Entry("healthy returns nothing", records.HealthStrategyHealthy),
+				)
+			})
+		})
+
+		Context("when all ips are un-healthy", func() {
+			BeforeEach(func() {
+				fakeHealthWatcher.StatusesStub = statusesOf(func(string) healthiness.HealthStatus {
+					return healthiness.StatusUnhealthy
+				})
+			})
+
+			DescribeTable("returns the ips selected by the strategy", func(strategy string, expectedIPs ...string) {
+				fakeRecordSet.HealthStrategyReturns(strategy)
+
+				ips, err := recordSet.Resolve("q-s.g.n.d.d.")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ips).To(ConsistOf(expectedIPs))
+			},
+				Entry("smart falls back to all", records.HealthStrategySmart, "123.123.123.123", "123.123.123.246", "123.123.123.5"),
+				Entry("unhealthy", records.HealthStrategyUnhealthy, "123.123.123.123", "123.123.123.246", "123.123.123.5"),
+				Entry("all", records.HealthStrategyAll, "123.123.123.123", "123.123.123.246", "123.123.123.5"),
+				Entry("healthy returns nothing", records.HealthStrategyHealthy),
+			)
+		})
+	})
+
 	Context("when the ips under a tracked domain change", func() {
 		BeforeEach(func() {
 			recordSet.Resolve("i.g.n.d.d.")
 			fakeRecordSet.ResolveReturns([]string{"123.123.123.123", "123.123.123.5"}, nil)
 
-			Expect(fakeHealthWatcher.IsHealthyCallCount()).To(Equal(2))
+			Expect(fakeHealthWatcher.StatusesCallCount()).To(Equal(1))
 			subscriptionChan <- true
 			Eventually(fakeRecordSet.ResolveCallCount).Should(Equal(2))
 		})
@@ -93,21 +358,33 @@ var _ = Describe("HealthyRecordSet", func() {
 		})
 
 		It("checks the health of new ones", func() {
-			Eventually(fakeHealthWatcher.IsHealthyCallCount).Should(Equal(3))
-			Expect(fakeHealthWatcher.IsHealthyArgsForCall(2)).To(Equal("123.123.123.5"))
+			Eventually(fakeHealthWatcher.StatusCallCount).Should(Equal(1))
+			Expect(fakeHealthWatcher.StatusArgsForCall(0)).To(Equal("123.123.123.5"))
+		})
+
+		It("assigns the new ones to the health check of the domain", func() {
+			Eventually(fakeCheckAssigner.AssignCallCount).Should(Equal(2))
+			domain, ips := fakeCheckAssigner.AssignArgsForCall(1)
+			Expect(domain).To(Equal("i.g.n.d.d."))
+			Expect(ips).To(Equal([]string{"123.123.123.123", "123.123.123.5"}))
 		})
 
 		It("stops tracking old ones", func() {
 			Eventually(fakeHealthWatcher.UntrackCallCount).Should(Equal(1))
 			Expect(fakeHealthWatcher.UntrackArgsForCall(0)).To(Equal("123.123.123.246"))
 		})
+
+		It("releases the health check of old ones", func() {
+			Eventually(fakeCheckAssigner.UntrackCallCount).Should(Equal(1))
+			Expect(fakeCheckAssigner.UntrackArgsForCall(0)).To(Equal("123.123.123.246"))
+		})
 	})
 
 	Context("when the ips not under a tracked domain change", func() {
 		BeforeEach(func() {
 			fakeRecordSet.ResolveReturns([]string{"123.123.123.123", "123.123.123.5"}, nil)
 
-			Expect(fakeHealthWatcher.IsHealthyCallCount()).To(Equal(0))
+			Expect(fakeHealthWatcher.StatusCallCount()).To(Equal(0))
 			subscriptionChan <- true
 		})
 
@@ -118,7 +395,7 @@ var _ = Describe("HealthyRecordSet", func() {
 		})
 
 		It("does not checks the health of new ones", func() {
-			Expect(fakeHealthWatcher.IsHealthyCallCount()).To(Equal(0))
+			Expect(fakeHealthWatcher.StatusCallCount()).To(Equal(0))
 		})
 
 		It("doesn't untrack anything", func() {
@@ -162,4 +439,141 @@ var _ = Describe("HealthyRecordSet", func() {
 			))
 		})
 	})
+
+	Describe("TrackIPs", func() {
+		BeforeEach(func() {
+			fakeRecordSet.ResolveStub = func(domain string) ([]string, error) {
+				return []string{"10.0.0.2"}, nil
+			}
+		})
+
+		It("checks the health of new ips", func() {
+			recordSet.TrackIPs([]string{"10.0.0.1", "10.0.0.2"})
+
+			Expect(fakeHealthWatcher.StatusCallCount()).To(Equal(2))
+			Expect(fakeHealthWatcher.StatusArgsForCall(0)).To(Equal("10.0.0.1"))
+			Expect(fakeHealthWatcher.StatusArgsForCall(1)).To(Equal("10.0.0.2"))
+
+			recordSet.TrackIPs([]string{"10.0.0.1", "10.0.0.2"})
+			Expect(fakeHealthWatcher.StatusCallCount()).To(Equal(2))
+		})
+
+		It("untracks released ips unless a tracked domain resolves to them", func() {
+			recordSet.TrackIPs([]string{"10.0.0.1", "10.0.0.2"})
+			_, err := recordSet.Resolve("a.")
+			Expect(err).NotTo(HaveOccurred())
+
+			recordSet.UntrackIPs([]string{"10.0.0.1", "10.0.0.2"})
+
+			Expect(fakeHealthWatcher.UntrackCallCount()).To(Equal(1))
+			Expect(fakeHealthWatcher.UntrackArgsForCall(0)).To(Equal("10.0.0.1"))
+		})
+
+		It("keeps tracking ips given before", func() {
+			recordSet.TrackIPs([]string{"10.0.0.1"})
+			recordSet.TrackIPs([]string{"10.0.0.3"})
+
+			recordSet.UntrackIPs([]string{"10.0.0.3"})
+
+			Expect(fakeHealthWatcher.UntrackCallCount()).To(Equal(1))
+			Expect(fakeHealthWatcher.UntrackArgsForCall(0)).To(Equal("10.0.0.3"))
+		})
+
+		It("ignores ips which were not given before", func() {
+			recordSet.UntrackIPs([]string{"10.0.0.1"})
+
+			Expect(fakeHealthWatcher.UntrackCallCount()).To(Equal(0))
+		})
+
+		It("keeps tracking them when the records change", func() {
+			recordSet.TrackIPs([]string{"10.0.0.1"})
+
+			subscriptionChan <- true
+			Consistently(fakeHealthWatcher.UntrackCallCount).Should(Equal(0))
+		})
+
+		It("does not report them as domains of health events", func() {
+			recordSet.TrackIPs([]string{"10.0.0.2"})
+			_, err := recordSet.Resolve("a.")
+			Expect(err).NotTo(HaveOccurred())
+
+			healthEvents <- healthiness.HealthEvent{IP: "10.0.0.2"}
+
+			Eventually(fakeEvents.PublishCallCount).Should(Equal(1))
+			Expect(fakeEvents.PublishArgsForCall(0).Domains).To(Equal([]string{"a."}))
+		})
+	})
+
+	Describe("snapshots", func() {
+		BeforeEach(func() {
+			fakeRecordSet.ResolveStub = func(domain string) ([]string, error) {
+				switch domain {
+				case "a.":
+					return []string{"10.0.0.1", "10.0.0.2"}, nil
+				case "b.":
+					return []string{"10.0.0.3"}, nil
+				}
+				return nil, errors.New("NXDOMAIN")
+			}
+		})
+
+		It("captures the tracked domains in order of use and the health of their ips", func() {
+			states := map[string]healthiness.HealthState{"10.0.0.1": {Status: healthiness.StatusDegraded}}
+			fakeHealthWatcher.HealthStatesReturns(states)
+
+			recordSet.Resolve("a.")
+			recordSet.Resolve("b.")
+			recordSet.Resolve("a.")
+
+			snapshot := recordSet.Snapshot()
+			Expect(snapshot.Domains).To(Equal([]string{"b.", "a."}))
+			Expect(snapshot.States).To(Equal(states))
+		})
+
+		Describe("Restore", func() {
+			BeforeEach(func() {
+				recordSet.Restore(healthiness.HealthSnapshot{
+					Domains: []string{"b.", "a.", "gone."},
+					States: map[string]healthiness.HealthState{
+						"10.0.0.1": {Status: healthiness.StatusUnhealthy},
+						"10.0.0.3": {Status: healthiness.StatusDegraded},
+						"10.0.0.9": {Status: healthiness.StatusUnhealthy},
+					},
+				})
+			})
+
+			It("restores the state of ips the domains still resolve to", func() {
+				Expect(fakeHealthWatcher.RestoreCallCount()).To(Equal(1))
+				Expect(fakeHealthWatcher.RestoreArgsForCall(0)).To(Equal(map[string]healthiness.HealthState{
+					"10.0.0.1": {Status: healthiness.StatusUnhealthy},
+					"10.0.0.3": {Status: healthiness.StatusDegraded},
+				}))
+			})
+
+			It("starts checking ips without a restored state", func() {
+				Expect(fakeHealthWatcher.StatusCallCount()).To(Equal(1))
+				Expect(fakeHealthWatcher.StatusArgsForCall(0)).To(Equal("10.0.0.2"))
+			})
+
+			It("tracks the restored domains", func() {
+				Expect(recordSet.Snapshot().Domains).To(Equal([]string{"b.", "a.", "gone."}))
+
+				subscriptionChan <- true
+				Eventually(fakeCheckAssigner.AssignCallCount).Should(Equal(5))
+			})
+
+			It("releases the checks of restored domains which no longer resolve", func() {
+				subscriptionChan <- true
+				Eventually(fakeCheckAssigner.AssignCallCount).Should(Equal(5))
+
+				assigned := map[string][]string{}
+				for i := 0; i < fakeCheckAssigner.AssignCallCount(); i++ {
+					domain, ips := fakeCheckAssigner.AssignArgsForCall(i)
+					assigned[domain] = ips
+				}
+
+				Expect(assigned).To(HaveKeyWithValue("gone.", BeNil()))
+			})
+		})
+	})
 })
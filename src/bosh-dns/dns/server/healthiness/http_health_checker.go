@@ -0,0 +1,48 @@
+package healthiness
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+)
+
+type httpHealthChecker struct {
+	client         HTTPClientGetter
+	port           int
+	path           string
+	expectedStatus int
+}
+
+func NewHTTPHealthChecker(client HTTPClientGetter, port int, path string, expectedStatus int) HealthChecker {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return &httpHealthChecker{
+		client:         client,
+		port:           port,
+		path:           path,
+		expectedStatus: expectedStatus,
+	}
+}
+
+func (hc *httpHealthChecker) GetStatus(ip string) (HealthStatus, error) {
+	endpoint := fmt.Sprintf("http://%s%s", net.JoinHostPort(ip, fmt.Sprintf("%d", hc.port)), hc.path)
+
+	response, err := hc.client.Get(endpoint)
+	if err != nil {
+		return StatusUnhealthy, err
+	}
+
+	// drain the body so the connection can be reused by the next check
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+
+	if response.StatusCode != hc.expectedStatus {
+		return StatusUnhealthy, fmt.Errorf("expected status %d but got %d", hc.expectedStatus, response.StatusCode)
+	}
+
+	return StatusHealthy, nil
+}
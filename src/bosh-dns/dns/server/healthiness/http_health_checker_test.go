@@ -0,0 +1,64 @@
+package healthiness_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"bosh-dns/dns/server/healthiness"
+	"bosh-dns/dns/server/healthiness/healthinessfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTPHealthChecker", func() {
+	var (
+		fakeClient    *healthinessfakes.FakeHTTPClientGetter
+		healthChecker healthiness.HealthChecker
+	)
+
+	respondWith := func(statusCode int) {
+		fakeClient.GetReturns(&http.Response{
+			StatusCode: statusCode,
+			Body:       ioutil.NopCloser(bytes.NewBufferString("body")),
+		}, nil)
+	}
+
+	BeforeEach(func() {
+		fakeClient = &healthinessfakes.FakeHTTPClientGetter{}
+		healthChecker = healthiness.NewHTTPHealthChecker(fakeClient, 8080, "/ping", 204)
+	})
+
+	It("is healthy when the expected status is returned", func() {
+		respondWith(204)
+
+		Expect(healthChecker.GetStatus("127.0.0.1")).To(Equal(healthiness.StatusHealthy))
+		Expect(fakeClient.GetArgsForCall(0)).To(Equal("http://127.0.0.1:8080/ping"))
+	})
+
+	It("is unhealthy when another status is returned", func() {
+		respondWith(200)
+
+		status, err := healthChecker.GetStatus("127.0.0.1")
+		Expect(err).To(MatchError("expected status 204 but got 200"))
+		Expect(status).To(Equal(healthiness.StatusUnhealthy))
+	})
+
+	It("is unhealthy when the request fails", func() {
+		fakeClient.GetReturns(nil, errors.New("fake-err"))
+
+		status, err := healthChecker.GetStatus("127.0.0.1")
+		Expect(err).To(MatchError("fake-err"))
+		Expect(status).To(Equal(healthiness.StatusUnhealthy))
+	})
+
+	It("brackets IPv6 addresses and adds a leading slash to the path", func() {
+		healthChecker = healthiness.NewHTTPHealthChecker(fakeClient, 8080, "ping", 200)
+		respondWith(200)
+
+		Expect(healthChecker.GetStatus("::1")).To(Equal(healthiness.StatusHealthy))
+		Expect(fakeClient.GetArgsForCall(0)).To(Equal("http://[::1]:8080/ping"))
+	})
+})
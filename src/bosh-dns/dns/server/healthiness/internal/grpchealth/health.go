@@ -0,0 +1,100 @@
+// Package grpchealth implements the client and server side of the standard
+// gRPC health checking protocol (grpc.health.v1.Health). The vendored grpc
+// release does not ship the generated health package, so the two messages
+// are declared by hand with the same wire format.
+package grpchealth
+
+import (
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+type ServingStatus int32
+
+const (
+	ServingStatusUnknown    ServingStatus = 0
+	ServingStatusServing    ServingStatus = 1
+	ServingStatusNotServing ServingStatus = 2
+)
+
+var servingStatusNames = map[int32]string{
+	0: "UNKNOWN",
+	1: "SERVING",
+	2: "NOT_SERVING",
+}
+
+func (s ServingStatus) String() string {
+	return proto.EnumName(servingStatusNames, int32(s))
+}
+
+type HealthCheckRequest struct {
+	Service string `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+}
+
+func (m *HealthCheckRequest) Reset()         { *m = HealthCheckRequest{} }
+func (m *HealthCheckRequest) String() string { return proto.CompactTextString(m) }
+func (*HealthCheckRequest) ProtoMessage()    {}
+
+type HealthCheckResponse struct {
+	Status ServingStatus `protobuf:"varint,1,opt,name=status,proto3" json:"status,omitempty"`
+}
+
+func (m *HealthCheckResponse) Reset()         { *m = HealthCheckResponse{} }
+func (m *HealthCheckResponse) String() string { return proto.CompactTextString(m) }
+func (*HealthCheckResponse) ProtoMessage()    {}
+
+const checkMethod = "/grpc.health.v1.Health/Check"
+
+func Check(ctx context.Context, conn *grpc.ClientConn, service string) (ServingStatus, error) {
+	out := new(HealthCheckResponse)
+
+	err := grpc.Invoke(ctx, checkMethod, &HealthCheckRequest{Service: service}, out, conn)
+	if err != nil {
+		return ServingStatusUnknown, err
+	}
+
+	return out.Status, nil
+}
+
+type HealthServer interface {
+	Check(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error)
+}
+
+func RegisterHealthServer(s *grpc.Server, srv HealthServer) {
+	s.RegisterService(&healthServiceDesc, srv)
+}
+
+func checkHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HealthCheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	if interceptor == nil {
+		return srv.(HealthServer).Check(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: checkMethod,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HealthServer).Check(ctx, req.(*HealthCheckRequest))
+	}
+
+	return interceptor(ctx, in, info, handler)
+}
+
+var healthServiceDesc = grpc.ServiceDesc{
+	ServiceName: "grpc.health.v1.Health",
+	HandlerType: (*HealthServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Check",
+			Handler:    checkHandler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "health.proto",
+}
@@ -96,3 +96,16 @@ func (t *PriorityLimitedTranscript) Registry() []string {
 	}
 	return r
 }
+
+// Ordered lists the names from the least to the most recently touched, so
+// that touching them in this order recreates the same priorities.
+func (t *PriorityLimitedTranscript) Ordered() []string {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	r := make([]string, 0, len(t.names))
+	for l := t.oldest(); l != t.head; l = l.next {
+		r = append(r, l.name)
+	}
+	return r
+}
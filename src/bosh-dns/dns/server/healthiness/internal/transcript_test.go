@@ -68,6 +68,15 @@ var _ = Describe("PriorityLimitedTranscript", func() {
 		}))
 	})
 
+	It("orders names from the least to the most recently touched", func() {
+		transcript.Touch("one")
+		transcript.Touch("two")
+		transcript.Touch("three")
+		transcript.Touch("one")
+
+		Expect(transcript.Ordered()).To(Equal([]string{"two", "three", "one"}))
+	})
+
 	It("is threadsafe", func() {
 		done := make(chan struct{})
 		wg := sync.WaitGroup{}
@@ -6,12 +6,35 @@ func NewNopHealthWatcher() *nopHealthWatcher {
 	return &nopHealthWatcher{}
 }
 
-func (hw *nopHealthWatcher) IsHealthy(ip string) bool {
-	return true
+func (hw *nopHealthWatcher) Status(ip string) HealthStatus {
+	return StatusHealthy
 }
 
+func (hw *nopHealthWatcher) Statuses(ips []string) map[string]HealthStatus {
+	statuses := make(map[string]HealthStatus, len(ips))
+	for _, ip := range ips {
+		statuses[ip] = StatusHealthy
+	}
+
+	return statuses
+}
+
+func (hw *nopHealthWatcher) HealthStates() map[string]HealthState {
+	return map[string]HealthState{}
+}
+
+func (hw *nopHealthWatcher) History() map[string][]HealthCheckResult {
+	return map[string][]HealthCheckResult{}
+}
+
+func (hw *nopHealthWatcher) Restore(states map[string]HealthState) {}
+
 func (hw *nopHealthWatcher) Untrack(ip string) {}
 
+func (hw *nopHealthWatcher) Events() <-chan HealthEvent {
+	return nil
+}
+
 func (hw *nopHealthWatcher) Run(signal <-chan struct{}) {
 	<-signal
 }
@@ -31,7 +31,7 @@ var _ = Describe("NopHealthWatcher", func() {
 		Eventually(stopped).Should(BeClosed())
 	})
 
-	Describe("IsHealthy", func() {
+	Describe("Status", func() {
 		var ip string
 
 		BeforeEach(func() {
@@ -39,7 +39,7 @@ var _ = Describe("NopHealthWatcher", func() {
 		})
 
 		It("is always healthy", func() {
-			Expect(healthWatcher.IsHealthy(ip)).To(BeTrue())
+			Expect(healthWatcher.Status(ip)).To(Equal(healthiness.StatusHealthy))
 		})
 	})
 })
@@ -0,0 +1,256 @@
+package healthiness
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type PeerHealthCheckerConfig struct {
+	Peers              []string
+	LocalIPs           []string
+	Quorum             int
+	MaxAge             time.Duration
+	LocalCheckInterval time.Duration
+	FetchInterval      time.Duration
+}
+
+const peerHealthCheckerLogTag = "PeerHealthChecker"
+
+// PeerHealthChecker shares checks with the peers serving the health they
+// observed. Once a quorum of fresh peer observations agree on an IP, their
+// result is used instead of a local check. Every IP is still checked locally
+// at least every LocalCheckInterval, and IPs failing their last local check
+// are checked locally until they recover. Peers resolving to one of LocalIPs
+// are this instance and are skipped.
+type PeerHealthChecker struct {
+	local  HealthChecker
+	client HTTPClientGetter
+	peers  RecordSet
+	port   int
+	clock  clock.Clock
+	config PeerHealthCheckerConfig
+	logger boshlog.Logger
+
+	observations map[string]HealthSnapshot
+	localStates  map[string]HealthState
+	mutex        *sync.Mutex
+}
+
+func NewPeerHealthChecker(
+	local HealthChecker,
+	client HTTPClientGetter,
+	peers RecordSet,
+	port int,
+	clock clock.Clock,
+	config PeerHealthCheckerConfig,
+	logger boshlog.Logger,
+) *PeerHealthChecker {
+	return &PeerHealthChecker{
+		local:  local,
+		client: client,
+		peers:  peers,
+		port:   port,
+		clock:  clock,
+		config: config,
+		logger: logger,
+
+		observations: map[string]HealthSnapshot{},
+		localStates:  map[string]HealthState{},
+		mutex:        &sync.Mutex{},
+	}
+}
+
+func (c *PeerHealthChecker) GetStatus(ip string) (HealthStatus, error) {
+	status, votes, found := c.consensus(ip)
+	if found {
+		if status == StatusUnhealthy {
+			return StatusUnhealthy, fmt.Errorf("%d peers observed the ip as unhealthy", votes)
+		}
+
+		return status, nil
+	}
+
+	status, err := c.local.GetStatus(ip)
+
+	state := HealthState{Status: status, LastCheck: c.clock.Now()}
+	if err != nil {
+		state.Status = StatusUnhealthy
+		state.Reason = err.Error()
+	}
+
+	c.mutex.Lock()
+	c.localStates[ip] = state
+	c.mutex.Unlock()
+
+	return status, err
+}
+
+// consensus returns the status most fresh peer observations agree on, if it
+// was observed by at least a quorum and a majority of them.
+func (c *PeerHealthChecker) consensus(ip string) (HealthStatus, int, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	local, found := c.localStates[ip]
+	if !found || local.Status == StatusUnhealthy || c.clock.Since(local.LastCheck) >= c.config.LocalCheckInterval {
+		return StatusUnknown, 0, false
+	}
+
+	votes := map[HealthStatus]int{}
+	total := 0
+
+	for _, observations := range c.observations {
+		state, found := observations.States[ip]
+		if !found || state.Status == StatusUnknown || c.clock.Since(state.LastCheck) > c.config.MaxAge {
+			continue
+		}
+
+		votes[state.Status]++
+		total++
+	}
+
+	for status, count := range votes {
+		if count >= c.config.Quorum && count*2 > total {
+			return status, count, true
+		}
+	}
+
+	return StatusUnknown, 0, false
+}
+
+// Snapshot returns the local checks, which are the observations served to
+// peers. Results taken from peers are never served back to them.
+func (c *PeerHealthChecker) Snapshot() HealthSnapshot {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	states := map[string]HealthState{}
+	for ip, state := range c.localStates {
+		if c.clock.Since(state.LastCheck) < c.config.LocalCheckInterval {
+			states[ip] = state
+		}
+	}
+
+	return HealthSnapshot{Domains: []string{}, States: states}
+}
+
+func (c *PeerHealthChecker) Restore(snapshot HealthSnapshot) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for ip, state := range snapshot.States {
+		c.localStates[ip] = state
+	}
+}
+
+// Run fetches the observations of the peers every FetchInterval.
+func (c *PeerHealthChecker) Run(shutdown <-chan struct{}) {
+	ticker := c.clock.NewTicker(c.config.FetchInterval)
+	defer ticker.Stop()
+
+	for {
+		c.Fetch()
+
+		select {
+		case <-ticker.C():
+		case <-shutdown:
+			return
+		}
+	}
+}
+
+// Fetch replaces the observations with those currently served by the peers
+// and forgets local checks too old to be used.
+func (c *PeerHealthChecker) Fetch() {
+	localIPs := map[string]struct{}{}
+	for _, ip := range c.config.LocalIPs {
+		localIPs[ip] = struct{}{}
+	}
+
+	peerIPs := map[string]struct{}{}
+	for _, peer := range c.config.Peers {
+		ips, err := c.peers.Resolve(peer)
+		if err != nil {
+			c.logger.Debug(peerHealthCheckerLogTag, "Resolving peers %s: %s", peer, err)
+			continue
+		}
+
+		for _, ip := range ips {
+			if _, found := localIPs[ip]; !found {
+				peerIPs[ip] = struct{}{}
+			}
+		}
+	}
+
+	observations := map[string]HealthSnapshot{}
+	observationsMutex := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+
+	for ip := range peerIPs {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+
+			snapshot, err := c.fetchPeer(ip)
+			if err != nil {
+				c.logger.Debug(peerHealthCheckerLogTag, "Fetching observations of peer %s: %s", ip, err)
+				return
+			}
+
+			observationsMutex.Lock()
+			observations[ip] = snapshot
+			observationsMutex.Unlock()
+		}(ip)
+	}
+
+	wg.Wait()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.observations = observations
+
+	for ip, state := range c.localStates {
+		if c.clock.Since(state.LastCheck) >= c.config.LocalCheckInterval {
+			delete(c.localStates, ip)
+		}
+	}
+}
+
+func (c *PeerHealthChecker) fetchPeer(ip string) (HealthSnapshot, error) {
+	endpoint := fmt.Sprintf("https://%s/health/observations", net.JoinHostPort(ip, fmt.Sprintf("%d", c.port)))
+
+	response, err := c.client.Get(endpoint)
+	if err != nil {
+		return HealthSnapshot{}, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 {
+		return HealthSnapshot{}, fmt.Errorf("health server responded with status %d", response.StatusCode)
+	}
+
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return HealthSnapshot{}, err // untested
+	}
+
+	var snapshot HealthSnapshot
+	err = json.Unmarshal(responseBytes, &snapshot)
+	if err != nil {
+		return HealthSnapshot{}, err
+	}
+
+	if c.clock.Since(snapshot.SavedAt) > c.config.MaxAge {
+		return HealthSnapshot{}, fmt.Errorf("observations saved at %s are too old", snapshot.SavedAt)
+	}
+
+	return snapshot, nil
+}
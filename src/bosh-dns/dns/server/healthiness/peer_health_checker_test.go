@@ -0,0 +1,263 @@
+package healthiness_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"bosh-dns/dns/server/healthiness"
+	"bosh-dns/dns/server/healthiness/healthinessfakes"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PeerHealthChecker", func() {
+	var (
+		fakeLocal     *healthinessfakes.FakeHealthChecker
+		fakeClient    *healthinessfakes.FakeHTTPClientGetter
+		fakePeers     *healthinessfakes.FakeRecordSet
+		fakeClock     *fakeclock.FakeClock
+		checker       *healthiness.PeerHealthChecker
+		served        map[string]healthiness.HealthSnapshot
+		servedMutex   *sync.Mutex
+		observedState func(status healthiness.HealthStatus) healthiness.HealthState
+	)
+
+	BeforeEach(func() {
+		fakeLocal = &healthinessfakes.FakeHealthChecker{}
+		fakeClient = &healthinessfakes.FakeHTTPClientGetter{}
+		fakePeers = &healthinessfakes.FakeRecordSet{}
+		fakeClock = fakeclock.NewFakeClock(time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC))
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+
+		fakeLocal.GetStatusReturns(healthiness.StatusHealthy, nil)
+		fakePeers.ResolveReturns([]string{"10.0.1.1", "10.0.1.2", "10.0.1.3"}, nil)
+
+		served = map[string]healthiness.HealthSnapshot{}
+		servedMutex = &sync.Mutex{}
+		fakeClient.GetStub = func(endpoint string) (*http.Response, error) {
+			servedMutex.Lock()
+			defer servedMutex.Unlock()
+
+			snapshot, found := served[endpoint]
+			if !found {
+				return nil, errors.New("connection refused")
+			}
+
+			contents, err := json.Marshal(snapshot)
+			Expect(err).NotTo(HaveOccurred())
+
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBuffer(contents)),
+			}, nil
+		}
+
+		observedState = func(status healthiness.HealthStatus) healthiness.HealthState {
+			return healthiness.HealthState{Status: status, LastCheck: fakeClock.Now()}
+		}
+
+		checker = healthiness.NewPeerHealthChecker(fakeLocal, fakeClient, fakePeers, 8853, fakeClock, healthiness.PeerHealthCheckerConfig{
+			Peers:              []string{"q-s0.observer.default.dep.bosh."},
+			Quorum:             2,
+			MaxAge:             30 * time.Second,
+			LocalCheckInterval: time.Minute,
+			FetchInterval:      5 * time.Second,
+		}, logger)
+	})
+
+	serve := func(peer string, statuses map[string]healthiness.HealthStatus) {
+		states := map[string]healthiness.HealthState{}
+		for ip, status := range statuses {
+			states[ip] = observedState(status)
+		}
+
+		servedMutex.Lock()
+		defer servedMutex.Unlock()
+
+		served["https://"+peer+":8853/health/observations"] = healthiness.HealthSnapshot{
+			SavedAt: fakeClock.Now(),
+			States:  states,
+		}
+	}
+
+	Describe("GetStatus", func() {
+		It("checks locally without observations of peers", func() {
+			Expect(checker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+			Expect(checker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+			Expect(fakeLocal.GetStatusCallCount()).To(Equal(2))
+		})
+
+		Context("when a quorum of peers agree", func() {
+			BeforeEach(func() {
+				serve("10.0.1.1", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusHealthy})
+				serve("10.0.1.2", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusHealthy})
+				serve("10.0.1.3", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusUnhealthy})
+				checker.Fetch()
+			})
+
+			It("fetches the observations of the resolved peers", func() {
+				Expect(fakePeers.ResolveCallCount()).To(Equal(1))
+				Expect(fakePeers.ResolveArgsForCall(0)).To(Equal("q-s0.observer.default.dep.bosh."))
+				Expect(fakeClient.GetCallCount()).To(Equal(3))
+			})
+
+			It("still checks locally first", func() {
+				Expect(checker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+				Expect(fakeLocal.GetStatusCallCount()).To(Equal(1))
+			})
+
+			It("uses the observations of the peers until the local check interval has passed", func() {
+				Expect(checker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+
+				fakeClock.Increment(20 * time.Second)
+				serve("10.0.1.1", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusHealthy})
+				serve("10.0.1.2", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusHealthy})
+				checker.Fetch()
+
+				Expect(checker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+				Expect(fakeLocal.GetStatusCallCount()).To(Equal(1))
+
+				fakeClock.Increment(40 * time.Second)
+				serve("10.0.1.1", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusHealthy})
+				serve("10.0.1.2", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusHealthy})
+				checker.Fetch()
+
+				Expect(checker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+				Expect(fakeLocal.GetStatusCallCount()).To(Equal(2))
+			})
+
+			It("does not use observations older than the maximum age", func() {
+				Expect(checker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+
+				fakeClock.Increment(31 * time.Second)
+
+				Expect(checker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+				Expect(fakeLocal.GetStatusCallCount()).To(Equal(2))
+			})
+
+			It("keeps checking locally while the local check fails", func() {
+				fakeLocal.GetStatusReturns(healthiness.StatusUnhealthy, errors.New("connection refused"))
+
+				for i := 0; i < 3; i++ {
+					status, err := checker.GetStatus("10.0.0.1")
+					Expect(status).To(Equal(healthiness.StatusUnhealthy))
+					Expect(err).To(MatchError("connection refused"))
+				}
+
+				Expect(fakeLocal.GetStatusCallCount()).To(Equal(3))
+			})
+		})
+
+		It("reports unhealthy when a quorum of peers observed it", func() {
+			serve("10.0.1.1", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusUnhealthy})
+			serve("10.0.1.2", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusUnhealthy})
+			checker.Fetch()
+
+			Expect(checker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+
+			status, err := checker.GetStatus("10.0.0.1")
+			Expect(status).To(Equal(healthiness.StatusUnhealthy))
+			Expect(err).To(MatchError("2 peers observed the ip as unhealthy"))
+			Expect(fakeLocal.GetStatusCallCount()).To(Equal(1))
+		})
+
+		It("does not count its own observations", func() {
+			serve("10.0.1.1", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusUnhealthy})
+			serve("10.0.1.2", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusUnhealthy})
+			checker = healthiness.NewPeerHealthChecker(fakeLocal, fakeClient, fakePeers, 8853, fakeClock, healthiness.PeerHealthCheckerConfig{
+				Peers:              []string{"q-s0.observer.default.dep.bosh."},
+				LocalIPs:           []string{"10.0.1.2"},
+				Quorum:             2,
+				MaxAge:             30 * time.Second,
+				LocalCheckInterval: time.Minute,
+			}, boshlog.NewLogger(boshlog.LevelNone))
+			checker.Fetch()
+
+			Expect(fakeClient.GetCallCount()).To(Equal(2))
+			for i := 0; i < fakeClient.GetCallCount(); i++ {
+				Expect(fakeClient.GetArgsForCall(i)).NotTo(ContainSubstring("10.0.1.2"))
+			}
+
+			Expect(checker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+			Expect(checker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+			Expect(fakeLocal.GetStatusCallCount()).To(Equal(2))
+		})
+
+		It("checks locally when the peers disagree", func() {
+			serve("10.0.1.1", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusHealthy})
+			serve("10.0.1.2", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusHealthy})
+			serve("10.0.1.3", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusUnhealthy})
+			fakePeers.ResolveReturns([]string{"10.0.1.1", "10.0.1.3"}, nil)
+			checker.Fetch()
+
+			Expect(checker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+			Expect(checker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+			Expect(fakeLocal.GetStatusCallCount()).To(Equal(2))
+		})
+
+		It("ignores peers which serve old observations", func() {
+			serve("10.0.1.1", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusHealthy})
+			serve("10.0.1.2", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusHealthy})
+			fakeClock.Increment(time.Minute)
+			serve("10.0.1.3", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusHealthy})
+			checker.Fetch()
+
+			Expect(checker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+			Expect(checker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+			Expect(fakeLocal.GetStatusCallCount()).To(Equal(2))
+		})
+	})
+
+	Describe("Snapshot", func() {
+		It("returns only the recent local checks", func() {
+			serve("10.0.1.1", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusHealthy})
+			serve("10.0.1.2", map[string]healthiness.HealthStatus{"10.0.0.1": healthiness.StatusHealthy})
+			checker.Fetch()
+
+			fakeLocal.GetStatusReturnsOnCall(1, healthiness.StatusUnhealthy, errors.New("connection refused"))
+
+			Expect(checker.GetStatus("10.0.0.1")).To(Equal(healthiness.StatusHealthy))
+			checkedAt := fakeClock.Now()
+			fakeClock.Increment(10 * time.Second)
+			checker.GetStatus("10.0.0.1")
+			checker.GetStatus("10.0.0.2")
+
+			Expect(checker.Snapshot().States).To(Equal(map[string]healthiness.HealthState{
+				"10.0.0.1": {Status: healthiness.StatusHealthy, LastCheck: checkedAt},
+				"10.0.0.2": {Status: healthiness.StatusUnhealthy, LastCheck: fakeClock.Now(), Reason: "connection refused"},
+			}))
+
+			fakeClock.Increment(time.Minute)
+
+			Expect(checker.Snapshot().States).To(BeEmpty())
+		})
+	})
+
+	Describe("Run", func() {
+		It("fetches the observations of the peers every fetch interval", func() {
+			shutdown := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				checker.Run(shutdown)
+				close(done)
+			}()
+
+			Eventually(fakeClient.GetCallCount).Should(Equal(3))
+
+			fakeClock.WaitForWatcherAndIncrement(5 * time.Second)
+			Eventually(fakeClient.GetCallCount).Should(Equal(6))
+
+			close(shutdown)
+			Eventually(done).Should(BeClosed())
+		})
+	})
+})
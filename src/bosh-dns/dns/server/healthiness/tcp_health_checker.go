@@ -0,0 +1,30 @@
+package healthiness
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+type tcpHealthChecker struct {
+	port    int
+	timeout time.Duration
+}
+
+func NewTCPHealthChecker(port int, timeout time.Duration) HealthChecker {
+	return &tcpHealthChecker{
+		port:    port,
+		timeout: timeout,
+	}
+}
+
+func (hc *tcpHealthChecker) GetStatus(ip string) (HealthStatus, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, strconv.Itoa(hc.port)), hc.timeout)
+	if err != nil {
+		return StatusUnhealthy, err
+	}
+
+	conn.Close()
+
+	return StatusHealthy, nil
+}
@@ -0,0 +1,52 @@
+package healthiness_test
+
+import (
+	"net"
+	"time"
+
+	"bosh-dns/dns/server/healthiness"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TCPHealthChecker", func() {
+	var listener net.Listener
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				conn.Close()
+			}
+		}()
+	})
+
+	AfterEach(func() {
+		listener.Close()
+	})
+
+	It("is healthy when a connection can be established", func() {
+		port := listener.Addr().(*net.TCPAddr).Port
+		healthChecker := healthiness.NewTCPHealthChecker(port, time.Second)
+
+		Expect(healthChecker.GetStatus("127.0.0.1")).To(Equal(healthiness.StatusHealthy))
+	})
+
+	It("is unhealthy when the connection is refused", func() {
+		port := listener.Addr().(*net.TCPAddr).Port
+		listener.Close()
+		healthChecker := healthiness.NewTCPHealthChecker(port, time.Second)
+
+		status, err := healthChecker.GetStatus("127.0.0.1")
+		Expect(err).To(MatchError(ContainSubstring("connection refused")))
+		Expect(status).To(Equal(healthiness.StatusUnhealthy))
+	})
+})
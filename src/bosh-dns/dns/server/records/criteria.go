@@ -9,6 +9,20 @@ import (
 var keyValueRegex = regexp.MustCompile("(a|i|s|m|n)([0-9]+)")
 var groupRegex = regexp.MustCompile("^q-g([0-9]+)$")
 
+// Values of the `s` short query key, selecting instances by their health.
+const (
+	// HealthStrategySmart selects healthy instances, falling back to
+	// degraded ones and then to every instance. It is used when no `s` key
+	// is given.
+	HealthStrategySmart     = "0"
+	HealthStrategyUnhealthy = "1"
+	HealthStrategyAll       = "2"
+	// HealthStrategyHealthy selects healthy instances, falling back to
+	// degraded ones. Instances that are failing, draining or not checked yet
+	// are never returned.
+	HealthStrategyHealthy = "3"
+)
+
 type criteria map[string][]string
 
 type Matcher interface {
@@ -144,6 +158,10 @@ func parseCriteria(firstSegment, groupSegment, instanceGroupName, network, deplo
 	for field, values := range criteriaMap {
 		// healthiness is not handled by the normal recordset
 		if field == "s" {
+			if len(values) > 1 || !isHealthStrategy(values[0]) {
+				return nil, errors.New("illegal health strategy")
+			}
+
 			continue
 		}
 		matcher.Append(Field(field, values))
@@ -152,6 +170,34 @@ func parseCriteria(firstSegment, groupSegment, instanceGroupName, network, deplo
 	return matcher, nil
 }
 
+func parseHealthStrategy(firstSegment string) string {
+	if !strings.HasPrefix(firstSegment, "q-") {
+		return HealthStrategySmart
+	}
+
+	criteriaMap := make(criteria)
+	err := criteriaMap.parseShortQueries(strings.TrimPrefix(firstSegment, "q-"))
+	if err != nil {
+		return HealthStrategySmart
+	}
+
+	values := criteriaMap["s"]
+	if len(values) != 1 || !isHealthStrategy(values[0]) {
+		return HealthStrategySmart
+	}
+
+	return values[0]
+}
+
+func isHealthStrategy(value string) bool {
+	switch value {
+	case HealthStrategySmart, HealthStrategyUnhealthy, HealthStrategyAll, HealthStrategyHealthy:
+		return true
+	}
+
+	return false
+}
+
 func (c criteria) parseShortQueries(query string) error {
 	querySections := keyValueRegex.FindAllStringSubmatch(query, -1)
 	if querySections == nil {
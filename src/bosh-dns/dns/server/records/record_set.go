@@ -86,6 +86,11 @@ func (r *RecordSet) Resolve(fqdn string) ([]string, error) {
 	return r.resolveQuery(fqdn)
 }
 
+// HealthStrategy reports which instances, by health, the query asks for.
+func (r *RecordSet) HealthStrategy(fqdn string) string {
+	return parseHealthStrategy(strings.SplitN(fqdn, ".", 2)[0])
+}
+
 func (r *RecordSet) Domains() []string {
 	r.recordsMutex.RLock()
 	defer r.recordsMutex.RUnlock()
@@ -93,6 +98,18 @@ func (r *RecordSet) Domains() []string {
 	return r.domains
 }
 
+// AllRecords returns a copy of the records which is safe to use while the
+// records are updated.
+func (r *RecordSet) AllRecords() []Record {
+	r.recordsMutex.RLock()
+	defer r.recordsMutex.RUnlock()
+
+	records := make([]Record, len(r.Records))
+	copy(records, r.Records)
+
+	return records
+}
+
 func (r *RecordSet) update() {
 	contents, err := r.recordFileReader.Get()
 	if err != nil {
@@ -165,7 +182,7 @@ func (r *RecordSet) resolveQuery(fqdn string) ([]string, error) {
 			return ips, err
 		}
 	} else {
-		panic(fmt.Sprintf("Bad group segment query had %d values %#v\n", len(groupSegments), groupSegments))
+		return ips, fmt.Errorf("bad group segment query had %d values %#v", len(groupSegments), groupSegments)
 	}
 
 	return r.ipsMatching(filter), nil
@@ -135,6 +135,14 @@ var _ = Describe("RecordSet", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("returns a copy of all records", func() {
+			allRecords := recordSet.AllRecords()
+			Expect(allRecords).To(Equal(recordSet.Records))
+
+			allRecords[0].IP = "10.0.0.1"
+			Expect(recordSet.Records[0].IP).NotTo(Equal("10.0.0.1"))
+		})
+
 		It("normalizes domain names", func() {
 			Expect(recordSet.Domains()).To(ConsistOf("withadot.", "nodot.", "domain."))
 			Expect(recordSet.Records).To(WithTransform(dereferencer, ContainElement(records.Record{
@@ -655,6 +663,24 @@ var _ = Describe("RecordSet", func() {
 			Expect(ips).To(ContainElement("123.123.123.123"))
 		})
 
+		Context("when the query selects instances by health", func() {
+			It("does not filter on health", func() {
+				for _, query := range []string{"q-s1", "q-s2", "q-s3", "q-s1a1"} {
+					ips, err := recordSet.Resolve(query + ".my-group.my-network.my-deployment.my-domain.")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(ips).NotTo(BeEmpty())
+				}
+			})
+
+			It("errors on unknown health strategies", func() {
+				_, err := recordSet.Resolve("q-s4.my-group.my-network.my-deployment.my-domain.")
+				Expect(err).To(MatchError("illegal health strategy"))
+
+				_, err = recordSet.Resolve("q-s1s2.my-group.my-network.my-deployment.my-domain.")
+				Expect(err).To(MatchError("illegal health strategy"))
+			})
+		})
+
 		Context("when the query contains poorly formed contents", func() {
 			It("returns an empty set", func() {
 				ips, err := recordSet.Resolve("q-missingvalue.my-group.my-network.my-deployment.my-domain.")
@@ -663,6 +689,14 @@ var _ = Describe("RecordSet", func() {
 			})
 		})
 
+		Context("when the query has neither a group id nor an instance group, network and deployment", func() {
+			It("returns an error", func() {
+				ips, err := recordSet.Resolve("q-s0.my-group.my-network.my-domain.")
+				Expect(err).To(MatchError(ContainSubstring("bad group segment query had 2 values")))
+				Expect(ips).To(HaveLen(0))
+			})
+		})
+
 		Context("when the query does not include any filters", func() {
 			It("returns all records matching the my-group.my-network.my-deployment.my-domain portion of the fqdn", func() {
 				ips, err := recordSet.Resolve("q-.my-group.my-network.my-deployment.my-domain.")
@@ -927,6 +961,28 @@ var _ = Describe("RecordSet", func() {
 		})
 	})
 
+	Describe("HealthStrategy", func() {
+		BeforeEach(func() {
+			fileReader.GetReturns([]byte(`{"record_keys": [], "record_infos": []}`), nil)
+
+			var err error
+			recordSet, err = records.NewRecordSet(fileReader, fakeLogger)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		DescribeTable("parses the s key of short queries", func(fqdn, expectedStrategy string) {
+			Expect(recordSet.HealthStrategy(fqdn)).To(Equal(expectedStrategy))
+		},
+			Entry("smart", "q-s0.my-group.my-network.my-deployment.my-domain.", records.HealthStrategySmart),
+			Entry("unhealthy", "q-s1.my-group.my-network.my-deployment.my-domain.", records.HealthStrategyUnhealthy),
+			Entry("all", "q-a1s2.my-group.my-network.my-deployment.my-domain.", records.HealthStrategyAll),
+			Entry("healthy", "q-s3i2.q-g1.my-domain.", records.HealthStrategyHealthy),
+			Entry("without a s key", "q-a1.my-group.my-network.my-deployment.my-domain.", records.HealthStrategySmart),
+			Entry("with an unknown s value", "q-s9.my-group.my-network.my-deployment.my-domain.", records.HealthStrategySmart),
+			Entry("for instance names", "s1.my-group.my-network.my-deployment.my-domain.", records.HealthStrategySmart),
+		)
+	})
+
 	Context("when fqdn is already an IP address", func() {
 		It("return the IP back", func() {
 			records, err := recordSet.Resolve("123.123.123.123")
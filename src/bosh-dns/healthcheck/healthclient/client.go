@@ -3,73 +3,91 @@ package healthclient
 import (
 	"crypto/tls"
 	"errors"
-	"io/ioutil"
 	"time"
 
 	"net/http"
 
 	"crypto/x509"
 
+	"bosh-dns/healthcheck/healthtls"
+
+	"code.cloudfoundry.org/clock"
 	"github.com/cloudfoundry/bosh-utils/httpclient"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
-func NewHealthClientFromFiles(caFile, clientCertFile, clientKeyFile string, logger boshlog.Logger) (*httpclient.HTTPClient, error) {
-	// Load client cert
-	cert, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
-	if err != nil {
-		return nil, err
+type Config struct {
+	// Timeout bounds every attempt of a request.
+	Timeout     time.Duration
+	MaxAttempts uint
+	RetryDelay  time.Duration
+
+	// ServerIdentity is the DNS name the certificates of health servers are
+	// verified against.
+	ServerIdentity string
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Timeout:        5 * time.Second,
+		MaxAttempts:    4,
+		RetryDelay:     500 * time.Millisecond,
+		ServerIdentity: "health.bosh-dns",
 	}
+}
 
-	// Load CA cert
-	caCert, err := ioutil.ReadFile(caFile)
+// NewHealthClientFromFiles returns a client which picks up changes to the
+// certificate, key and CA files without being recreated.
+func NewHealthClientFromFiles(caFile, clientCertFile, clientKeyFile string, config Config, logger boshlog.Logger) (*httpclient.HTTPClient, error) {
+	credentials, err := healthtls.NewCredentialsFromFiles(caFile, clientCertFile, clientKeyFile, boshsys.NewOsFileSystem(logger), clock.NewClock(), logger)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewHealthClient(caCert, cert, logger), nil
+	return NewHealthClientWithCredentials(credentials, config, logger), nil
 }
 
-func NewHealthClient(caCert []byte, cert tls.Certificate, logger boshlog.Logger) *httpclient.HTTPClient {
-	caCertPool := x509.NewCertPool()
-	caCertPool.AppendCertsFromPEM(caCert)
+func NewHealthClient(caCert []byte, cert tls.Certificate, config Config, logger boshlog.Logger) *httpclient.HTTPClient {
+	return NewHealthClientWithCredentials(healthtls.NewCredentials(caCert, cert), config, logger)
+}
 
-	client := httpclient.NewMutualTLSClient(cert, caCertPool, "")
-	client.Timeout = 5 * time.Second
+func NewHealthClientWithCredentials(credentials *healthtls.Credentials, config Config, logger boshlog.Logger) *httpclient.HTTPClient {
+	client := httpclient.NewMutualTLSClient(*credentials.Certificate(), credentials.CAPool(), "")
+	client.Timeout = config.Timeout
 
 	if tr, ok := client.Transport.(*http.Transport); ok {
 		tr.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(10000)
 		tr.TLSClientConfig.InsecureSkipVerify = true
-		tr.TLSClientConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
-			certs := make([]*x509.Certificate, len(rawCerts))
-			for i, asn1Data := range rawCerts {
-				cert, err := x509.ParseCertificate(asn1Data)
-				if err != nil {
-					return errors.New("tls: failed to parse certificate from server: " + err.Error())
-				}
-				certs[i] = cert
+		tr.TLSClientConfig.Certificates = nil
+		tr.TLSClientConfig.NameToCertificate = nil
+		tr.TLSClientConfig.GetClientCertificate = credentials.GetClientCertificate
+		// VerifyConnection also runs for resumed sessions, which skip
+		// VerifyPeerCertificate, so that they are verified against the current
+		// CAs as well
+		tr.TLSClientConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("tls: server did not present a certificate")
 			}
 
 			opts := x509.VerifyOptions{
-				Roots:         tr.TLSClientConfig.RootCAs,
+				Roots:         credentials.CAPool(),
 				CurrentTime:   time.Now(),
-				DNSName:       "health.bosh-dns",
+				DNSName:       config.ServerIdentity,
 				Intermediates: x509.NewCertPool(),
 			}
 
-			for i, cert := range certs {
-				if i == 0 {
-					continue
-				}
+			for _, cert := range state.PeerCertificates[1:] {
 				opts.Intermediates.AddCert(cert)
 			}
-			_, err := certs[0].Verify(opts)
+
+			_, err := state.PeerCertificates[0].Verify(opts)
 			return err
 		}
 	}
 
 	return httpclient.NewHTTPClient(
-		httpclient.NewNetworkSafeRetryClient(client, 4, 500*time.Millisecond, logger),
+		httpclient.NewNetworkSafeRetryClient(client, config.MaxAttempts, config.RetryDelay, logger),
 		logger,
 	)
 }
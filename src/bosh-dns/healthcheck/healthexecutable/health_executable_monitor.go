@@ -1,80 +1,277 @@
 package healthexecutable
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"sync"
 
+	"bosh-dns/dns/config"
+
 	"code.cloudfoundry.org/clock"
 	"github.com/cloudfoundry/bosh-utils/logger"
 	"github.com/cloudfoundry/bosh-utils/system"
 )
 
+type Status string
+
+const (
+	StatusHealthy  Status = "healthy"
+	StatusDegraded Status = "degraded"
+	StatusFailing  Status = "failing"
+)
+
+// killGracePeriod is how long the process group of a timed out executable
+// gets to exit after SIGTERM before it is killed.
+const killGracePeriod = time.Second
+
+// maxOutputLength bounds the output kept of each run. The end of the output
+// is kept, as that is where the reason of a failure usually is.
+const maxOutputLength = 1024
+
+// Result is the outcome of the last run of a health executable.
+type Result struct {
+	Executable string              `json:"executable"`
+	Status     Status              `json:"status"`
+	ExitStatus int                 `json:"exit_status"`
+	Duration   config.DurationJSON `json:"duration"`
+	LastRun    time.Time           `json:"last_run"`
+	Stdout     string              `json:"stdout"`
+	Stderr     string              `json:"stderr"`
+	Error      string              `json:"error,omitempty"`
+}
+
+type Globber interface {
+	Glob(pattern string) ([]string, error)
+}
+
+// HealthExecutableMonitor runs the health executables matching a glob every
+// interval. The glob is evaluated again before every run, so that executables
+// of jobs added or removed later are picked up. Executables exiting with
+// degradedExitStatus report their job as running, but degraded; when it is 0
+// any non-zero exit status fails the job.
 type HealthExecutableMonitor struct {
+	healthExecutablesGlob string
+	globber               Globber
 	healthExecutablePaths []string
 	cmdRunner             system.CmdRunner
 	clock                 clock.Clock
 	interval              time.Duration
+	timeout               time.Duration
+	degradedExitStatus    int
 	shutdown              chan struct{}
-	status                bool
+	stopped               chan struct{}
+	status                Status
+	results               []Result
 	mutex                 *sync.Mutex
 	logger                logger.Logger
 }
 
 func NewHealthExecutableMonitor(
-	healthExecutablePaths []string,
+	healthExecutablesGlob string,
+	globber Globber,
 	cmdRunner system.CmdRunner,
 	clock clock.Clock,
 	interval time.Duration,
+	timeout time.Duration,
+	degradedExitStatus int,
 	shutdown chan struct{},
 	logger logger.Logger,
 ) *HealthExecutableMonitor {
 	monitor := &HealthExecutableMonitor{
-		healthExecutablePaths: healthExecutablePaths,
+		healthExecutablesGlob: healthExecutablesGlob,
+		globber:               globber,
+		healthExecutablePaths: []string{},
 		cmdRunner:             cmdRunner,
 		clock:                 clock,
 		interval:              interval,
+		timeout:               timeout,
+		degradedExitStatus:    degradedExitStatus,
 		shutdown:              shutdown,
-		status:                true,
+		stopped:               make(chan struct{}),
+		status:                StatusHealthy,
+		results:               []Result{},
 		mutex:                 &sync.Mutex{},
 		logger:                logger,
 	}
 
+	monitor.discover()
 	go monitor.run()
 
 	return monitor
 }
 
-func (m *HealthExecutableMonitor) Status() bool {
+func (m *HealthExecutableMonitor) Status() Status {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 	return m.status
 }
 
+// Results returns the results of the last run of each executable, in the
+// order the executables are run.
+func (m *HealthExecutableMonitor) Results() []Result {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.results
+}
+
+// Stopped is closed once the monitor has stopped after shutdown, which
+// waits for the executables currently running.
+func (m *HealthExecutableMonitor) Stopped() <-chan struct{} {
+	return m.stopped
+}
+
 func (m *HealthExecutableMonitor) run() {
-	ticker := m.clock.NewTicker(m.interval)
-	m.logger.Debug("HealthExecutableMonitor", "starting monitor for [%s] with interval %v", strings.Join(m.healthExecutablePaths, ", "), m.interval)
+	defer close(m.stopped)
+
+	// the interval starts over once every executable is done, so that a slow
+	// run never overlaps the next one
+	timer := m.clock.NewTimer(m.interval)
+	m.logger.Debug("HealthExecutableMonitor", "starting monitor for '%s' with interval %v", m.healthExecutablesGlob, m.interval)
 	for {
 		select {
 		case <-m.shutdown:
 			m.logger.Debug("HealthExecutableMonitor", "stopping")
-			ticker.Stop()
+			timer.Stop()
 			return
-		case <-ticker.C():
-			var allSucceeded = true
-			for _, executable := range m.healthExecutablePaths {
-				_, _, exitStatus, err := m.cmdRunner.RunCommand(executable)
-				if err != nil {
-					allSucceeded = false
-					m.logger.Error("HealthExecutableMonitor", "Error occurred executing '%s': %v", executable, err)
-				} else if exitStatus != 0 {
-					allSucceeded = false
+		case <-timer.C():
+			m.discover()
+			results := m.runExecutables()
+
+			var status = StatusHealthy
+			for _, result := range results {
+				if result.Status == StatusFailing {
+					status = StatusFailing
+				} else if result.Status == StatusDegraded && status == StatusHealthy {
+					status = StatusDegraded
 				}
 			}
 			m.mutex.Lock()
-			m.status = allSucceeded
+			m.status = status
+			m.results = results
 			m.mutex.Unlock()
+
+			timer.Reset(m.interval)
+		}
+	}
+}
+
+// discover replaces the executables with the current matches of the glob.
+// When the glob fails, the known executables are kept.
+func (m *HealthExecutableMonitor) discover() {
+	paths, err := m.globber.Glob(m.healthExecutablesGlob)
+	if err != nil {
+		m.logger.Error("HealthExecutableMonitor", "Error occurred finding health executables '%s': %v", m.healthExecutablesGlob, err)
+		return
+	}
+
+	sort.Strings(paths)
+
+	known := map[string]struct{}{}
+	for _, path := range m.healthExecutablePaths {
+		known[path] = struct{}{}
+	}
+
+	for _, path := range paths {
+		if _, found := known[path]; found {
+			delete(known, path)
+		} else {
+			m.logger.Info("HealthExecutableMonitor", "Found health executable '%s'", path)
 		}
 	}
+
+	for _, path := range m.healthExecutablePaths {
+		if _, removed := known[path]; removed {
+			m.logger.Info("HealthExecutableMonitor", "Health executable '%s' was removed", path)
+		}
+	}
+
+	m.healthExecutablePaths = paths
+}
+
+func (m *HealthExecutableMonitor) runExecutables() []Result {
+	results := make([]Result, len(m.healthExecutablePaths))
+	wg := &sync.WaitGroup{}
+
+	for i, executable := range m.healthExecutablePaths {
+		wg.Add(1)
+		go func(i int, executable string) {
+			defer wg.Done()
+			results[i] = m.runExecutable(executable)
+		}(i, executable)
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+func (m *HealthExecutableMonitor) runExecutable(executable string) Result {
+	started := m.clock.Now()
+	result := Result{
+		Executable: executable,
+		Status:     StatusFailing,
+		ExitStatus: -1,
+		LastRun:    started,
+	}
+
+	process, err := m.cmdRunner.RunComplexCommandAsync(system.Command{Name: executable})
+	if err != nil {
+		result.Error = err.Error()
+		m.logger.Error("HealthExecutableMonitor", "Error occurred executing '%s': %v", executable, err)
+		return result
+	}
+
+	timer := m.clock.NewTimer(m.timeout)
+	defer timer.Stop()
+
+	var processResult system.Result
+	timedOut := false
+	exited := process.Wait()
+
+	select {
+	case processResult = <-exited:
+	case <-timer.C():
+		timedOut = true
+		m.logger.Error("HealthExecutableMonitor", "Executing '%s' timed out after %s", executable, m.timeout)
+
+		err = process.TerminateNicely(killGracePeriod)
+		if err != nil {
+			m.logger.Error("HealthExecutableMonitor", "Error occurred terminating '%s': %v", executable, err)
+		} else {
+			processResult = <-exited
+		}
+	}
+
+	result.Duration = config.DurationJSON(m.clock.Since(started))
+	result.ExitStatus = processResult.ExitStatus
+	result.Stdout = trimOutput(processResult.Stdout)
+	result.Stderr = trimOutput(processResult.Stderr)
+
+	switch {
+	case timedOut:
+		result.Error = fmt.Sprintf("timed out after %s", m.timeout)
+	case m.degradedExitStatus > 0 && processResult.ExitStatus == m.degradedExitStatus:
+		result.Status = StatusDegraded
+	case processResult.ExitStatus > 0:
+		// failing; the error of the process only repeats the exit status
+	case processResult.Error != nil:
+		result.Error = processResult.Error.Error()
+		m.logger.Error("HealthExecutableMonitor", "Error occurred executing '%s': %v", executable, processResult.Error)
+	default:
+		result.Status = StatusHealthy
+	}
+
+	return result
+}
+
+func trimOutput(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) > maxOutputLength {
+		return "..." + output[len(output)-maxOutputLength:]
+	}
+
+	return output
 }
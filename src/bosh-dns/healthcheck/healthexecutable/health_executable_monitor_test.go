@@ -9,19 +9,30 @@ import (
 
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
+
+	"bosh-dns/dns/config"
 
 	"code.cloudfoundry.org/clock/fakeclock"
 	loggerfakes "github.com/cloudfoundry/bosh-utils/logger/fakes"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 	sysfakes "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
 var _ = Describe("HealthExecutableMonitor", func() {
+	const executablesGlob = "/var/vcap/jobs/*/bin/dns/healthy"
+
 	var (
 		monitor         *healthexecutable.HealthExecutableMonitor
 		logger          *loggerfakes.FakeLogger
 		cmdRunner       *sysfakes.FakeCmdRunner
+		waitedRunner    waitSignalingCmdRunner
+		fs              *sysfakes.FakeFileSystem
 		clock           *fakeclock.FakeClock
 		interval        time.Duration
+		timeout         time.Duration
+		degraded        int
 		executablePaths []string
 		signal          chan struct{}
 	)
@@ -30,17 +41,32 @@ var _ = Describe("HealthExecutableMonitor", func() {
 		logger = &loggerfakes.FakeLogger{}
 		clock = fakeclock.NewFakeClock(time.Now())
 		cmdRunner = sysfakes.NewFakeCmdRunner()
+		waitedRunner = waitSignalingCmdRunner{
+			FakeCmdRunner: cmdRunner,
+			waited:        map[boshsys.Process]chan struct{}{},
+			mutex:         &sync.Mutex{},
+		}
+		fs = sysfakes.NewFakeFileSystem()
 		interval = time.Millisecond
+		timeout = time.Minute
+		degraded = 3
 		executablePaths = []string{"e1", "e2", "e3"}
 		signal = make(chan struct{})
 	})
 
 	JustBeforeEach(func() {
+		if len(executablePaths) > 0 {
+			fs.SetGlob(executablesGlob, executablePaths)
+		}
+
 		monitor = healthexecutable.NewHealthExecutableMonitor(
-			executablePaths,
-			cmdRunner,
+			executablesGlob,
+			fs,
+			waitedRunner,
 			clock,
 			interval,
+			timeout,
+			degraded,
 			signal,
 			logger,
 		)
@@ -52,45 +78,145 @@ var _ = Describe("HealthExecutableMonitor", func() {
 		}
 	})
 
+	addResult := func(executable string, result boshsys.Result) {
+		cmdRunner.AddProcess(executable, &sysfakes.FakeProcess{WaitResult: result})
+	}
+
+	// addHangingProcess adds a process which only exits once terminated. The
+	// returned channel is closed once the monitor waits for the process.
+	addHangingProcess := func(executable string) (*sysfakes.FakeProcess, chan struct{}) {
+		process := &sysfakes.FakeProcess{
+			TerminatedNicelyCallBack: func(p *sysfakes.FakeProcess) {
+				p.WaitCh <- boshsys.Result{ExitStatus: 143, Stderr: "terminated"}
+			},
+		}
+		cmdRunner.AddProcess(executable, process)
+
+		return process, waitedRunner.signalWait(process)
+	}
+
+	lastRun := func() time.Time {
+		results := monitor.Results()
+		if len(results) == 0 {
+			return time.Time{}
+		}
+
+		return results[0].LastRun
+	}
+
+	// tick starts the next run and waits until it is done
+	tick := func() {
+		clock.WaitForWatcherAndIncrement(interval)
+		Eventually(lastRun).Should(Equal(clock.Now()))
+	}
+
 	Context("when some executables go unhealthy and they become healthy again", func() {
 		BeforeEach(func() {
-			cmdRunner.AddCmdResult(executablePaths[0], sysfakes.FakeCmdResult{ExitStatus: 0})
-			cmdRunner.AddCmdResult(executablePaths[1], sysfakes.FakeCmdResult{ExitStatus: 0})
-			cmdRunner.AddCmdResult(executablePaths[2], sysfakes.FakeCmdResult{ExitStatus: 0})
+			addResult(executablePaths[0], boshsys.Result{ExitStatus: 0})
+			addResult(executablePaths[1], boshsys.Result{ExitStatus: 0})
+			addResult(executablePaths[2], boshsys.Result{ExitStatus: 0})
 
-			cmdRunner.AddCmdResult(executablePaths[0], sysfakes.FakeCmdResult{ExitStatus: 0})
-			cmdRunner.AddCmdResult(executablePaths[1], sysfakes.FakeCmdResult{ExitStatus: 1})
-			cmdRunner.AddCmdResult(executablePaths[2], sysfakes.FakeCmdResult{ExitStatus: 0})
+			addResult(executablePaths[0], boshsys.Result{ExitStatus: 0})
+			addResult(executablePaths[1], boshsys.Result{ExitStatus: 1})
+			addResult(executablePaths[2], boshsys.Result{ExitStatus: 0})
 
-			cmdRunner.AddCmdResult(executablePaths[0], sysfakes.FakeCmdResult{ExitStatus: 0})
-			cmdRunner.AddCmdResult(executablePaths[1], sysfakes.FakeCmdResult{ExitStatus: 0})
-			cmdRunner.AddCmdResult(executablePaths[2], sysfakes.FakeCmdResult{ExitStatus: 0})
+			addResult(executablePaths[0], boshsys.Result{ExitStatus: 0})
+			addResult(executablePaths[1], boshsys.Result{ExitStatus: 0})
+			addResult(executablePaths[2], boshsys.Result{ExitStatus: 0})
 		})
 
-		It("starts with status true", func() {
-			Expect(monitor.Status()).To(BeTrue())
+		It("starts with status healthy", func() {
+			Expect(monitor.Status()).To(Equal(healthexecutable.StatusHealthy))
 		})
 
 		It("returns status accordingly", func() {
-			clock.WaitForWatcherAndIncrement(interval)
-			Eventually(monitor.Status).Should(BeTrue())
-			clock.WaitForWatcherAndIncrement(interval)
-			Eventually(monitor.Status).Should(BeFalse())
-			clock.WaitForWatcherAndIncrement(interval)
-			Eventually(monitor.Status).Should(BeTrue())
+			tick()
+			Expect(monitor.Status()).To(Equal(healthexecutable.StatusHealthy))
+			tick()
+			Expect(monitor.Status()).To(Equal(healthexecutable.StatusFailing))
+			tick()
+			Expect(monitor.Status()).To(Equal(healthexecutable.StatusHealthy))
+		})
+	})
+
+	Context("when some executables report degraded", func() {
+		BeforeEach(func() {
+			addResult(executablePaths[0], boshsys.Result{ExitStatus: 0})
+			addResult(executablePaths[1], boshsys.Result{ExitStatus: 3, Error: errors.New("exit status 3")})
+			addResult(executablePaths[2], boshsys.Result{ExitStatus: 0})
+
+			addResult(executablePaths[0], boshsys.Result{ExitStatus: 1, Error: errors.New("exit status 1")})
+			addResult(executablePaths[1], boshsys.Result{ExitStatus: 3, Error: errors.New("exit status 3")})
+			addResult(executablePaths[2], boshsys.Result{ExitStatus: 0})
+		})
+
+		It("is degraded unless another executable fails", func() {
+			tick()
+			Expect(monitor.Status()).To(Equal(healthexecutable.StatusDegraded))
+			tick()
+			Expect(monitor.Status()).To(Equal(healthexecutable.StatusFailing))
+		})
+
+		Context("when no exit status reports degraded", func() {
+			BeforeEach(func() {
+				degraded = 0
+			})
+
+			It("is failing", func() {
+				tick()
+				Expect(monitor.Status()).To(Equal(healthexecutable.StatusFailing))
+			})
+		})
+	})
+
+	Context("when recording the results of the executables", func() {
+		BeforeEach(func() {
+			addResult(executablePaths[0], boshsys.Result{ExitStatus: 0, Stdout: "ok\n"})
+			addResult(executablePaths[1], boshsys.Result{ExitStatus: 1, Stderr: "  " + strings.Repeat("x", 2000) + "connection refused\n"})
+			addResult(executablePaths[2], boshsys.Result{ExitStatus: 0, Error: errors.New("can't do that")})
+		})
+
+		It("has no results before the first run", func() {
+			Expect(monitor.Results()).To(BeEmpty())
+		})
+
+		It("returns the trimmed output, exit status and error of each executable", func() {
+			tick()
+
+			results := monitor.Results()
+			Expect(results).To(HaveLen(3))
+			Expect(results[0]).To(Equal(healthexecutable.Result{
+				Executable: "e1",
+				Status:     healthexecutable.StatusHealthy,
+				ExitStatus: 0,
+				Duration:   config.DurationJSON(0),
+				LastRun:    clock.Now(),
+				Stdout:     "ok",
+			}))
+
+			Expect(results[1].Executable).To(Equal("e2"))
+			Expect(results[1].Status).To(Equal(healthexecutable.StatusFailing))
+			Expect(results[1].ExitStatus).To(Equal(1))
+			Expect(results[1].Stderr).To(HaveLen(1024 + len("...")))
+			Expect(results[1].Stderr).To(HavePrefix("...xxx"))
+			Expect(results[1].Stderr).To(HaveSuffix("connection refused"))
+
+			Expect(results[2].Executable).To(Equal("e3"))
+			Expect(results[2].Status).To(Equal(healthexecutable.StatusFailing))
+			Expect(results[2].Error).To(Equal("can't do that"))
 		})
 	})
 
 	Context("when executing an executable returns an error", func() {
 		BeforeEach(func() {
-			cmdRunner.AddCmdResult(executablePaths[0], sysfakes.FakeCmdResult{ExitStatus: 0})
-			cmdRunner.AddCmdResult(executablePaths[1], sysfakes.FakeCmdResult{ExitStatus: 0, Error: errors.New("can't do that")})
-			cmdRunner.AddCmdResult(executablePaths[2], sysfakes.FakeCmdResult{ExitStatus: 0})
+			addResult(executablePaths[0], boshsys.Result{ExitStatus: 0})
+			addResult(executablePaths[1], boshsys.Result{ExitStatus: 0, Error: errors.New("can't do that")})
+			addResult(executablePaths[2], boshsys.Result{ExitStatus: 0})
 		})
 
 		It("logs an error", func() {
-			clock.WaitForWatcherAndIncrement(interval)
-			Eventually(monitor.Status).Should(BeFalse())
+			tick()
+			Expect(monitor.Status()).To(Equal(healthexecutable.StatusFailing))
 
 			Expect(logger.ErrorCallCount()).To(Equal(1))
 			logTag, template, interpols := logger.ErrorArgsForCall(0)
@@ -99,14 +225,160 @@ var _ = Describe("HealthExecutableMonitor", func() {
 		})
 	})
 
+	Context("when an executable cannot be started", func() {
+		BeforeEach(func() {
+			addResult(executablePaths[0], boshsys.Result{ExitStatus: 0})
+			cmdRunner.AddProcess(executablePaths[1], &sysfakes.FakeProcess{StartErr: errors.New("permission denied")})
+			addResult(executablePaths[2], boshsys.Result{ExitStatus: 0})
+		})
+
+		It("fails", func() {
+			tick()
+			Expect(monitor.Status()).To(Equal(healthexecutable.StatusFailing))
+			Expect(monitor.Results()[1].Error).To(Equal("permission denied"))
+		})
+	})
+
+	Context("when an executable runs into its timeout", func() {
+		var (
+			hanging *sysfakes.FakeProcess
+			waited  chan struct{}
+		)
+
+		BeforeEach(func() {
+			addResult(executablePaths[0], boshsys.Result{ExitStatus: 0})
+			hanging, waited = addHangingProcess(executablePaths[1])
+			addResult(executablePaths[2], boshsys.Result{ExitStatus: 0})
+
+			addResult(executablePaths[0], boshsys.Result{ExitStatus: 0})
+			addResult(executablePaths[1], boshsys.Result{ExitStatus: 0})
+			addResult(executablePaths[2], boshsys.Result{ExitStatus: 0})
+		})
+
+		It("terminates its process group and fails", func() {
+			clock.WaitForWatcherAndIncrement(interval)
+			runAt := clock.Now()
+			Eventually(waited).Should(BeClosed())
+
+			clock.WaitForWatcherAndIncrement(timeout)
+			Eventually(monitor.Status).Should(Equal(healthexecutable.StatusFailing))
+
+			Expect(hanging.TerminatedNicely).To(BeTrue())
+			Expect(hanging.TerminateNicelyKillGracePeriod).To(Equal(time.Second))
+
+			results := monitor.Results()
+			Expect(results[1].Error).To(Equal("timed out after 1m0s"))
+			Expect(results[1].ExitStatus).To(Equal(143))
+			Expect(results[1].Stderr).To(Equal("terminated"))
+			Expect(results[1].LastRun).To(Equal(runAt))
+			Expect(results[1].Duration).To(Equal(config.DurationJSON(timeout)))
+			Expect(results[0].Status).To(Equal(healthexecutable.StatusHealthy))
+			Expect(results[2].Status).To(Equal(healthexecutable.StatusHealthy))
+		})
+
+		It("does not start the next run before the slow one has finished", func() {
+			clock.WaitForWatcherAndIncrement(interval)
+			Eventually(waited).Should(BeClosed())
+
+			clock.Increment(interval)
+			Consistently(monitor.Results).Should(BeEmpty())
+
+			clock.WaitForWatcherAndIncrement(timeout)
+			Eventually(monitor.Status).Should(Equal(healthexecutable.StatusFailing))
+
+			tick()
+			Expect(monitor.Status()).To(Equal(healthexecutable.StatusHealthy))
+		})
+	})
+
+	Context("when several executables are slow", func() {
+		BeforeEach(func() {
+			addHangingProcess(executablePaths[0])
+			addHangingProcess(executablePaths[1])
+			addResult(executablePaths[2], boshsys.Result{ExitStatus: 0})
+		})
+
+		It("runs them concurrently", func() {
+			clock.WaitForWatcherAndIncrement(interval)
+			Eventually(clock.WatcherCount).Should(Equal(2))
+
+			clock.Increment(timeout)
+			Eventually(monitor.Status).Should(Equal(healthexecutable.StatusFailing))
+
+			results := monitor.Results()
+			Expect(results[0].Duration).To(Equal(config.DurationJSON(timeout)))
+			Expect(results[1].Duration).To(Equal(config.DurationJSON(timeout)))
+			Expect(results[2].Status).To(Equal(healthexecutable.StatusHealthy))
+		})
+	})
+
+	Context("when executables are added and removed", func() {
+		BeforeEach(func() {
+			fs.SetGlob(executablesGlob, []string{"e2", "e1"}, []string{"e2", "e1"}, []string{"e3", "e1"})
+			executablePaths = []string{}
+
+			addResult("e1", boshsys.Result{ExitStatus: 0})
+			addResult("e2", boshsys.Result{ExitStatus: 0})
+			addResult("e1", boshsys.Result{ExitStatus: 0})
+			addResult("e2", boshsys.Result{ExitStatus: 0})
+			addResult("e3", boshsys.Result{ExitStatus: 1})
+		})
+
+		infoMessages := func() []string {
+			messages := []string{}
+			for i := 0; i < logger.InfoCallCount(); i++ {
+				_, template, interpols := logger.InfoArgsForCall(i)
+				messages = append(messages, fmt.Sprintf(template, interpols...))
+			}
+
+			return messages
+		}
+
+		It("runs the executables matching the glob at each tick", func() {
+			Expect(infoMessages()).To(Equal([]string{
+				"Found health executable 'e1'",
+				"Found health executable 'e2'",
+			}))
+
+			tick()
+			Expect(monitor.Status()).To(Equal(healthexecutable.StatusHealthy))
+			Expect(monitor.Results()).To(HaveLen(2))
+			Expect(monitor.Results()[1].Executable).To(Equal("e2"))
+
+			tick()
+			Expect(monitor.Status()).To(Equal(healthexecutable.StatusFailing))
+			Expect(monitor.Results()).To(HaveLen(2))
+			Expect(monitor.Results()[1].Executable).To(Equal("e3"))
+
+			Expect(infoMessages()).To(Equal([]string{
+				"Found health executable 'e1'",
+				"Found health executable 'e2'",
+				"Found health executable 'e3'",
+				"Health executable 'e2' was removed",
+			}))
+		})
+
+		It("keeps the known executables when the glob fails", func() {
+			tick()
+
+			fs.GlobErr = errors.New("fake-glob-err")
+			tick()
+			Expect(monitor.Results()[1].Executable).To(Equal("e2"))
+
+			Expect(logger.ErrorCallCount()).To(Equal(1))
+			_, template, interpols := logger.ErrorArgsForCall(0)
+			Expect(fmt.Sprintf(template, interpols...)).To(Equal("Error occurred finding health executables '/var/vcap/jobs/*/bin/dns/healthy': fake-glob-err"))
+		})
+	})
+
 	Context("when no executables are defined", func() {
 		BeforeEach(func() {
 			executablePaths = []string{}
 		})
 
-		It("always returns status true", func() {
+		It("always returns status healthy", func() {
 			clock.WaitForWatcherAndIncrement(interval)
-			Consistently(monitor.Status).Should(BeTrue())
+			Consistently(monitor.Status).Should(Equal(healthexecutable.StatusHealthy))
 		})
 	})
 
@@ -118,9 +390,61 @@ var _ = Describe("HealthExecutableMonitor", func() {
 			signal = nil
 
 			Eventually(clock.WatcherCount).Should(Equal(0))
-			cmdRunner.AddCmdResult(executablePaths[1], sysfakes.FakeCmdResult{ExitStatus: 1})
 			clock.Increment(interval * 2)
-			Consistently(monitor.Status).Should(Equal(true))
+			Consistently(func() []boshsys.Command { return cmdRunner.RunComplexCommands }).Should(BeEmpty())
+		})
+
+		It("reports that it stopped", func() {
+			Consistently(monitor.Stopped()).ShouldNot(BeClosed())
+
+			close(signal)
+			signal = nil
+
+			Eventually(monitor.Stopped()).Should(BeClosed())
 		})
 	})
 })
+
+// waitSignalingCmdRunner closes a channel once the monitor waits for a
+// process, as the Waited flag of fake processes is written unguarded by the
+// goroutines of the monitor.
+type waitSignalingCmdRunner struct {
+	*sysfakes.FakeCmdRunner
+	waited map[boshsys.Process]chan struct{}
+	mutex  *sync.Mutex
+}
+
+func (r waitSignalingCmdRunner) signalWait(process *sysfakes.FakeProcess) chan struct{} {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	waited := make(chan struct{})
+	r.waited[process] = waited
+
+	return waited
+}
+
+func (r waitSignalingCmdRunner) RunComplexCommandAsync(cmd boshsys.Command) (boshsys.Process, error) {
+	process, err := r.FakeCmdRunner.RunComplexCommandAsync(cmd)
+
+	r.mutex.Lock()
+	waited, found := r.waited[process]
+	delete(r.waited, process)
+	r.mutex.Unlock()
+
+	if !found {
+		return process, err
+	}
+
+	return waitSignalingProcess{Process: process, waited: waited}, err
+}
+
+type waitSignalingProcess struct {
+	boshsys.Process
+	waited chan struct{}
+}
+
+func (p waitSignalingProcess) Wait() <-chan boshsys.Result {
+	defer close(p.waited)
+	return p.Process.Wait()
+}
@@ -0,0 +1,136 @@
+package healthserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+// Drainer tracks whether the instance is being drained. A draining instance
+// reports the state "draining", so that peers only answer with it when no
+// other instance is available.
+type Drainer struct {
+	clock    clock.Clock
+	draining bool
+	until    time.Time
+	mutex    *sync.Mutex
+}
+
+type DrainState struct {
+	Draining bool       `json:"draining"`
+	Until    *time.Time `json:"until,omitempty"`
+}
+
+func NewDrainer(clock clock.Clock) *Drainer {
+	return &Drainer{clock: clock, mutex: &sync.Mutex{}}
+}
+
+// Start drains the instance until it is stopped or, with a positive
+// duration, until the duration has passed.
+func (d *Drainer) Start(duration time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.draining = true
+	d.until = time.Time{}
+	if duration > 0 {
+		d.until = d.clock.Now().Add(duration)
+	}
+}
+
+func (d *Drainer) Stop() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.draining = false
+	d.until = time.Time{}
+}
+
+func (d *Drainer) State() DrainState {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if !d.draining || (!d.until.IsZero() && !d.clock.Now().Before(d.until)) {
+		return DrainState{}
+	}
+
+	state := DrainState{Draining: true}
+	if !d.until.IsZero() {
+		until := d.until
+		state.Until = &until
+	}
+
+	return state
+}
+
+// drainEntryPoint is served on the local admin address only. POST starts
+// draining, optionally for the duration given as the duration parameter,
+// DELETE stops it and GET returns whether the instance is draining.
+func (c *concreteHealthServer) drainEntryPoint(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var duration time.Duration
+		if param := r.URL.Query().Get("duration"); param != "" {
+			var err error
+			duration, err = time.ParseDuration(param)
+			if err != nil || duration <= 0 {
+				w.Header().Add("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("duration must be a positive duration, e.g. 10m"))
+				return
+			}
+		}
+
+		c.drainer.Start(duration)
+		c.logger.Info(logTag, "Draining instance for %s", durationDescription(duration))
+	case http.MethodDelete:
+		c.drainer.Stop()
+		c.logger.Info(logTag, "Stopped draining instance")
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	stateRaw, err := json.Marshal(c.drainer.State())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError) // untested
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(stateRaw)
+}
+
+func durationDescription(duration time.Duration) string {
+	if duration == 0 {
+		return "an unlimited time"
+	}
+
+	return duration.String()
+}
+
+// drainingHealth replaces the state of a running or degraded agent, as
+// draining is the more relevant state to the peers.
+func drainingHealth(healthRaw []byte, state DrainState) []byte {
+	var health map[string]interface{}
+	err := json.Unmarshal(healthRaw, &health)
+	if err != nil || (health["state"] != "running" && health["state"] != "degraded") {
+		return healthRaw
+	}
+
+	health["state"] = "draining"
+	if state.Until != nil {
+		health["draining_until"] = state.Until
+	}
+
+	drainingRaw, err := json.Marshal(health)
+	if err != nil {
+		return healthRaw // untested
+	}
+
+	return drainingRaw
+}
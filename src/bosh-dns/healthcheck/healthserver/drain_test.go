@@ -0,0 +1,58 @@
+package healthserver_test
+
+import (
+	"time"
+
+	"bosh-dns/healthcheck/healthserver"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Drainer", func() {
+	var (
+		fakeClock *fakeclock.FakeClock
+		drainer   *healthserver.Drainer
+	)
+
+	BeforeEach(func() {
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		drainer = healthserver.NewDrainer(fakeClock)
+	})
+
+	It("is not draining initially", func() {
+		Expect(drainer.State()).To(Equal(healthserver.DrainState{}))
+	})
+
+	It("drains until stopped", func() {
+		drainer.Start(0)
+		fakeClock.Increment(24 * time.Hour)
+
+		Expect(drainer.State()).To(Equal(healthserver.DrainState{Draining: true}))
+
+		drainer.Stop()
+
+		Expect(drainer.State()).To(Equal(healthserver.DrainState{}))
+	})
+
+	It("drains for the given duration", func() {
+		until := fakeClock.Now().Add(10 * time.Minute)
+		drainer.Start(10 * time.Minute)
+
+		fakeClock.Increment(10*time.Minute - time.Second)
+		Expect(drainer.State()).To(Equal(healthserver.DrainState{Draining: true, Until: &until}))
+
+		fakeClock.Increment(time.Second)
+		Expect(drainer.State()).To(Equal(healthserver.DrainState{}))
+	})
+
+	It("drains for the duration of the last start", func() {
+		drainer.Start(time.Minute)
+		drainer.Start(0)
+		fakeClock.Increment(time.Hour)
+
+		Expect(drainer.State().Draining).To(BeTrue())
+	})
+})
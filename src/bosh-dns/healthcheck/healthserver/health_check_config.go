@@ -5,12 +5,17 @@ import "bosh-dns/dns/config"
 type HealthCheckConfig struct {
 	Address                  string              `json:"address"`
 	Port                     int                 `json:"port"`
+	AdminPort                int                 `json:"admin_port"`
 	CertificateFile          string              `json:"certificate_file"`
 	PrivateKeyFile           string              `json:"private_key_file"`
 	CAFile                   string              `json:"ca_file"`
 	HealthFileName           string              `json:"health_file_name"`
 	HealthExecutablesGlob    string              `json:"health_executables_glob"`
 	HealthExecutableInterval config.DurationJSON `json:"health_executable_interval"`
+	HealthExecutableTimeout  config.DurationJSON `json:"health_executable_timeout"`
+	DegradedExitStatus       int                 `json:"health_executable_degraded_exit_status"`
+	ObservationsFileName     string              `json:"observations_file_name"`
+	AllowedClientIdentities  []string            `json:"allowed_client_identities"`
 }
 
 const CN = "health.bosh-dns"
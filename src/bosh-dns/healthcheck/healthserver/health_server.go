@@ -1,25 +1,32 @@
 package healthserver
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"net/http"
+	"time"
 
 	"crypto/tls"
-	"crypto/x509"
+	"encoding/json"
 	"io/ioutil"
 
+	"bosh-dns/healthcheck/healthexecutable"
+	"bosh-dns/healthcheck/healthtls"
+
+	"code.cloudfoundry.org/clock"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	"github.com/cloudfoundry/bosh-utils/system"
 	"github.com/pivotal-cf/paraphernalia/secure/tlsconfig"
 )
 
 type HealthServer interface {
-	Serve(config *HealthCheckConfig)
+	Serve(config *HealthCheckConfig, shutdown chan struct{}) error
 }
 
 type HealthExecutable interface {
-	Status() bool
+	Status() healthexecutable.Status
+	Results() []healthexecutable.Result
 }
 
 type concreteHealthServer struct {
@@ -27,61 +34,132 @@ type concreteHealthServer struct {
 	fs                 system.FileSystem
 	healthJsonFileName string
 	healthExecutable   HealthExecutable
+	observationsFile   string
+	allowedIdentities  map[string]struct{}
+	clock              clock.Clock
+	drainer            *Drainer
 }
 
 const logTag = "healthServer"
 
-func NewHealthServer(logger boshlog.Logger, fs system.FileSystem, healthFileName string, healthExecutable HealthExecutable) HealthServer {
+// drainTimeout bounds how long requests in flight may take to complete once
+// the server is shut down.
+const drainTimeout = 5 * time.Second
+
+func NewHealthServer(logger boshlog.Logger, fs system.FileSystem, healthFileName string, healthExecutable HealthExecutable, observationsFile string, clock clock.Clock) HealthServer {
 	return &concreteHealthServer{
 		logger:             logger,
 		fs:                 fs,
 		healthJsonFileName: healthFileName,
 		healthExecutable:   healthExecutable,
+		observationsFile:   observationsFile,
+		clock:              clock,
+		drainer:            NewDrainer(clock),
 	}
 }
 
-func (c *concreteHealthServer) Serve(config *HealthCheckConfig) {
-	http.HandleFunc("/health", c.healthEntryPoint)
+// Serve blocks until the server fails or, after shutdown is closed, until
+// the requests in flight have completed.
+func (c *concreteHealthServer) Serve(config *HealthCheckConfig, shutdown chan struct{}) error {
+	allowedIdentities := config.AllowedClientIdentities
+	if len(allowedIdentities) == 0 {
+		allowedIdentities = []string{CN}
+	}
 
-	caCert, err := ioutil.ReadFile(config.CAFile)
-	if err != nil {
-		log.Fatal(err)
-		return
+	c.allowedIdentities = map[string]struct{}{}
+	for _, identity := range allowedIdentities {
+		c.allowedIdentities[identity] = struct{}{}
 	}
-	caCertPool := x509.NewCertPool()
-	caCertPool.AppendCertsFromPEM(caCert)
 
-	cert, err := tls.LoadX509KeyPair(config.CertificateFile, config.PrivateKeyFile)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", c.healthEntryPoint)
+	mux.HandleFunc("/health/observations", c.observationsEntryPoint)
+	mux.HandleFunc("/health/details", c.detailsEntryPoint)
+
+	credentials, err := healthtls.NewCredentialsFromFiles(config.CAFile, config.CertificateFile, config.PrivateKeyFile, c.fs, c.clock, c.logger)
 	if err != nil {
-		log.Fatal(err)
-		return
+		return bosherr.WrapError(err, "Loading health server credentials")
 	}
 
 	tlsConfig := tlsconfig.Build(
-		tlsconfig.WithIdentity(cert),
 		tlsconfig.WithInternalServiceDefaults(),
 	)
 
-	serverConfig := tlsConfig.Server(tlsconfig.WithClientAuthentication(caCertPool))
-	serverConfig.BuildNameToCertificate()
+	serverConfig := tlsConfig.Server(tlsconfig.WithClientAuthentication(credentials.CAPool()))
+	serverConfig.GetCertificate = credentials.GetCertificate
+	serverConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		clientConfig := serverConfig.Clone()
+		clientConfig.ClientCAs = credentials.CAPool()
+		return clientConfig, nil
+	}
 
 	server := &http.Server{
 		Addr:      fmt.Sprintf("%s:%d", config.Address, config.Port),
+		Handler:   mux,
 		TLSConfig: serverConfig,
 	}
 	server.SetKeepAlivesEnabled(false)
 
-	serveErr := server.ListenAndServeTLS("", "")
-	c.logger.Error(logTag, "http healthcheck ending with %s", serveErr)
+	servers := []*http.Server{server}
+	serveErrs := make(chan error, 2)
+
+	go func() {
+		serveErrs <- server.ListenAndServeTLS("", "")
+	}()
+
+	if config.AdminPort != 0 {
+		adminMux := http.NewServeMux()
+		adminMux.HandleFunc("/drain", c.drainEntryPoint)
+
+		// the admin server is only reachable locally, as its requests are
+		// not authenticated
+		adminServer := &http.Server{
+			Addr:    fmt.Sprintf("127.0.0.1:%d", config.AdminPort),
+			Handler: adminMux,
+		}
+		servers = append(servers, adminServer)
+
+		go func() {
+			serveErrs <- adminServer.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err = <-serveErrs:
+		shutdownServers(servers, 0)
+		return bosherr.WrapError(err, "Serving health")
+	case <-shutdown:
+	}
+
+	c.logger.Info(logTag, "Draining requests")
+
+	err = shutdownServers(servers, drainTimeout)
+	if err != nil {
+		return bosherr.WrapError(err, "Draining health requests")
+	}
+
+	return nil
+}
+
+// shutdownServers waits up to timeout for the requests in flight to
+// complete.
+func shutdownServers(servers []*http.Server, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var shutdownErr error
+	for _, server := range servers {
+		err := server.Shutdown(ctx)
+		if err != nil && shutdownErr == nil {
+			shutdownErr = err
+		}
+	}
+
+	return shutdownErr
 }
 
 func (c *concreteHealthServer) healthEntryPoint(w http.ResponseWriter, r *http.Request) {
-	// Should not be possible to get here without having a peer certificate
-	cn := r.TLS.PeerCertificates[0].Subject.CommonName
-	if cn != CN {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Header().Add("Content-Type", "text/plain")
-		w.Write([]byte("TLS certificate common name does not match"))
+	if !c.verifyIdentity(w, r) {
 		return
 	}
 
@@ -95,9 +173,121 @@ func (c *concreteHealthServer) healthEntryPoint(w http.ResponseWriter, r *http.R
 
 	w.Header().Add("Content-Type", "application/json")
 
-	if c.healthExecutable.Status() {
-		w.Write(healthRaw)
-	} else {
-		w.Write([]byte(`{"state":"job-health-executable-fail"}`))
+	switch c.healthExecutable.Status() {
+	case healthexecutable.StatusFailing:
+		failingRaw, _ := json.Marshal(map[string]interface{}{
+			"state":               "job-health-executable-fail",
+			"failing_executables": executablesWithStatus(c.healthExecutable.Results(), healthexecutable.StatusFailing),
+		})
+		w.Write(failingRaw)
+		return
+	case healthexecutable.StatusDegraded:
+		healthRaw = degradedHealth(healthRaw, executablesWithStatus(c.healthExecutable.Results(), healthexecutable.StatusDegraded))
+	}
+
+	if drain := c.drainer.State(); drain.Draining {
+		healthRaw = drainingHealth(healthRaw, drain)
+	}
+
+	w.Write(healthRaw)
+}
+
+// detailsEntryPoint serves the result of the last run of each health
+// executable, so that it can be seen which job reports itself unhealthy.
+func (c *concreteHealthServer) detailsEntryPoint(w http.ResponseWriter, r *http.Request) {
+	if !c.verifyIdentity(w, r) {
+		return
+	}
+
+	detailsRaw, err := json.Marshal(map[string]interface{}{
+		"status":      c.healthExecutable.Status(),
+		"executables": c.healthExecutable.Results(),
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError) // untested
+		return
 	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(detailsRaw)
+}
+
+func executablesWithStatus(results []healthexecutable.Result, status healthexecutable.Status) []string {
+	executables := []string{}
+	for _, result := range results {
+		if result.Status == status {
+			executables = append(executables, result.Executable)
+		}
+	}
+
+	return executables
+}
+
+// observationsEntryPoint serves the health of the peers observed by the
+// local bosh-dns, which it writes to the observations file.
+func (c *concreteHealthServer) observationsEntryPoint(w http.ResponseWriter, r *http.Request) {
+	if !c.verifyIdentity(w, r) {
+		return
+	}
+
+	if c.observationsFile == "" || !c.fs.FileExists(c.observationsFile) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	observationsRaw, err := c.fs.ReadFile(c.observationsFile)
+	if err != nil {
+		c.logger.Error(logTag, "Failed to read health observations. error: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(observationsRaw)
+}
+
+// verifyIdentity only allows clients whose certificate has an allowed
+// identity as its common name or as one of its DNS or URI SANs.
+func (c *concreteHealthServer) verifyIdentity(w http.ResponseWriter, r *http.Request) bool {
+	// Should not be possible to get here without having a peer certificate
+	cert := r.TLS.PeerCertificates[0]
+
+	identities := []string{cert.Subject.CommonName}
+	identities = append(identities, cert.DNSNames...)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+
+	for _, identity := range identities {
+		if _, found := c.allowedIdentities[identity]; found {
+			return true
+		}
+	}
+
+	c.logger.Debug(logTag, "Rejecting client certificate with identities %v", identities)
+
+	w.Header().Add("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusForbidden)
+	w.Write([]byte("TLS certificate identity is not allowed: none of the common name or SANs match an allowed client identity"))
+	return false
+}
+
+// degradedHealth only downgrades a running agent. When the agent itself
+// reports another state, that state is more relevant to the peers.
+func degradedHealth(healthRaw []byte, degradedExecutables []string) []byte {
+	var health map[string]interface{}
+	err := json.Unmarshal(healthRaw, &health)
+	if err != nil || health["state"] != "running" {
+		return healthRaw
+	}
+
+	health["state"] = "degraded"
+	health["degraded_executables"] = degradedExecutables
+
+	degradedRaw, err := json.Marshal(health)
+	if err != nil {
+		return healthRaw // untested
+	}
+
+	return degradedRaw
 }
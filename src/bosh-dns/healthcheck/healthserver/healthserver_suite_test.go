@@ -0,0 +1,13 @@
+package healthserver_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestHealthServer(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "healthserver")
+}
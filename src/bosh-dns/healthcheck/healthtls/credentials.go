@@ -0,0 +1,176 @@
+package healthtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	logTag = "HealthCredentials"
+
+	// ReloadInterval bounds how often the credential files are read, since
+	// every health request performs a handshake.
+	ReloadInterval = 5 * time.Second
+)
+
+// Credentials holds the certificate and CA pool of a health server or
+// client. When created from files, the files are re-read when the
+// credentials are used, so that they can be rotated without a restart.
+// Until a changed certificate and key load together, e.g. while only one
+// of them has been replaced, the previous credentials stay in use.
+//
+// The CA file may contain several certificates. During a CA rotation it
+// should contain both the old and the new CA, so that peers which have
+// not yet been given their new certificate are still trusted.
+type Credentials struct {
+	caFile   string
+	certFile string
+	keyFile  string
+	fs       boshsys.FileSystem
+	clock    clock.Clock
+	logger   boshlog.Logger
+
+	caRaw       []byte
+	certRaw     []byte
+	keyRaw      []byte
+	certificate *tls.Certificate
+	caPool      *x509.CertPool
+	lastCheck   time.Time
+	mutex       *sync.Mutex
+}
+
+// NewCredentials returns credentials which are never reloaded.
+func NewCredentials(caCert []byte, cert tls.Certificate) *Credentials {
+	caPool := x509.NewCertPool()
+	caPool.AppendCertsFromPEM(caCert)
+
+	return &Credentials{
+		certificate: &cert,
+		caPool:      caPool,
+		mutex:       &sync.Mutex{},
+	}
+}
+
+func NewCredentialsFromFiles(caFile, certFile, keyFile string, fs boshsys.FileSystem, clock clock.Clock, logger boshlog.Logger) (*Credentials, error) {
+	c := &Credentials{
+		caFile:   caFile,
+		certFile: certFile,
+		keyFile:  keyFile,
+		fs:       fs,
+		clock:    clock,
+		logger:   logger,
+		mutex:    &sync.Mutex{},
+	}
+
+	c.lastCheck = clock.Now()
+
+	err := c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Credentials) Certificate() *tls.Certificate {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.reload()
+
+	return c.certificate
+}
+
+func (c *Credentials) CAPool() *x509.CertPool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.reload()
+
+	return c.caPool
+}
+
+func (c *Credentials) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.Certificate(), nil
+}
+
+func (c *Credentials) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return c.Certificate(), nil
+}
+
+func (c *Credentials) reload() {
+	if c.fs == nil || c.clock.Since(c.lastCheck) < ReloadInterval {
+		return
+	}
+
+	c.lastCheck = c.clock.Now()
+
+	err := c.load()
+	if err != nil {
+		c.logger.Error(logTag, "Keeping previous health credentials: %s", err)
+	}
+}
+
+func (c *Credentials) load() error {
+	caRaw, err := c.fs.ReadFile(c.caFile)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading CA file '%s'", c.caFile)
+	}
+
+	certRaw, err := c.fs.ReadFile(c.certFile)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading certificate file '%s'", c.certFile)
+	}
+
+	keyRaw, err := c.fs.ReadFile(c.keyFile)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading private key file '%s'", c.keyFile)
+	}
+
+	if string(caRaw) != string(c.caRaw) {
+		caPool, err := newCAPool(caRaw)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Loading CA file '%s'", c.caFile)
+		}
+
+		if c.caPool != nil {
+			c.logger.Info(logTag, "Reloaded CA file '%s'", c.caFile)
+		}
+
+		c.caPool = caPool
+		c.caRaw = caRaw
+	}
+
+	if string(certRaw) != string(c.certRaw) || string(keyRaw) != string(c.keyRaw) {
+		cert, err := tls.X509KeyPair(certRaw, keyRaw)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Loading certificate file '%s' and private key file '%s'", c.certFile, c.keyFile)
+		}
+
+		if c.certificate != nil {
+			c.logger.Info(logTag, "Reloaded certificate file '%s'", c.certFile)
+		}
+
+		c.certificate = &cert
+		c.certRaw = certRaw
+		c.keyRaw = keyRaw
+	}
+
+	return nil
+}
+
+func newCAPool(caCert []byte) (*x509.CertPool, error) {
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caCert) {
+		return nil, bosherr.Error("No CA certificates found")
+	}
+
+	return caPool, nil
+}
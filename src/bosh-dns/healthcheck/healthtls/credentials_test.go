@@ -0,0 +1,114 @@
+package healthtls_test
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"bosh-dns/healthcheck/healthtls"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	sysfakes "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Credentials", func() {
+	var (
+		fs          *sysfakes.FakeFileSystem
+		fakeClock   *fakeclock.FakeClock
+		credentials *healthtls.Credentials
+	)
+
+	asset := func(name string) string {
+		contents, err := ioutil.ReadFile(filepath.Join("..", "assets", "test_certs", name))
+		Expect(err).NotTo(HaveOccurred())
+		return string(contents)
+	}
+
+	certificateBytes := func(name string) []byte {
+		block, _ := pem.Decode([]byte(asset(name)))
+		Expect(block).NotTo(BeNil())
+		return block.Bytes
+	}
+
+	caPool := func(names ...string) *x509.CertPool {
+		pool := x509.NewCertPool()
+		for _, name := range names {
+			Expect(pool.AppendCertsFromPEM([]byte(asset(name)))).To(BeTrue())
+		}
+		return pool
+	}
+
+	BeforeEach(func() {
+		fs = sysfakes.NewFakeFileSystem()
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+
+		fs.WriteFileString("/ca.pem", asset("test_ca.pem"))
+		fs.WriteFileString("/client.pem", asset("test_client.pem"))
+		fs.WriteFileString("/client.key", asset("test_client.key"))
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		credentials, err = healthtls.NewCredentialsFromFiles("/ca.pem", "/client.pem", "/client.key", fs, fakeClock, boshlog.NewLogger(boshlog.LevelNone))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("loads the certificate and CA from the files", func() {
+		Expect(credentials.Certificate().Certificate[0]).To(Equal(certificateBytes("test_client.pem")))
+		Expect(credentials.CAPool().Equal(caPool("test_ca.pem"))).To(BeTrue())
+
+		certificate, err := credentials.GetCertificate(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(certificate).To(Equal(credentials.Certificate()))
+
+		certificate, err = credentials.GetClientCertificate(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(certificate).To(Equal(credentials.Certificate()))
+	})
+
+	It("reloads changed files after the reload interval", func() {
+		fs.WriteFileString("/client.pem", asset("test_fake_client.pem"))
+		fs.WriteFileString("/ca.pem", asset("test_ca.pem")+asset("test_fake_ca.pem"))
+
+		fakeClock.Increment(healthtls.ReloadInterval - time.Millisecond)
+		Expect(credentials.Certificate().Certificate[0]).To(Equal(certificateBytes("test_client.pem")))
+
+		fakeClock.Increment(time.Millisecond)
+		Expect(credentials.Certificate().Certificate[0]).To(Equal(certificateBytes("test_fake_client.pem")))
+		Expect(credentials.CAPool().Equal(caPool("test_ca.pem", "test_fake_ca.pem"))).To(BeTrue())
+	})
+
+	It("keeps the previous certificate until the certificate and key match", func() {
+		fs.WriteFileString("/client.pem", asset("test_server.pem"))
+
+		fakeClock.Increment(healthtls.ReloadInterval)
+		Expect(credentials.Certificate().Certificate[0]).To(Equal(certificateBytes("test_client.pem")))
+
+		fs.WriteFileString("/client.key", asset("test_server.key"))
+
+		fakeClock.Increment(healthtls.ReloadInterval)
+		Expect(credentials.Certificate().Certificate[0]).To(Equal(certificateBytes("test_server.pem")))
+	})
+
+	It("keeps the previous credentials when the files cannot be read", func() {
+		fs.RegisterReadFileError("/ca.pem", errors.New("fake-err"))
+
+		fakeClock.Increment(healthtls.ReloadInterval)
+		Expect(credentials.Certificate().Certificate[0]).To(Equal(certificateBytes("test_client.pem")))
+		Expect(credentials.CAPool().Equal(caPool("test_ca.pem"))).To(BeTrue())
+	})
+
+	It("keeps the previous CA pool when the CA file contains no certificates", func() {
+		fs.WriteFileString("/ca.pem", "not a certificate")
+
+		fakeClock.Increment(healthtls.ReloadInterval)
+		Expect(credentials.CAPool().Equal(caPool("test_ca.pem"))).To(BeTrue())
+	})
+})
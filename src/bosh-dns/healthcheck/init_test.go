@@ -35,6 +35,7 @@ var (
 	configFile          *os.File
 	healthExecutableDir string
 	configPort          int
+	adminPort           int
 )
 
 var _ = SynchronizedBeforeSuite(func() []byte {
@@ -60,15 +61,19 @@ var _ = BeforeEach(func() {
 	Expect(err).ToNot(HaveOccurred())
 
 	configPort = 1234 + config.GinkgoConfig.ParallelNode
+	adminPort = 2234 + config.GinkgoConfig.ParallelNode
 
 	configContents, err := json.Marshal(healthserver.HealthCheckConfig{
 		Port:                     configPort,
+		AdminPort:                adminPort,
 		CertificateFile:          "assets/test_certs/test_server.pem",
 		PrivateKeyFile:           "assets/test_certs/test_server.key",
 		CAFile:                   "assets/test_certs/test_ca.pem",
 		HealthFileName:           healthFile.Name(),
 		HealthExecutablesGlob:    filepath.Join(healthExecutableDir, "*"),
 		HealthExecutableInterval: dnsconfig.DurationJSON(time.Millisecond),
+		HealthExecutableTimeout:  dnsconfig.DurationJSON(time.Second),
+		DegradedExitStatus:       3,
 	})
 	Expect(err).NotTo(HaveOccurred())
 
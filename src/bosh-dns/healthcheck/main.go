@@ -39,34 +39,44 @@ func mainExitCode() int {
 	}
 	shutdown := make(chan struct{})
 
+	sigterm := make(chan os.Signal, 1)
+	signal.Notify(sigterm, syscall.SIGTERM)
+
+	go func() {
+		<-sigterm
+		logger.Info(logTag, "Received SIGTERM, shutting down")
+		close(shutdown)
+	}()
+
 	fs := boshsys.NewOsFileSystem(logger)
+	systemClock := clock.NewClock()
 	cmdRunner := boshsys.NewExecCmdRunner(logger)
 	interval := time.Duration(config.HealthExecutableInterval)
-	executablePaths, err := fs.Glob(config.HealthExecutablesGlob)
-	if err != nil {
-		logger.Error(logTag, fmt.Sprintf("Error: %v", err.Error()))
-		return 1
+	timeout := time.Duration(config.HealthExecutableTimeout)
+	if timeout == 0 {
+		timeout = interval
 	}
-
 	healthExecutableMonitor := healthexecutable.NewHealthExecutableMonitor(
-		executablePaths,
+		config.HealthExecutablesGlob,
+		fs,
 		cmdRunner,
-		clock.NewClock(),
+		systemClock,
 		interval,
+		timeout,
+		config.DegradedExitStatus,
 		shutdown,
 		logger,
 	)
 
-	healthServer = healthserver.NewHealthServer(logger, fs, config.HealthFileName, healthExecutableMonitor)
-	healthServer.Serve(config)
-
-	sigterm := make(chan os.Signal, 1)
-	signal.Notify(sigterm, syscall.SIGTERM)
+	healthServer = healthserver.NewHealthServer(logger, fs, config.HealthFileName, healthExecutableMonitor, config.ObservationsFileName, systemClock)
+	err = healthServer.Serve(config, shutdown)
+	if err != nil {
+		logger.Error(logTag, fmt.Sprintf("Error: %v", err.Error()))
+		return 1
+	}
 
-	go func() {
-		<-sigterm
-		close(shutdown)
-	}()
+	<-healthExecutableMonitor.Stopped()
+	logger.Info(logTag, "Stopped")
 
 	return 0
 }
@@ -2,10 +2,12 @@ package main_test
 
 import (
 	"bosh-dns/healthcheck/healthclient"
+	"bosh-dns/healthcheck/healthserver"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os/exec"
 	"time"
 
 	"github.com/cloudfoundry/bosh-utils/httpclient"
@@ -13,6 +15,9 @@ import (
 
 	"path/filepath"
 
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -56,7 +61,7 @@ var _ = Describe("HealthCheck server", func() {
 				client, err := healthclient.NewHealthClientFromFiles(
 					"assets/test_certs/test_ca.pem",
 					"assets/test_certs/test_client.pem",
-					"assets/test_certs/test_client.key", logger)
+					"assets/test_certs/test_client.key", healthclient.DefaultConfig(), logger)
 				Expect(err).NotTo(HaveOccurred())
 
 				respData, err := secureGetRespBody(client, configPort)
@@ -83,7 +88,7 @@ var _ = Describe("HealthCheck server", func() {
 					client, err := healthclient.NewHealthClientFromFiles(
 						"assets/test_certs/test_ca.pem",
 						"assets/test_certs/test_client.pem",
-						"assets/test_certs/test_client.key", logger)
+						"assets/test_certs/test_client.key", healthclient.DefaultConfig(), logger)
 					Expect(err).NotTo(HaveOccurred())
 
 					respData, err := secureGetRespBody(client, configPort)
@@ -109,18 +114,46 @@ var _ = Describe("HealthCheck server", func() {
 					client, err := healthclient.NewHealthClientFromFiles(
 						"assets/test_certs/test_ca.pem",
 						"assets/test_certs/test_client.pem",
-						"assets/test_certs/test_client.key", logger)
+						"assets/test_certs/test_client.key", healthclient.DefaultConfig(), logger)
+					Expect(err).NotTo(HaveOccurred())
+
+					Eventually(func() map[string]interface{} {
+						respData, err := secureGetRespBody(client, configPort)
+						Expect(err).ToNot(HaveOccurred())
+						var respJson map[string]interface{}
+						err = json.Unmarshal(respData, &respJson)
+						Expect(err).ToNot(HaveOccurred())
+						return respJson
+					}, time.Second*2).Should(Equal(map[string]interface{}{
+						"state":               "job-health-executable-fail",
+						"failing_executables": []interface{}{filepath.Join(healthExecutableDir, "bad.ps1")},
+					}))
+				})
+			})
+
+			Describe("when the vm is healthy, but the job health executable reports degraded", func() {
+				BeforeEach(func() {
+					err := ioutil.WriteFile(filepath.Join(healthExecutableDir, "degraded.ps1"), []byte("#!/bin/bash\nexit 3"), 0700)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("returns degraded json output", func() {
+					client, err := healthclient.NewHealthClientFromFiles(
+						"assets/test_certs/test_ca.pem",
+						"assets/test_certs/test_client.pem",
+						"assets/test_certs/test_client.key", healthclient.DefaultConfig(), logger)
 					Expect(err).NotTo(HaveOccurred())
 
-					Eventually(func() map[string]string {
+					Eventually(func() map[string]interface{} {
 						respData, err := secureGetRespBody(client, configPort)
 						Expect(err).ToNot(HaveOccurred())
-						var respJson map[string]string
+						var respJson map[string]interface{}
 						err = json.Unmarshal(respData, &respJson)
 						Expect(err).ToNot(HaveOccurred())
 						return respJson
-					}, time.Second*2).Should(Equal(map[string]string{
-						"state": "job-health-executable-fail",
+					}, time.Second*2).Should(Equal(map[string]interface{}{
+						"state":                "degraded",
+						"degraded_executables": []interface{}{filepath.Join(healthExecutableDir, "degraded.ps1")},
 					}))
 				})
 			})
@@ -135,7 +168,7 @@ var _ = Describe("HealthCheck server", func() {
 				client, err := healthclient.NewHealthClientFromFiles(
 					"assets/test_certs/test_ca.pem",
 					"assets/test_certs/test_client.pem",
-					"assets/test_certs/test_client.key", logger)
+					"assets/test_certs/test_client.key", healthclient.DefaultConfig(), logger)
 				Expect(err).NotTo(HaveOccurred())
 
 				respData, err := secureGetRespBody(client, configPort)
@@ -155,7 +188,7 @@ var _ = Describe("HealthCheck server", func() {
 			client, err := healthclient.NewHealthClientFromFiles(
 				"assets/test_certs/test_fake_ca.pem",
 				"assets/test_certs/test_fake_client.pem",
-				"assets/test_certs/test_client.key", logger)
+				"assets/test_certs/test_client.key", healthclient.DefaultConfig(), logger)
 			Expect(err).NotTo(HaveOccurred())
 
 			_, err = secureGetRespBody(client, configPort)
@@ -163,23 +196,116 @@ var _ = Describe("HealthCheck server", func() {
 			Expect(err.Error()).To(ContainSubstring("x509: certificate signed by unknown authority"))
 		})
 
+		It("drains and exits cleanly on SIGTERM", func() {
+			sess.Terminate()
+
+			Eventually(sess, 10*time.Second).Should(gexec.Exit(0))
+			Expect(sess.Out).To(gbytes.Say("Draining requests"))
+		})
+
 		It("should reject a client cert with the wrong CN", func() {
 			client, err := healthclient.NewHealthClientFromFiles(
 				"assets/test_certs/test_ca.pem",
 				"assets/test_certs/test_wrong_cn_client.pem",
-				"assets/test_certs/test_client.key", logger)
+				"assets/test_certs/test_client.key", healthclient.DefaultConfig(), logger)
 			Expect(err).NotTo(HaveOccurred())
 
 			resp, err := secureGet(client, configPort)
 			Expect(err).ToNot(HaveOccurred())
 
-			Expect(resp.StatusCode).To(BeNumerically(">=", 400))
-			Expect(resp.StatusCode).To(BeNumerically("<", 500))
+			Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
 
 			respBody, err := ioutil.ReadAll(resp.Body)
 			Expect(err).ToNot(HaveOccurred())
 
-			Expect(respBody).To(Equal([]byte("TLS certificate common name does not match")))
+			Expect(string(respBody)).To(ContainSubstring("TLS certificate identity is not allowed"))
+		})
+	})
+})
+
+var _ = Describe("HealthCheck admin server", func() {
+	BeforeEach(func() {
+		startServer()
+		Expect(waitForServer(adminPort)).To(Succeed())
+	})
+
+	drain := func(method, query string) (int, map[string]interface{}) {
+		req, err := http.NewRequest(method, fmt.Sprintf("http://127.0.0.1:%d/drain%s", adminPort, query), nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+
+		var state map[string]interface{}
+		if resp.StatusCode == http.StatusOK {
+			Expect(json.NewDecoder(resp.Body).Decode(&state)).To(Succeed())
+		}
+
+		return resp.StatusCode, state
+	}
+
+	It("starts and stops draining", func() {
+		status, state := drain(http.MethodGet, "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(state).To(Equal(map[string]interface{}{"draining": false}))
+
+		status, state = drain(http.MethodPost, "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(state).To(Equal(map[string]interface{}{"draining": true}))
+
+		status, state = drain(http.MethodDelete, "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(state).To(Equal(map[string]interface{}{"draining": false}))
+	})
+
+	It("drains until the given duration has passed", func() {
+		status, state := drain(http.MethodPost, "?duration=1s")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(state).To(HaveKeyWithValue("draining", true))
+		Expect(state).To(HaveKey("until"))
+
+		Eventually(func() interface{} {
+			_, state := drain(http.MethodGet, "")
+			return state["draining"]
+		}, 3*time.Second).Should(BeFalse())
+	})
+
+	It("rejects invalid durations", func() {
+		status, _ := drain(http.MethodPost, "?duration=soon")
+		Expect(status).To(Equal(http.StatusBadRequest))
+	})
+
+	It("rejects other methods", func() {
+		status, _ := drain(http.MethodPut, "")
+		Expect(status).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
+
+var _ = Describe("HealthCheck server startup", func() {
+	Context("when the credentials cannot be loaded", func() {
+		BeforeEach(func() {
+			configRaw, err := ioutil.ReadFile(configFile.Name())
+			Expect(err).NotTo(HaveOccurred())
+
+			var config healthserver.HealthCheckConfig
+			Expect(json.Unmarshal(configRaw, &config)).To(Succeed())
+
+			config.CertificateFile = "assets/test_certs/missing.pem"
+
+			configRaw, err = json.Marshal(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.WriteFile(configFile.Name(), configRaw, 0666)).To(Succeed())
+		})
+
+		It("exits with an error", func() {
+			var err error
+			cmd = exec.Command(pathToServer, configFile.Name())
+			sess, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(sess).Should(gexec.Exit(1))
+			Expect(sess.Out).To(gbytes.Say("Loading health server credentials"))
 		})
 	})
 })
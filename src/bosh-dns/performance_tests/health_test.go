@@ -92,7 +92,7 @@ func setupSecureGet() *httpclient.HTTPClient {
 
 	logger := boshlog.NewAsyncWriterLogger(boshlog.LevelDebug, ioutil.Discard)
 
-	return healthclient.NewHealthClient(caCert, cert, logger)
+	return healthclient.NewHealthClient(caCert, cert, healthclient.DefaultConfig(), logger)
 }
 
 func secureGetHealthEndpoint(client *httpclient.HTTPClient, serverAddress string) (*http.Response, error) {
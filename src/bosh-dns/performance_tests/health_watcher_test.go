@@ -0,0 +1,140 @@
+package performance_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"bosh-dns/dns/server/healthiness"
+
+	"code.cloudfoundry.org/clock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakePeers answers checks like a deployment in which every tenth peer is
+// dead and only fails once its check has timed out.
+type fakePeers struct {
+	timeout time.Duration
+
+	mutex   sync.Mutex
+	running int
+	peak    int
+	checks  map[string][]time.Time
+}
+
+func (p *fakePeers) GetStatus(ip string) (healthiness.HealthStatus, error) {
+	p.mutex.Lock()
+	p.running++
+	if p.running > p.peak {
+		p.peak = p.running
+	}
+	p.checks[ip] = append(p.checks[ip], time.Now())
+	p.mutex.Unlock()
+
+	defer func() {
+		p.mutex.Lock()
+		p.running--
+		p.mutex.Unlock()
+	}()
+
+	if strings.HasSuffix(ip, "0") {
+		time.Sleep(p.timeout)
+		return healthiness.StatusUnhealthy, errors.New("timeout")
+	}
+
+	return healthiness.StatusHealthy, nil
+}
+
+func (p *fakePeers) checkedTwice() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	count := 0
+	for _, checks := range p.checks {
+		if len(checks) > 1 {
+			count++
+		}
+	}
+
+	return count
+}
+
+var _ = Describe("Health Watcher", func() {
+	const (
+		peerCount = 5000
+		workers   = 100
+		interval  = 2 * time.Second
+	)
+
+	var (
+		peers  *fakePeers
+		signal chan struct{}
+		ips    []string
+	)
+
+	BeforeEach(func() {
+		peers = &fakePeers{
+			timeout: 100 * time.Millisecond,
+			checks:  map[string][]time.Time{},
+		}
+		signal = make(chan struct{})
+
+		ips = []string{}
+		for i := 0; i < peerCount; i++ {
+			ips = append(ips, fmt.Sprintf("10.%d.%d.%d", i/65536, i/256%256, i%256))
+		}
+	})
+
+	AfterEach(func() {
+		close(signal)
+	})
+
+	It("sweeps thousands of peers evenly within the check interval and the worker limit", func() {
+		watcher := healthiness.NewHealthWatcher(peers, clock.NewClock(), healthiness.HealthWatcherConfig{
+			CheckInterval: interval,
+			Workers:       workers,
+		})
+
+		firstChecksStarted := time.Now()
+		watcher.Statuses(ips)
+		go watcher.Run(signal)
+
+		Eventually(func() int { return len(watcher.HealthStates()) }, interval).Should(Equal(peerCount))
+		Expect(time.Since(firstChecksStarted)).To(BeNumerically("<", interval))
+
+		Eventually(peers.checkedTwice, 3*interval, 10*time.Millisecond).Should(Equal(peerCount))
+
+		peers.mutex.Lock()
+		defer peers.mutex.Unlock()
+
+		Expect(peers.peak).To(BeNumerically("<=", workers))
+
+		var first, last time.Time
+		perSlice := map[int64]int{}
+		for _, checks := range peers.checks {
+			sweep := checks[1]
+			if first.IsZero() || sweep.Before(first) {
+				first = sweep
+			}
+			if sweep.After(last) {
+				last = sweep
+			}
+		}
+
+		for _, checks := range peers.checks {
+			perSlice[int64(checks[1].Sub(first)/(interval/10))]++
+		}
+
+		By(fmt.Sprintf("sweeping %d peers over %s with at most %d checks at a time", peerCount, last.Sub(first), peers.peak))
+
+		Expect(last.Sub(first)).To(BeNumerically(">", interval*8/10))
+		Expect(last.Sub(first)).To(BeNumerically("<", interval*12/10))
+
+		for slice, count := range perSlice {
+			Expect(count).To(BeNumerically("<", peerCount/10*2), fmt.Sprintf("slice %d of the interval", slice))
+		}
+	})
+})